@@ -7,6 +7,29 @@ type ExcelImporter interface {
 	Import(path string) error
 }
 
+// DryRunImporter the importer which reports if it only parses and checks the file,
+// so the caller can refuse the importer which ignores the dry run.
+type DryRunImporter interface {
+	ExcelImporter
+	// CheckDryRun check if the import only parses and checks the file
+	CheckDryRun() bool
+}
+
+type ExcelExporter interface {
+	// Export exports the data into the excel file.
+	Export(path string) error
+}
+
+// Sink receives the imported models, so the target of the import isn't limited to the gorm db.
+// e.g. the jsonl file, the message queue or the http api.
+type Sink interface {
+	// Write writes the record into the sink.
+	// the ID of the record should be set if the sink generates it, the tree node is linked by it.
+	Write(record *SinkRecord) error
+	// Flush flushes the buffered records, called after the import.
+	Flush() error
+}
+
 type RowModelFactory interface {
 	// MinColumnCount the min row count to construct raw model
 	MinColumnCount() int
@@ -0,0 +1,422 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"excel_import/general_framework"
+	"excel_import/import_effect"
+	"excel_import/tree_framework"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sync"
+)
+
+// Auditor write the audit records of the changes made by the imports of the job.
+// the inserted models are audited from the insert effects, the inserted tree nodes are fetched by the id,
+// and the updated records are snapshotted before the update effects are executed,
+// so the update effects should be executed by the batch feature or the sql runner middleware.
+// the importer which updates directly should call AuditUpdate with its tx before, so the pending updates are
+// snapshotted before the update is executed, otherwise the update is refused.
+// the pending changes are fetched in batched queries.
+type Auditor struct {
+	jobID string
+	cfg   *config
+
+	pendingUpdates []*pendingUpdate
+	pendingNodes   []*pendingNode
+	records        []*Record
+	// guard the pending changes, the records and their flushes, the middlewares run concurrently under the parallel import
+	mu sync.Mutex
+	// the tables audited by the importers, which can be updated directly
+	recorded import_effect.RecordedUpdates
+}
+
+type pendingUpdate struct {
+	row        *int
+	table      string
+	primaryKey string
+	updates    map[string]any
+	wheres     map[string]any
+}
+
+type pendingNode struct {
+	row   *int
+	table string
+	id    int64
+}
+
+func NewAuditor(jobID string, options ...OptionFunc) *Auditor {
+	return &Auditor{
+		jobID: jobID,
+		cfg:   newConfig(options...),
+	}
+}
+
+func (a *Auditor) GetJobID() string {
+	return a.jobID
+}
+
+// Migrate create the audit table if not exists
+func (a *Auditor) Migrate(tx *gorm.DB) error {
+	return tx.Table(a.cfg.auditTable).AutoMigrate(&Record{})
+}
+
+// AuditInsert audit the inserted model of the row, the model must be parsed by gorm.
+// the row starts from 0 as the rows of the recorder, nil if the change isn't from a row.
+func (a *Auditor) AuditInsert(tx *gorm.DB, row *int, model any) error {
+	s, err := import_effect.ParseSchema(tx, model)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(model)
+	values := make(map[string]any, len(s.Fields))
+	for _, field := range s.Fields {
+		if len(field.DBName) == 0 {
+			continue
+		}
+		values[field.DBName], _ = field.ValueOf(context.Background(), rv)
+	}
+
+	record := &Record{Row: row, Table: s.Table, Op: OpInsert}
+	if pk := s.PrioritizedPrimaryField; pk != nil {
+		if value, isZero := pk.ValueOf(context.Background(), rv); !isZero {
+			record.PrimaryKey = fmt.Sprint(value)
+		}
+	}
+	if record.After, err = encode(values); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.addRecords(tx, record)
+}
+
+// AuditUpdate audit the records of the table which are going to be updated.
+// the records are snapshotted when the pending updates reach the batch size or Flush is called.
+func (a *Auditor) AuditUpdate(tx *gorm.DB, row *int, table, primaryKey string, updates, wheres map[string]any) error {
+	a.recorded.Mark(tx, table)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pendingUpdates = append(a.pendingUpdates, &pendingUpdate{
+		row:        row,
+		table:      table,
+		primaryKey: primaryKey,
+		updates:    updates,
+		wheres:     wheres,
+	})
+	if len(a.pendingUpdates) >= a.cfg.batchSize {
+		return a.flushUpdates(tx)
+	}
+
+	return nil
+}
+
+// AuditNodeInsert audit the inserted record of the table by the id, which is fetched in batch
+func (a *Auditor) AuditNodeInsert(tx *gorm.DB, row *int, table string, id int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pendingNodes = append(a.pendingNodes, &pendingNode{row: row, table: table, id: id})
+	if len(a.pendingNodes) >= a.cfg.batchSize {
+		return a.flushNodes(tx)
+	}
+
+	return nil
+}
+
+// Flush snapshot the pending changes and write all the audit records
+func (a *Auditor) Flush(tx *gorm.DB) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.flushUpdates(tx); err != nil {
+		return err
+	}
+	if err := a.flushNodes(tx); err != nil {
+		return err
+	}
+
+	return a.writeRecords(tx)
+}
+
+// flushUpdates snapshot the pending updates, one query per table, the lock should be held.
+// the records updated several times are audited in order, the before of the later is the after of the former.
+func (a *Auditor) flushUpdates(tx *gorm.DB) error {
+	if len(a.pendingUpdates) == 0 {
+		return nil
+	}
+	pendings := a.pendingUpdates
+	a.pendingUpdates = nil
+
+	for _, table := range pendingTables(pendings) {
+		query := tx.Session(&gorm.Session{NewDB: true})
+		var cond *gorm.DB
+		for _, pending := range pendings {
+			if pending.table != table {
+				continue
+			}
+			if cond == nil {
+				cond = query.Where(pending.wheres)
+				continue
+			}
+			cond = cond.Or(pending.wheres)
+		}
+
+		rows := make([]map[string]any, 0)
+		if err := tx.Table(table).Where(cond).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			import_effect.NormalizeRow(row)
+		}
+
+		for _, pending := range pendings {
+			if pending.table != table {
+				continue
+			}
+			if err := a.auditRows(tx, pending, rows); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// auditRows audit the rows matched by the update, the rows are updated in place
+func (a *Auditor) auditRows(tx *gorm.DB, pending *pendingUpdate, rows []map[string]any) error {
+	for _, row := range rows {
+		if !match(row, pending.wheres) {
+			continue
+		}
+
+		before, err := encode(row)
+		if err != nil {
+			return err
+		}
+		for column, value := range pending.updates {
+			row[column] = value
+		}
+		after, err := encode(row)
+		if err != nil {
+			return err
+		}
+
+		record := &Record{
+			Row:        pending.row,
+			Table:      pending.table,
+			PrimaryKey: fmt.Sprint(row[pending.primaryKey]),
+			Op:         OpUpdate,
+			Before:     before,
+			After:      after,
+		}
+		if err = a.addRecords(tx, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushNodes fetch the pending inserted nodes, one query per table, the lock should be held
+func (a *Auditor) flushNodes(tx *gorm.DB) error {
+	if len(a.pendingNodes) == 0 {
+		return nil
+	}
+	pendings := a.pendingNodes
+	a.pendingNodes = nil
+
+	tables := make([]string, 0)
+	ids := make(map[string][]int64)
+	for _, pending := range pendings {
+		if _, ok := ids[pending.table]; !ok {
+			tables = append(tables, pending.table)
+		}
+		ids[pending.table] = append(ids[pending.table], pending.id)
+	}
+
+	rowsByID := make(map[string]map[string]any)
+	for _, table := range tables {
+		rows := make([]map[string]any, 0)
+		if err := tx.Table(table).Where(map[string]any{a.cfg.target.PrimaryKey: ids[table]}).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			import_effect.NormalizeRow(row)
+			rowsByID[table+"."+fmt.Sprint(row[a.cfg.target.PrimaryKey])] = row
+		}
+	}
+
+	for _, pending := range pendings {
+		row, ok := rowsByID[pending.table+"."+fmt.Sprint(pending.id)]
+		if !ok {
+			continue
+		}
+
+		after, err := encode(row)
+		if err != nil {
+			return err
+		}
+		record := &Record{Row: pending.row, Table: pending.table, PrimaryKey: fmt.Sprint(pending.id), Op: OpInsert, After: after}
+		if err = a.addRecords(tx, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *Auditor) addRecords(tx *gorm.DB, records ...*Record) error {
+	for _, record := range records {
+		record.JobID = a.jobID
+	}
+	a.records = append(a.records, records...)
+	if len(a.records) >= a.cfg.batchSize {
+		return a.writeRecords(tx)
+	}
+
+	return nil
+}
+
+func (a *Auditor) writeRecords(tx *gorm.DB) error {
+	if len(a.records) == 0 {
+		return nil
+	}
+
+	records := a.records
+	a.records = nil
+	return tx.Table(a.cfg.auditTable).CreateInBatches(records, a.cfg.batchSize).Error
+}
+
+// Middleware the middleware of the general framework, which audits the effects of the sections.
+// it should be added by WithMiddlewares, so that the updates are snapshotted before the batch is executed.
+func (a *Auditor) Middleware() general_framework.GeneralMiddleware {
+	return &generalMiddleware{auditor: a}
+}
+
+// TreeMiddleware the middleware of the tree framework, which audits the imported nodes by the id.
+// the tables of the nodes are resolved by import_effect.NodeTable, the nodes without the id are skipped.
+func (a *Auditor) TreeMiddleware(tables ...string) tree_framework.TreeMiddleware {
+	return &treeMiddleware{auditor: a, tables: tables}
+}
+
+type generalMiddleware struct {
+	auditor *Auditor
+}
+
+func (m *generalMiddleware) PreImportHandle(tx *gorm.DB, whole *general_framework.RawWhole) error {
+	return m.auditor.Migrate(tx)
+}
+
+func (m *generalMiddleware) PostImportSectionHandle(tx *gorm.DB, rc *general_framework.RawContent) error {
+	m.auditor.recorded.Release(rc)
+
+	row := rc.GetRow()
+	if im := rc.GetInsertModel(); im != nil {
+		if err := m.auditor.AuditInsert(tx, &row, im); err != nil {
+			return err
+		}
+	}
+
+	update, err := m.auditor.cfg.target.ResolveUpdate(tx, rc)
+	if err != nil || update == nil {
+		return err
+	}
+
+	return m.auditor.AuditUpdate(tx, &row, update.Table, update.PrimaryKey, update.Updates, update.Wheres)
+}
+
+// PreSectionUpdate snapshot the pending updates before the direct update of the importer is executed,
+// and refuse the update which isn't audited by AuditUpdate before
+func (m *generalMiddleware) PreSectionUpdate(tx *gorm.DB, rc *general_framework.RawContent, table string) error {
+	if !m.auditor.recorded.Check(rc, table) {
+		return fmt.Errorf("%w: table %q of the row %d", import_effect.ErrUnrecordedUpdate, table, rc.GetRow())
+	}
+
+	m.auditor.mu.Lock()
+	defer m.auditor.mu.Unlock()
+
+	return m.auditor.flushUpdates(tx)
+}
+
+// PreBatchFlushHandle snapshot the pending updates before they are executed by the batch
+func (m *generalMiddleware) PreBatchFlushHandle(tx *gorm.DB) error {
+	m.auditor.mu.Lock()
+	defer m.auditor.mu.Unlock()
+
+	return m.auditor.flushUpdates(tx)
+}
+
+func (m *generalMiddleware) PostHandle(tx *gorm.DB) error {
+	return m.auditor.Flush(tx)
+}
+
+type treeMiddleware struct {
+	auditor *Auditor
+	tables  []string
+}
+
+func (m *treeMiddleware) PreImportHandle(tx *gorm.DB, info tree_framework.TreeInfo) error {
+	return m.auditor.Migrate(tx)
+}
+
+func (m *treeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *tree_framework.TreeNode) error {
+	table, ok := import_effect.NodeTable(m.tables, node)
+	if !ok {
+		return nil
+	}
+
+	var row *int
+	if rows := node.GetRows(); len(rows) > 0 {
+		first := rows[0]
+		row = &first
+	}
+
+	return m.auditor.AuditNodeInsert(tx, row, table, node.GetID())
+}
+
+func (m *treeMiddleware) PostHandle(tx *gorm.DB) error {
+	return m.auditor.Flush(tx)
+}
+
+// pendingTables the tables of the pending updates in order
+func pendingTables(pendings []*pendingUpdate) []string {
+	tables := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pending := range pendings {
+		if seen[pending.table] {
+			continue
+		}
+		seen[pending.table] = true
+		tables = append(tables, pending.table)
+	}
+
+	return tables
+}
+
+// match check if the row matches the where condition, the values are compared as the text
+func match(row, wheres map[string]any) bool {
+	for column, value := range wheres {
+		if fmt.Sprint(row[column]) != fmt.Sprint(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encode the values in json, the keys are sorted
+func encode(values map[string]any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/import_effect"
+	"excel_import/tree_framework"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type auditExcelModel struct {
+	Code  string  `exi:"index:0"`
+	Price float64 `exi:"index:1,fcf:float"`
+}
+
+type auditProduct struct {
+	ID    int64   `gorm:"column:id;primaryKey"`
+	Code  string  `gorm:"column:code"`
+	Price float64 `gorm:"column:price"`
+}
+
+func (p *auditProduct) TableName() string {
+	return "audit_products"
+}
+
+// auditImporter insert the new products and update the existing ones by the code,
+// the effects are executed by the batch feature
+type auditImporter struct {
+}
+
+func (ai *auditImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	model := s.GetModel().(*auditExcelModel)
+
+	var count int64
+	if err := tx.Model(&auditProduct{}).Where("code = ?", model.Code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		s.SetInsertModel(&auditProduct{Code: model.Code, Price: model.Price})
+		return nil
+	}
+
+	s.SetUpdateModelCond(&auditProduct{}, map[string]any{"price": model.Price}, map[string]any{"code": model.Code})
+	return nil
+}
+
+func openAuditDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&auditProduct{}, &auditCategory{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func rowOf(row int) *int {
+	return &row
+}
+
+func newTestRecorder(dir string) *util.UnexpectedRecorder {
+	return util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+}
+
+func TestAuditor_General(t *testing.T) {
+	db := openAuditDB(t)
+	if err := db.Create([]*auditProduct{{Code: "P1", Price: 1}, {Code: "P2", Price: 2}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "products.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P1", "5"}, {"P3", "3"}, {"P1", "6"}, {"P2", "7"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	auditor := NewAuditor("job-1", WithAuditTable("audits"), WithBatchSize(2))
+	control := general_framework.ImportControl{StartRow: 1, Ef: util.DefaultRowEndFunc, EnableBatch: true, BatchSize: 2}
+	framework := general_framework.NewImporterOneSectionFramework(db, &auditImporter{},
+		general_framework.WithControl(control),
+		general_framework.WithSimpleModelFactory(&auditExcelModel{}),
+		general_framework.WithMiddlewares(auditor.Middleware()),
+		general_framework.WithRecorder(newTestRecorder(dir)),
+		general_framework.WithProgressReporter(util.NewProgressReporter(false)))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*Record
+	if err := db.Table("audits").Order("sheet_row").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	// the later update of the same record starts from the former one
+	expected := []*Record{
+		{Row: rowOf(1), Table: "audit_products", PrimaryKey: "1", Op: OpUpdate,
+			Before: `{"code":"P1","id":1,"price":1}`, After: `{"code":"P1","id":1,"price":5}`},
+		{Row: rowOf(2), Table: "audit_products", Op: OpInsert, After: `{"code":"P3","id":0,"price":3}`},
+		{Row: rowOf(3), Table: "audit_products", PrimaryKey: "1", Op: OpUpdate,
+			Before: `{"code":"P1","id":1,"price":5}`, After: `{"code":"P1","id":1,"price":6}`},
+		{Row: rowOf(4), Table: "audit_products", PrimaryKey: "2", Op: OpUpdate,
+			Before: `{"code":"P2","id":2,"price":2}`, After: `{"code":"P2","id":2,"price":7}`},
+	}
+	for i, record := range records {
+		e := expected[i]
+		if record.JobID != "job-1" || record.Row == nil || *record.Row != *e.Row || record.Table != e.Table || record.PrimaryKey != e.PrimaryKey ||
+			record.Op != e.Op || record.Before != e.Before || record.After != e.After {
+			t.Fatalf("record %d is %+v, expected %+v", i, record, e)
+		}
+	}
+
+	product := &auditProduct{}
+	if err := db.First(product, "code = ?", "P1").Error; err != nil || product.Price != 6 {
+		t.Fatalf("unexpected product %+v, err %v", product, err)
+	}
+}
+
+// directAuditImporter update the price directly, audited before if set
+type directAuditImporter struct {
+	auditor *Auditor
+}
+
+func (di *directAuditImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	model := s.GetModel().(*auditExcelModel)
+	updates, wheres := map[string]any{"price": model.Price}, map[string]any{"code": model.Code}
+	if di.auditor != nil {
+		row := s.GetRow()
+		if err := di.auditor.AuditUpdate(tx, &row, "audit_products", "id", updates, wheres); err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&auditProduct{}).Where(wheres).Updates(updates).Error
+}
+
+func TestAuditor_DirectUpdate(t *testing.T) {
+	db := openAuditDB(t)
+	if err := db.Create(&auditProduct{Code: "P1", Price: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "products.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P1", "5"}}); err != nil {
+		t.Fatal(err)
+	}
+	importDirect := func(auditor, audited *Auditor) error {
+		framework := general_framework.NewImporterOneSectionFramework(db, &directAuditImporter{auditor: audited},
+			general_framework.WithSimpleModelFactory(&auditExcelModel{}),
+			general_framework.WithMiddlewares(auditor.Middleware()),
+			general_framework.WithRecorder(newTestRecorder(dir)),
+			general_framework.WithProgressReporter(util.NewProgressReporter(false)))
+		return framework.Import(path)
+	}
+
+	// the update which isn't audited before is refused
+	auditor := NewAuditor("job-4")
+	if err := importDirect(auditor, nil); !errors.Is(err, import_effect.ErrUnrecordedUpdate) {
+		t.Fatalf("expected the unrecorded update error, got %v", err)
+	}
+
+	// the before is snapshotted before the direct update
+	if err := importDirect(auditor, auditor); err != nil {
+		t.Fatal(err)
+	}
+	var records []*Record
+	if err := db.Where("job_id = ?", "job-4").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Before != `{"code":"P1","id":1,"price":1}` || records[0].After != `{"code":"P1","id":1,"price":5}` {
+		t.Fatalf("unexpected records %+v", records)
+	}
+}
+
+type auditCategory struct {
+	ID       int64  `gorm:"column:id;primaryKey"`
+	Name     string `gorm:"column:name"`
+	ParentID int64  `gorm:"column:parent_id"`
+}
+
+func TestAuditor_Concurrent(t *testing.T) {
+	db := openAuditDB(t)
+	// sqlite allows only one writer
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err = db.Create(&auditProduct{Code: "P1", Price: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	auditor := NewAuditor("job-3", WithBatchSize(3))
+	if err = auditor.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- auditor.AuditInsert(db, &i, &auditProduct{ID: int64(i + 1), Code: fmt.Sprintf("C%d", i)})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			errs <- auditor.AuditUpdate(db, &i, "audit_products", "id", map[string]any{"price": i}, map[string]any{"code": "P1"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err = range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = auditor.Flush(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// no audit record is lost
+	var count int64
+	if err = db.Model(&Record{}).Where("job_id = ?", "job-3").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 40 {
+		t.Fatalf("got %d audit records, expected 40", count)
+	}
+
+	// the change which isn't from a row has no row
+	if err = auditor.AuditInsert(db, nil, &auditProduct{ID: 21, Code: "C20"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = auditor.Flush(db); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Model(&Record{}).Where("job_id = ? AND sheet_row IS NULL", "job-3").Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("got %d audit records without the row, err %v", count, err)
+	}
+}
+
+type categoryModelFac struct {
+}
+
+func (mf *categoryModelFac) GetModel() any {
+	return &categoryModel{}
+}
+
+func (mf *categoryModelFac) MinColumnCount() int {
+	return 2
+}
+
+type categoryModel struct {
+	L1, L2 string
+}
+
+type categoryImporter struct {
+}
+
+func (ci *categoryImporter) ImportLevelNode(tx *gorm.DB, node *tree_framework.TreeNode) error {
+	category := &auditCategory{Name: node.GetValue(), ParentID: node.GetParent().GetID()}
+	if err := tx.Create(category).Error; err != nil {
+		return err
+	}
+	node.SetID(category.ID)
+
+	return nil
+}
+
+func TestAuditor_Tree(t *testing.T) {
+	db := openAuditDB(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "categories.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"一级", "二级"}, {"水果", "苹果"}, {"蔬菜", "白菜"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	auditor := NewAuditor("job-2", WithBatchSize(3))
+	cfg := &tree_framework.TreeImportCfg{LevelOrder: []int{0, 1}, TreeBoundary: 1, ColumnCount: 2, ModelFac: &categoryModelFac{}}
+	ci := &categoryImporter{}
+	framework := tree_framework.NewTreeImportFramework(db, cfg, nil, []tree_framework.LevelImporter{ci, ci},
+		tree_framework.WithMiddlewares(auditor.TreeMiddleware("audit_categories")),
+		tree_framework.WithRecorder(newTestRecorder(dir)), tree_framework.WithProgressReporter(util.NewProgressReporter(false)))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*Record
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	// the levels are imported one by one, the apple is the third node
+	apple := records[2]
+	if apple.Row == nil || *apple.Row != 1 || apple.PrimaryKey != "3" || apple.Op != OpInsert || apple.After != `{"id":3,"name":"苹果","parent_id":1}` {
+		t.Fatalf("unexpected record %+v", apple)
+	}
+}
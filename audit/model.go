@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"excel_import/import_effect"
+	"time"
+)
+
+type Op string
+
+const (
+	OpInsert Op = "insert"
+	OpUpdate Op = "update"
+
+	defaultAuditTable = "import_audits"
+	defaultBatchSize  = 500
+)
+
+type OptionFunc func(*config)
+
+type config struct {
+	auditTable string
+	target     import_effect.Target
+	batchSize  int
+}
+
+func newConfig(options ...OptionFunc) *config {
+	cfg := &config{
+		auditTable: defaultAuditTable,
+		target:     import_effect.NewTarget(),
+		batchSize:  defaultBatchSize,
+	}
+
+	for _, option := range options {
+		option(cfg)
+	}
+	if cfg.batchSize <= 0 {
+		cfg.batchSize = defaultBatchSize
+	}
+
+	return cfg
+}
+
+// WithAuditTable set the table of the audit records, default is import_audits
+func WithAuditTable(table string) OptionFunc {
+	return func(cfg *config) {
+		cfg.auditTable = table
+	}
+}
+
+// WithTable set the table of the update effects without the update model
+func WithTable(table string) OptionFunc {
+	return func(cfg *config) {
+		cfg.target.Table = table
+	}
+}
+
+// WithPrimaryKey set the primary key column of the tables without the model, default is id
+func WithPrimaryKey(column string) OptionFunc {
+	return func(cfg *config) {
+		cfg.target.PrimaryKey = column
+	}
+}
+
+// WithBatchSize set the count of the pending changes which are snapshotted in one query,
+// and the count of the audit records which are written in one insertion. default is 500
+func WithBatchSize(size int) OptionFunc {
+	return func(cfg *config) {
+		cfg.batchSize = size
+	}
+}
+
+// Record the audit record of the changed record
+type Record struct {
+	ID    int64  `gorm:"column:id;primaryKey"`
+	JobID string `gorm:"column:job_id;index"`
+	// the row of the sheet, start from 0 as the rows of the recorder. nil if the change isn't from a row
+	Row   *int   `gorm:"column:sheet_row"`
+	Table string `gorm:"column:table_name"`
+	// the primary key of the changed record, empty if the inserted record isn't inserted yet
+	PrimaryKey string `gorm:"column:primary_key"`
+	Op         Op     `gorm:"column:op"`
+	// the values of the record in json, before is empty for the insertion
+	Before    string    `gorm:"column:before_json"`
+	After     string    `gorm:"column:after_json"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (r *Record) TableName() string {
+	return defaultAuditTable
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultStructName = "ExcelModel"
+	defaultSqlBatch   = 100
+
+	driverMysql  = "mysql"
+	driverSqlite = "sqlite"
+)
+
+type filesResult struct {
+	Files []string `json:"files"`
+}
+
+func runGenModel(args []string) (any, error) {
+	fs := newFlagSet("gen-model")
+	file := fs.String("file", "", "the excel file")
+	name := fs.String("name", defaultStructName, "the name of the struct")
+	if err := parseFlags(fs, args, "file", "name"); err != nil {
+		return nil, err
+	}
+
+	model, err := util.GenerateExcelModelString(*file, *name)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"model": model}, nil
+}
+
+func runSplitSheets(args []string) (any, error) {
+	fs := newFlagSet("split-sheets")
+	file := fs.String("file", "", "the xlsx file")
+	suffix := fs.String("suffix", "", "only split the sheets with the suffix, all the sheets if empty")
+	if err := parseFlags(fs, args, "file"); err != nil {
+		return nil, err
+	}
+
+	files, err := util.DivideSheetsIntoTablesBySuffixKey(*file, *suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filesResult{Files: files}, nil
+}
+
+func runCombine(args []string) (any, error) {
+	fs := newFlagSet("combine")
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < 2 {
+		return nil, fmt.Errorf("%w: at least 2 files are required", errUsage)
+	}
+
+	if err := util.CombineTablesIntoOne(fs.Args()...); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"file": fs.Arg(0)}, nil
+}
+
+func runSplitRows(args []string) (any, error) {
+	fs := newFlagSet("split-rows")
+	file := fs.String("file", "", "the csv or xlsx file")
+	rows := fs.Int("rows", 0, "the max data rows of each table")
+	if err := parseFlags(fs, args, "file"); err != nil {
+		return nil, err
+	}
+	if *rows <= 0 {
+		return nil, fmt.Errorf("%w: -rows should be positive", errUsage)
+	}
+
+	files, err := util.DivideExcelContent(*file, *rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filesResult{Files: files}, nil
+}
+
+func runSplitTrees(args []string) (any, error) {
+	fs := newFlagSet("split-trees")
+	file := fs.String("file", "", "the csv or xlsx file")
+	outputDir := fs.String("out", "", "the output directory, the directory of the file if empty")
+	keys := fs.String("keys", "", "the comma separated indexes of the columns which identify the tree")
+	if err := parseFlags(fs, args, "file", "keys"); err != nil {
+		return nil, err
+	}
+
+	indexes, err := parseIndexes(*keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(*outputDir) == 0 {
+		*outputDir = filepath.Dir(*file)
+	}
+
+	files, err := util.DivideMultipleTreesIntoMultipleTables(*file, *outputDir, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filesResult{Files: files}, nil
+}
+
+func runSql(args []string) (any, error) {
+	fs := newFlagSet("run-sql")
+	file := fs.String("file", "", "the sql file, one sentence per line")
+	driver := fs.String("driver", driverMysql, "the db driver, mysql or sqlite")
+	dsn := fs.String("dsn", "", "the dsn of the db")
+	batch := fs.Int("batch", defaultSqlBatch, "the sentences executed in one batch")
+	if err := parseFlags(fs, args, "file", "dsn"); err != nil {
+		return nil, err
+	}
+	if *batch <= 0 {
+		return nil, fmt.Errorf("%w: -batch should be positive", errUsage)
+	}
+
+	db, err := openDB(*driver, *dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := util.NewSqlSentencesRunner(*file, db, "")
+	if err = runner.OpenSqlFile(); err != nil {
+		return nil, err
+	}
+	defer runner.Close()
+
+	if err = runner.RunSqlSentencesWithBatch(*batch); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"file": *file, "batch": *batch}, nil
+}
+
+func openDB(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case driverMysql:
+		return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	case driverSqlite:
+		return gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	default:
+		return nil, fmt.Errorf("%w: unsupported driver %s", errUsage, driver)
+	}
+}
+
+func parseIndexes(s string) ([]int, error) {
+	var indexes []int
+	for _, part := range strings.Split(s, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("%w: invalid column index %q", errUsage, part)
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
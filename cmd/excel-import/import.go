@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/import_spec"
+	util "excel_import/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	checkFailedFileName   = "check_failed.csv"
+	importFailedFileName  = "import_failed.csv"
+	unexpectedLogFileName = "unexpected.jsonl"
+)
+
+// rowError the recorded error of the rows
+type rowError struct {
+	Rows  []int  `json:"rows,omitempty"`
+	Error string `json:"error"`
+}
+
+// importResult the result of the validate and import commands
+type importResult struct {
+	Total            int         `json:"total"`
+	Success          int         `json:"success"`
+	Failed           int         `json:"failed"`
+	CheckErrors      []*rowError `json:"check_errors,omitempty"`
+	ImportErrors     []*rowError `json:"import_errors,omitempty"`
+	CheckFailedPath  string      `json:"check_failed_path,omitempty"`
+	ImportFailedPath string      `json:"import_failed_path,omitempty"`
+}
+
+// errorCollector collect the recorded errors into the result
+type errorCollector struct {
+	result *importResult
+	mu     sync.Mutex
+}
+
+func (c *errorCollector) ObserveCheckError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.result.CheckErrors = append(c.result.CheckErrors, newRowError(err))
+}
+
+func (c *errorCollector) ObserveImportError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.result.ImportErrors = append(c.result.ImportErrors, newRowError(err))
+}
+
+func (c *errorCollector) FlushObserved() error {
+	return nil
+}
+
+func newRowError(err error) *rowError {
+	re := &rowError{Error: err.Error()}
+
+	var rowErr *util.RowError
+	if errors.As(err, &rowErr) {
+		// the rows of the result start from 1, as shown in the excel
+		for _, row := range rowErr.Rows {
+			re.Rows = append(re.Rows, row+1)
+		}
+	}
+
+	return re
+}
+
+func runValidate(args []string) (any, error) {
+	fs := newFlagSet("validate")
+	specPath := fs.String("spec", "", "the yaml or json import spec")
+	file := fs.String("file", "", "the csv or xlsx file")
+	outputDir := fs.String("out", "", "the directory of the failure files, the directory of the file if empty")
+	if err := parseFlags(fs, args, "spec", "file"); err != nil {
+		return nil, err
+	}
+
+	spec, err := loadSpec(*specPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return runSpec(*file, *outputDir, func(options ...general_framework.OptionFunc) (*import_spec.Runtime, error) {
+		runtime := import_spec.NewRuntime(spec, nil, options...)
+		return runtime, runtime.Validate(*file)
+	})
+}
+
+func runImport(args []string) (any, error) {
+	fs := newFlagSet("import")
+	specPath := fs.String("spec", "", "the yaml or json import spec")
+	file := fs.String("file", "", "the csv or xlsx file")
+	driver := fs.String("driver", driverMysql, "the db driver, mysql or sqlite")
+	dsn := fs.String("dsn", "", "the dsn of the db")
+	outputDir := fs.String("out", "", "the directory of the failure files, the directory of the file if empty")
+	if err := parseFlags(fs, args, "spec", "file", "dsn"); err != nil {
+		return nil, err
+	}
+
+	spec, err := loadSpec(*specPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.Target.Table) == 0 {
+		return nil, fmt.Errorf("%w: the target table of the spec is required", errUsage)
+	}
+
+	db, err := openDB(*driver, *dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return runSpec(*file, *outputDir, func(options ...general_framework.OptionFunc) (*import_spec.Runtime, error) {
+		runtime := import_spec.NewRuntime(spec, db, options...)
+		return runtime, runtime.Import(*file)
+	})
+}
+
+// loadSpec load the spec, the invalid spec is the usage error
+func loadSpec(path string) (*import_spec.Spec, error) {
+	spec, err := import_spec.Load(path)
+	if errors.Is(err, import_spec.ErrInvalidSpec) {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	return spec, err
+}
+
+// runSpec run the spec runtime with the recorder, the recorded errors are collected into the result
+func runSpec(file, outputDir string, run func(options ...general_framework.OptionFunc) (*import_spec.Runtime, error)) (*importResult, error) {
+	if len(outputDir) == 0 {
+		outputDir = filepath.Dir(file)
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	result := &importResult{}
+	recorder := util.NewUnexpectedRecorder(filepath.Join(outputDir, checkFailedFileName),
+		filepath.Join(outputDir, importFailedFileName), filepath.Join(outputDir, unexpectedLogFileName))
+	recorder.AddObserver(&errorCollector{result: result})
+	reporter := util.NewProgressReporter(false)
+
+	runtime, err := run(general_framework.WithRecorder(recorder), general_framework.WithProgressReporter(reporter))
+
+	result.Total = runtime.GetRows()
+	result.Success = reporter.GetSuccess()
+	result.Failed = reporter.GetFailed()
+	if len(result.CheckErrors) > 0 {
+		result.CheckFailedPath = recorder.GetCheckFailedPath()
+		return result, fmt.Errorf("%w: %d rows failed the check", errInvalidContent, len(result.CheckErrors))
+	}
+	if len(result.ImportErrors) > 0 {
+		result.ImportFailedPath = recorder.GetImportFailedPath()
+	}
+	if errors.Is(err, import_spec.ErrRowsUnexpected) {
+		return result, fmt.Errorf("%w: %v", errInvalidContent, err)
+	}
+
+	return result, err
+}
@@ -0,0 +1,141 @@
+// Command excel-import exposes the utilities and the import framework on the command line.
+//
+//	excel-import <command> [flags]
+//
+// the result of the command is written to the stdout as a json object,
+// and the exit code is 0 for success, 1 for failure, 2 for the usage error and 3 for the invalid content.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitInvalid = 3
+)
+
+var (
+	errUsage          = errors.New("usage error")
+	errInvalidContent = errors.New("invalid content")
+)
+
+type command struct {
+	usage string
+	run   func(args []string) (any, error)
+}
+
+var commands = map[string]*command{
+	"gen-model":    {usage: "generate the excel model struct from the header and the rows", run: runGenModel},
+	"split-sheets": {usage: "split the sheets of the workbook into tables", run: runSplitSheets},
+	"combine":      {usage: "combine the tables into the first one", run: runCombine},
+	"split-rows":   {usage: "split the rows of the table into tables", run: runSplitRows},
+	"split-trees":  {usage: "split the trees of the table into tables by the key columns", run: runSplitTrees},
+	"run-sql":      {usage: "run the sql file with batch", run: runSql},
+	"validate":     {usage: "check the table by the import spec", run: runValidate},
+	"import":       {usage: "import the table by the import spec", run: runImport},
+}
+
+// output the json result of the command
+type output struct {
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Result  any    `json:"result,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func main() {
+	// the library logs by fmt.Printf, keep the stdout for the json result
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	os.Exit(run(os.Args[1:], stdout))
+}
+
+func run(args []string, stdout io.Writer) int {
+	if len(args) == 0 {
+		printUsage()
+		return writeOutput(stdout, &output{}, fmt.Errorf("%w: command is required", errUsage))
+	}
+
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		return writeOutput(stdout, &output{Command: name}, fmt.Errorf("%w: unknown command %s", errUsage, name))
+	}
+
+	result, err := cmd.run(args[1:])
+	return writeOutput(stdout, &output{Command: name, Result: result}, err)
+}
+
+// writeOutput write the result and return the exit code of the error
+func writeOutput(w io.Writer, out *output, err error) int {
+	code := exitOK
+	switch {
+	case err == nil:
+		out.OK = true
+	case errors.Is(err, errUsage):
+		code = exitUsage
+	case errors.Is(err, errInvalidContent):
+		code = exitInvalid
+	default:
+		code = exitFailed
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if eerr := encoder.Encode(out); eerr != nil {
+		fmt.Fprintf(os.Stderr, "write output failed: %v\n", eerr)
+		return exitFailed
+	}
+
+	return code
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: excel-import <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+// newFlagSet create the flag set whose errors are returned instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parse the args, the parse error and the missing required flags are usage errors
+func parseFlags(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	for _, name := range required {
+		if f := fs.Lookup(name); f != nil && len(f.Value.String()) == 0 {
+			return fmt.Errorf("%w: -%s is required", errUsage, name)
+		}
+	}
+
+	return nil
+}
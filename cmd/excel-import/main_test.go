@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	util "excel_import/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type cliProduct struct {
+	ID    int64
+	Name  string
+	Price float64
+	Kind  string
+}
+
+func runCommand(t *testing.T, args ...string) (int, map[string]any) {
+	stdout := &bytes.Buffer{}
+	code := run(args, stdout)
+
+	out := make(map[string]any)
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("invalid output %s: %v", stdout.String(), err)
+	}
+
+	return code, out
+}
+
+func writeFile(t *testing.T, path, content string) string {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"unknown"},
+		{"split-rows", "-file", "a.csv"},
+		{"split-trees", "-file", "a.csv", "-keys", "a"},
+		{"validate", "-file", "a.csv"},
+	}
+
+	for _, args := range tests {
+		if code, out := runCommand(t, args...); code != exitUsage || out["ok"] != false {
+			t.Fatalf("args %v exit %d, output %v", args, code, out)
+		}
+	}
+}
+
+func TestRun_SplitRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"名称"}, {"a"}, {"b"}, {"c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runCommand(t, "split-rows", "-file", path, "-rows", "2")
+	if code != exitOK {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+	if files := out["result"].(map[string]any)["files"].([]any); len(files) != 2 {
+		t.Fatalf("unexpected files %v", files)
+	}
+}
+
+func TestRun_ValidateAndImport(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "data.db")
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&cliProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := writeFile(t, filepath.Join(dir, "product.yaml"), `
+name: product
+columns:
+  - {header: 名称, field: name, required: true}
+  - {header: 价格, type: float, fcf: float, field: price}
+  - {header: 类型, enum: [水果, 蔬菜], field: kind}
+target:
+  table: cli_products
+`)
+
+	// the invalid rows are reported with the excel rows
+	invalid := filepath.Join(dir, "invalid.csv")
+	if err = util.WriteExcelContent(invalid, [][]string{{"名称", "价格", "类型", "备注"},
+		{"苹果", "1.5", "水果", ""}, {"白菜", "abc", "肉", ""}}); err != nil {
+		t.Fatal(err)
+	}
+	code, out := runCommand(t, "validate", "-spec", spec, "-file", invalid)
+	if code != exitInvalid {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+	result := out["result"].(map[string]any)
+	checkErrors := result["check_errors"].([]any)
+	if result["total"] != float64(2) || len(checkErrors) != 1 || checkErrors[0].(map[string]any)["rows"].([]any)[0] != float64(3) {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	// the valid rows are imported into the table
+	valid := filepath.Join(dir, "valid.csv")
+	if err = util.WriteExcelContent(valid, [][]string{{"名称", "价格", "类型", "备注"},
+		{"苹果", "1.5", "水果", "a"}, {"白菜", "2", "蔬菜", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if code, out = runCommand(t, "validate", "-spec", spec, "-file", valid); code != exitOK {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+	if code, out = runCommand(t, "import", "-spec", spec, "-file", valid, "-driver", driverSqlite, "-dsn", dbPath); code != exitOK {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+	if result = out["result"].(map[string]any); result["success"] != float64(2) {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	var products []*cliProduct
+	if err = db.Order("id").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || products[0].Name != "苹果" || products[0].Price != 1.5 || products[1].Kind != "蔬菜" {
+		t.Fatalf("unexpected products %+v", products)
+	}
+}
+
+func TestRun_RunSql(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "data.db")
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&cliProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sqlPath := writeFile(t, filepath.Join(dir, "products.sql"),
+		"INSERT INTO cli_products (name) VALUES ('a');\nINSERT INTO cli_products (name) VALUES ('b');\nINSERT INTO cli_products (name) VALUES ('c');\n")
+	if code, out := runCommand(t, "run-sql", "-file", sqlPath, "-driver", driverSqlite, "-dsn", dbPath, "-batch", "2"); code != exitOK {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+
+	var count int64
+	if err = db.Model(&cliProduct{}).Count(&count).Error; err != nil || count != 3 {
+		t.Fatalf("got %d products, err %v", count, err)
+	}
+
+	// the failure of the sql exits with the failure code
+	if code, out := runCommand(t, "run-sql", "-file", filepath.Join(dir, "missing.sql"), "-driver", driverSqlite, "-dsn", dbPath); code != exitFailed {
+		t.Fatalf("exit %d, output %v", code, out)
+	}
+}
@@ -0,0 +1,153 @@
+package export_framework
+
+import (
+	"errors"
+	"excel_import"
+	"excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+)
+
+var (
+	errInvalidModel = errors.New("model should be a pointer to a struct")
+)
+
+// ExportFramework exports the query result into the excel file with the layout of the exi tags,
+// so that the exported file can be imported by ImportFramework with the same model.
+type ExportFramework struct {
+	query   *gorm.DB
+	model   any
+	tags    []*excel_import.ExcelImportTagAttr
+	control ExportControl
+}
+
+func WithControl(control ExportControl) OptionFunc {
+	return func(framework *ExportFramework) {
+		framework.control = control
+	}
+}
+
+func WithSheetName(sheetName string) OptionFunc {
+	return func(framework *ExportFramework) {
+		framework.control.SheetName = sheetName
+	}
+}
+
+func WithoutHeader() OptionFunc {
+	return func(framework *ExportFramework) {
+		framework.control.WithoutHeader = true
+	}
+}
+
+// NewExportFramework create the export framework.
+// query is the gorm query of the exported rows, model is the exi tagged model which is scanned by the query.
+func NewExportFramework(query *gorm.DB, model any, options ...OptionFunc) *ExportFramework {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		panic(errInvalidModel)
+	}
+
+	ef := &ExportFramework{
+		query:   query,
+		model:   model,
+		tags:    util.ParseTag(model),
+		control: defaultExportControl,
+	}
+
+	for _, option := range options {
+		option(ef)
+	}
+
+	if len(ef.control.SheetName) == 0 {
+		ef.control.SheetName = defaultSheetName
+	}
+
+	return ef
+}
+
+func (e *ExportFramework) WithOption(option OptionFunc) *ExportFramework {
+	option(e)
+	return e
+}
+
+// Export exports the query result into the file, support CSV and XLSX format.
+// the rows are streamed from the query and into the file, so the large result set won't be loaded at once.
+func (e *ExportFramework) Export(path string) error {
+	writer, err := newRowWriter(path, e.control.SheetName)
+	if err != nil {
+		return err
+	}
+
+	if err = e.export(writer); err != nil {
+		writer.Close()
+		fmt.Printf("export content failed: %v\n", err)
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (e *ExportFramework) export(writer rowWriter) error {
+	// write the header
+	if !e.control.WithoutHeader {
+		if err := writer.WriteRow(util.ParseHeaderNames(e.model)); err != nil {
+			return err
+		}
+	}
+
+	query := e.query
+	if query.Statement.Model == nil && len(query.Statement.Table) == 0 {
+		query = query.Model(e.model)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// write the rows one by one
+	columnCount := e.columnCount()
+	for rows.Next() {
+		model := util.NewModel(e.model)
+		if err = query.ScanRows(rows, model); err != nil {
+			return err
+		}
+
+		row, err := e.modelToRow(model, columnCount)
+		if err != nil {
+			return err
+		}
+
+		if err = writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (e *ExportFramework) columnCount() int {
+	count := 0
+	for _, tag := range e.tags {
+		count = max(count, tag.ColumnIndex+1)
+	}
+
+	return count
+}
+
+// modelToRow translate the model into the row in the tag column order
+func (e *ExportFramework) modelToRow(model any, columnCount int) ([]string, error) {
+	row := make([]string, columnCount)
+	for i, tag := range e.tags {
+		s, err := util.GetFieldString(model, i)
+		if err != nil {
+			return nil, err
+		}
+
+		row[tag.ColumnIndex] = s
+	}
+
+	return row, nil
+}
@@ -0,0 +1,231 @@
+package export_framework
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/tree_framework"
+	util "excel_import/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type exportProduct struct {
+	ID         int64     `exi:"index:0,name:编号" gorm:"column:id;primaryKey"`
+	Name       string    `exi:"index:1,name:名称" gorm:"column:name"`
+	Price      float64   `exi:"index:2,name:价格" gorm:"column:price"`
+	OnSale     bool      `exi:"index:3,name:在售" gorm:"column:on_sale"`
+	CreateTime time.Time `exi:"index:4,name:创建时间" gorm:"column:create_time"`
+	Remark     *string   `exi:"index:6" gorm:"column:remark"`
+}
+
+func (exportProduct) TableName() string {
+	return "export_product"
+}
+
+type collectImporter struct {
+	products []*exportProduct
+}
+
+func (c *collectImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	c.products = append(c.products, s.GetModel().(*exportProduct))
+	return nil
+}
+
+func initExportDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "export.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.AutoMigrate(&exportProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestExportFramework_ExportRoundTrip(t *testing.T) {
+	db := initExportDB(t)
+	remark := "limited, \"new\""
+	products := []*exportProduct{
+		{ID: 1, Name: "苹果", Price: 1.5, OnSale: true, CreateTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local), Remark: &remark},
+		{ID: 2, Name: "banana", Price: 0.25, CreateTime: time.Date(2024, 2, 3, 0, 0, 0, 0, time.Local)},
+		{ID: 3, Name: "cherry", Price: 12},
+	}
+	if err := db.Create(products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ext := range []string{".xlsx", ".csv"} {
+		path := filepath.Join(t.TempDir(), "export_product"+ext)
+		framework := NewExportFramework(db.Order("id asc"), &exportProduct{})
+		if err := framework.Export(path); err != nil {
+			t.Fatal(err)
+		}
+
+		// check the header
+		contents, err := util.ReadExcelContent(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedHeader := []string{"编号", "名称", "价格", "在售", "创建时间", "", "Remark"}
+		for i, header := range expectedHeader {
+			if contents[0][i] != header {
+				t.Fatalf("%s header %d is %s, expected %s", ext, i, contents[0][i], header)
+			}
+		}
+
+		// import the exported file
+		importer := &collectImporter{}
+		importFramework := general_framework.NewImporterOneSectionFramework(nil, importer, general_framework.WithSimpleModelFactory(&exportProduct{}))
+		if err = importFramework.Import(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(importer.products) != len(products) {
+			t.Fatalf("%s imported %d products, expected %d", ext, len(importer.products), len(products))
+		}
+		for i, p := range importer.products {
+			expected := products[i]
+			if p.ID != expected.ID || p.Name != expected.Name || p.Price != expected.Price || p.OnSale != expected.OnSale ||
+				!p.CreateTime.Equal(expected.CreateTime) || (p.Remark == nil) != (expected.Remark == nil) ||
+				(p.Remark != nil && *p.Remark != *expected.Remark) {
+				t.Fatalf("%s imported product %+v, expected %+v", ext, p, expected)
+			}
+		}
+	}
+}
+
+func TestExportFramework_ExportQuery(t *testing.T) {
+	db := initExportDB(t)
+	products := []*exportProduct{{ID: 1, Name: "a", Price: 1}, {ID: 2, Name: "b", Price: 2}, {ID: 3, Name: "c", Price: 3}}
+	if err := db.Create(products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export_query.csv")
+	framework := NewExportFramework(db.Table("export_product").Where("price > ?", 1).Order("id desc"), &exportProduct{}, WithoutHeader())
+	if err := framework.Export(path); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := util.ReadExcelContent(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contents) != 2 || contents[0][1] != "c" || contents[1][1] != "b" {
+		t.Fatalf("contents is %v, expected c and b", contents)
+	}
+}
+
+type exportCategory struct {
+	ID       int64  `gorm:"column:id;primaryKey"`
+	ParentID *int64 `gorm:"column:parent_id"`
+	Name     string `gorm:"column:name"`
+	Code     string `gorm:"column:code"`
+}
+
+func (exportCategory) TableName() string {
+	return "export_category"
+}
+
+// exportCategoryModel the model of the tree import, the code of the leaf is after the tree boundary
+type exportCategoryModel struct {
+	L1   string `exi:"index:0"`
+	L2   string `exi:"index:1"`
+	L3   string `exi:"index:2"`
+	Code string `exi:"index:3,name:编码" gorm:"column:code"`
+}
+
+type collectLeafImporter struct {
+	leaves map[string]string
+}
+
+func (c *collectLeafImporter) ImportLevelNode(tx *gorm.DB, node *tree_framework.TreeNode) error {
+	if !node.CheckIsLeaf() {
+		return nil
+	}
+
+	path := node.GetValue()
+	for parent := node.GetParent(); !parent.CheckIsRoot(); parent = parent.GetParent() {
+		path = parent.GetValue() + "/" + path
+	}
+	c.leaves[path] = node.GetItem().(*exportCategoryModel).Code
+	return nil
+}
+
+func TestTreeExportFramework_ExportRoundTrip(t *testing.T) {
+	db := initExportDB(t)
+	if err := db.AutoMigrate(&exportCategory{}); err != nil {
+		t.Fatal(err)
+	}
+	id := func(v int64) *int64 { return &v }
+	categories := []*exportCategory{
+		{ID: 1, Name: "食品"}, {ID: 2, ParentID: id(1), Name: "水果"}, {ID: 3, ParentID: id(2), Name: "苹果", Code: "A1"},
+		{ID: 4, ParentID: id(2), Name: "香蕉", Code: "B2"}, {ID: 5, ParentID: id(1), Name: "蔬菜", Code: "V"},
+		{ID: 6, ParentID: id(0), Name: "日用", Code: "D"},
+	}
+	if err := db.Create(categories).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := &TreeExportMapping{IDColumn: "id", ParentColumn: "parent_id", ValueColumn: "name", LeafModel: &exportCategoryModel{}}
+	for _, ext := range []string{".xlsx", ".csv"} {
+		path := filepath.Join(t.TempDir(), "export_category"+ext)
+		framework := NewTreeExportFramework(db.Table("export_category").Order("id"), mapping, []string{"一级", "二级", "三级"})
+		if err := framework.Export(path); err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := util.ReadExcelContent(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]string{
+			{"一级", "二级", "三级", "编码"},
+			{"食品", "水果", "苹果", "A1"},
+			{"食品", "水果", "香蕉", "B2"},
+			{"食品", "蔬菜", "", "V"},
+			{"日用", "", "", "D"},
+		}
+		for i, row := range expected {
+			for j, cell := range row {
+				if j >= len(contents[i]) && len(cell) > 0 || j < len(contents[i]) && contents[i][j] != cell {
+					t.Fatalf("%s contents are %v, expected %v", ext, contents, expected)
+				}
+			}
+		}
+
+		// import the exported file
+		cfg := &tree_framework.TreeImportCfg{
+			LevelOrder:   []int{0, 1, 2},
+			TreeBoundary: 2,
+			ColumnCount:  4,
+			ModelFac:     util.NewSimpleModelFactory(&exportCategoryModel{}),
+		}
+		ci := &collectLeafImporter{leaves: make(map[string]string)}
+		importFramework := tree_framework.NewTreeImportFramework(nil, cfg, nil, []tree_framework.LevelImporter{ci, ci, ci},
+			tree_framework.WithProgressReporter(util.NewProgressReporter(false)))
+		if err = importFramework.Import(path); err != nil {
+			t.Fatal(err)
+		}
+		leaves := map[string]string{"食品/水果/苹果": "A1", "食品/水果/香蕉": "B2", "食品/蔬菜": "V", "日用": "D"}
+		if !reflect.DeepEqual(ci.leaves, leaves) {
+			t.Fatalf("%s imported leaves %v, expected %v", ext, ci.leaves, leaves)
+		}
+	}
+
+	// the orphan node can't be exported
+	if err := db.Create(&exportCategory{ID: 7, ParentID: id(99), Name: "孤儿"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	framework := NewTreeExportFramework(db.Table("export_category"), mapping, nil)
+	if err := framework.Export(filepath.Join(t.TempDir(), "orphan.csv")); !errors.Is(err, errTreeParentNotFound) {
+		t.Fatalf("got %v, expected the parent not found error", err)
+	}
+}
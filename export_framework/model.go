@@ -0,0 +1,18 @@
+package export_framework
+
+const (
+	defaultSheetName = "Sheet1"
+)
+
+type OptionFunc func(*ExportFramework)
+
+type ExportControl struct {
+	// the sheet name of the xlsx file
+	SheetName string
+	// disable writing the header row
+	WithoutHeader bool
+}
+
+var defaultExportControl = ExportControl{
+	SheetName: defaultSheetName,
+}
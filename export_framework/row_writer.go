@@ -0,0 +1,114 @@
+package export_framework
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rowWriter writes the rows into the file one by one, so that the large content can be streamed.
+type rowWriter interface {
+	// WriteRow write the row after the last written row
+	WriteRow(row []string) error
+	// Close flush the content and close the file
+	Close() error
+}
+
+// newRowWriter create the row writer by the file type, support CSV and XLSX format
+func newRowWriter(path, sheetName string) (rowWriter, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".csv":
+		return newCsvRowWriter(path)
+	case ".xlsx":
+		return newXlsxRowWriter(path, sheetName)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", ext)
+	}
+}
+
+type csvRowWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCsvRowWriter(path string) (*csvRowWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &csvRowWriter{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}, nil
+}
+
+func (c *csvRowWriter) WriteRow(row []string) error {
+	return c.writer.Write(row)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		c.file.Close()
+		return err
+	}
+
+	return c.file.Close()
+}
+
+type xlsxRowWriter struct {
+	path   string
+	f      *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXlsxRowWriter(path, sheetName string) (*xlsxRowWriter, error) {
+	f := excelize.NewFile()
+	if sheetName != f.GetSheetName(0) {
+		if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxRowWriter{
+		path:   path,
+		f:      f,
+		stream: stream,
+	}, nil
+}
+
+func (x *xlsxRowWriter) WriteRow(row []string) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		values[i] = v
+	}
+
+	return x.stream.SetRow(cell, values)
+}
+
+func (x *xlsxRowWriter) Close() error {
+	defer x.f.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+
+	return x.f.SaveAs(x.path)
+}
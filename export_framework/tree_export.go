@@ -0,0 +1,302 @@
+package export_framework
+
+import (
+	"context"
+	"errors"
+	"excel_import"
+	"excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+var (
+	errTreeParentNotFound = errors.New("parent not found")
+	errTreeCycle          = errors.New("the parents form a cycle")
+)
+
+type TreeOptionFunc func(*TreeExportFramework)
+
+// TreeExportMapping the mapping of the hierarchy table
+type TreeExportMapping struct {
+	// the id column of the node
+	IDColumn string
+	// the parent id column of the node, the parent id of the first level is null, empty or 0
+	ParentColumn string
+	// the value column of the node, written into the level column
+	ValueColumn string
+	// the exi tagged model of the leaf, optional. it's scanned from the record of the leaf,
+	// and the fields after the tree boundary are written after the level columns.
+	// usually it's the model of the tree import, so the exported file has the same layout.
+	LeafModel any
+}
+
+// TreeExportFramework exports the hierarchy table into the column per level layout, one row per leaf,
+// so that the exported file can be imported by TreeImportFramework with the level order in the column order.
+type TreeExportFramework struct {
+	query   *gorm.DB
+	mapping *TreeExportMapping
+	// the header names of the levels, the tree boundary is the last level
+	levelNames []string
+	leafTags   []*excel_import.ExcelImportTagAttr
+	control    ExportControl
+}
+
+type exportNode struct {
+	id       string
+	parentID string
+	value    string
+	leaf     any
+	children []*exportNode
+}
+
+func WithTreeControl(control ExportControl) TreeOptionFunc {
+	return func(framework *TreeExportFramework) {
+		framework.control = control
+	}
+}
+
+// NewTreeExportFramework create the tree export framework.
+// query is the gorm query of the hierarchy table, the siblings are written in the order of the query.
+// the level names are the header names of the level columns, the deeper levels than the names have the blank header.
+func NewTreeExportFramework(query *gorm.DB, mapping *TreeExportMapping, levelNames []string, options ...TreeOptionFunc) *TreeExportFramework {
+	if mapping == nil {
+		panic("mapping should not nil")
+	}
+
+	te := &TreeExportFramework{
+		query:      query,
+		mapping:    mapping,
+		levelNames: levelNames,
+		control:    defaultExportControl,
+	}
+	if mapping.LeafModel != nil {
+		v := reflect.ValueOf(mapping.LeafModel)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			panic(errInvalidModel)
+		}
+		te.leafTags = util.ParseTag(mapping.LeafModel)
+	}
+
+	for _, option := range options {
+		option(te)
+	}
+
+	if len(te.control.SheetName) == 0 {
+		te.control.SheetName = defaultSheetName
+	}
+
+	return te
+}
+
+// Export exports the tree into the file, support CSV and XLSX format.
+// the whole tree is loaded before written, since the rows are the paths of the leaves.
+func (e *TreeExportFramework) Export(path string) error {
+	roots, err := e.loadTree()
+	if err != nil {
+		fmt.Printf("load tree failed: %v\n", err)
+		return err
+	}
+
+	writer, err := newRowWriter(path, e.control.SheetName)
+	if err != nil {
+		return err
+	}
+
+	if err = e.export(writer, roots); err != nil {
+		writer.Close()
+		fmt.Printf("export tree failed: %v\n", err)
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (e *TreeExportFramework) export(writer rowWriter, roots []*exportNode) error {
+	depth := 0
+	for _, root := range roots {
+		depth = max(depth, treeDepth(root))
+	}
+	boundary := max(depth, len(e.levelNames)) - 1
+
+	columnCount := boundary + 1
+	for _, tag := range e.leafTags {
+		columnCount = max(columnCount, tag.ColumnIndex+1)
+	}
+
+	// write the header
+	if !e.control.WithoutHeader {
+		header := make([]string, columnCount)
+		copy(header, e.levelNames)
+		if e.mapping.LeafModel != nil {
+			leafHeader := util.ParseHeaderNames(e.mapping.LeafModel)
+			for i := boundary + 1; i < len(leafHeader); i++ {
+				header[i] = leafHeader[i]
+			}
+		}
+		if err := writer.WriteRow(header); err != nil {
+			return err
+		}
+	}
+
+	// write the paths of the leaves in the depth first order
+	var write func(node *exportNode, path []string) error
+	write = func(node *exportNode, path []string) error {
+		path = append(path, node.value)
+		if len(node.children) > 0 {
+			for _, child := range node.children {
+				if err := write(child, path); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		row := make([]string, columnCount)
+		copy(row, path)
+		if err := e.fillLeaf(row, node.leaf, boundary); err != nil {
+			return err
+		}
+		return writer.WriteRow(row)
+	}
+	for _, root := range roots {
+		if err := write(root, make([]string, 0, depth)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fillLeaf fill the fields of the leaf model after the tree boundary into the row
+func (e *TreeExportFramework) fillLeaf(row []string, leaf any, boundary int) error {
+	if leaf == nil {
+		return nil
+	}
+
+	for i, tag := range e.leafTags {
+		if tag.ColumnIndex <= boundary {
+			continue
+		}
+
+		s, err := util.GetFieldString(leaf, i)
+		if err != nil {
+			return err
+		}
+		row[tag.ColumnIndex] = s
+	}
+
+	return nil
+}
+
+// loadTree load the nodes of the table and link them, return the nodes of the first level
+func (e *TreeExportFramework) loadTree() ([]*exportNode, error) {
+	records := make([]map[string]any, 0)
+	if err := e.query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	var leafSchema *schema.Schema
+	if e.mapping.LeafModel != nil {
+		stmt := &gorm.Statement{DB: e.query}
+		if err := stmt.Parse(e.mapping.LeafModel); err != nil {
+			return nil, err
+		}
+		leafSchema = stmt.Schema
+	}
+
+	nodes := make([]*exportNode, 0, len(records))
+	byID := make(map[string]*exportNode, len(records))
+	for _, record := range records {
+		node := &exportNode{
+			id:       recordString(record[e.mapping.IDColumn]),
+			parentID: recordString(record[e.mapping.ParentColumn]),
+			value:    recordString(record[e.mapping.ValueColumn]),
+		}
+		if node.parentID == "0" {
+			node.parentID = ""
+		}
+		if leafSchema != nil {
+			leaf, err := newLeaf(leafSchema, e.mapping.LeafModel, record)
+			if err != nil {
+				return nil, err
+			}
+			node.leaf = leaf
+		}
+
+		nodes = append(nodes, node)
+		byID[node.id] = node
+	}
+
+	roots := make([]*exportNode, 0)
+	for _, node := range nodes {
+		if len(node.parentID) == 0 {
+			roots = append(roots, node)
+			continue
+		}
+
+		parent, ok := byID[node.parentID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s of %s", errTreeParentNotFound, node.parentID, node.id)
+		}
+		parent.children = append(parent.children, node)
+	}
+
+	// the nodes in the cycles aren't reachable from the first level
+	reached := 0
+	for _, root := range roots {
+		reached += treeSize(root)
+	}
+	if reached != len(nodes) {
+		return nil, fmt.Errorf("%w: %d nodes aren't under the first level", errTreeCycle, len(nodes)-reached)
+	}
+
+	return roots, nil
+}
+
+// newLeaf create the leaf model and set the fields by the columns of the record
+func newLeaf(s *schema.Schema, model any, record map[string]any) (any, error) {
+	leaf := util.NewModel(model)
+	rv := reflect.ValueOf(leaf).Elem()
+	for _, field := range s.Fields {
+		value, ok := record[field.DBName]
+		if !ok || len(field.DBName) == 0 {
+			continue
+		}
+		if err := field.Set(context.Background(), rv, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return leaf, nil
+}
+
+func recordString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func treeDepth(node *exportNode) int {
+	depth := 0
+	for _, child := range node.children {
+		depth = max(depth, treeDepth(child))
+	}
+
+	return depth + 1
+}
+
+func treeSize(node *exportNode) int {
+	size := 1
+	for _, child := range node.children {
+		size += treeSize(child)
+	}
+
+	return size
+}
@@ -34,7 +34,7 @@ func (f *FeatureMgr) CheckContents(contents []string, tags []*excel_import.Excel
 	if f.formatCheckStatus == formatCheckTagInit {
 		f.formatCheckStatus = formatCheckTagNotExists
 		for _, tag := range tags {
-			if len(tag.FCF) > 0 {
+			if len(tag.FCF) > 0 || len(tag.Enum) > 0 {
 				f.formatCheckStatus = formatCheckTagExists
 				break
 			}
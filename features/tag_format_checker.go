@@ -34,9 +34,15 @@ func (t *TagFormatChecker) RegisterFormatChecker(fcf excel_import.FormatCheckFun
 
 func (t *TagFormatChecker) CheckContents(content []string, tags []*excel_import.ExcelImportTagAttr) error {
 	errBuilder := util.NewErrBuilder()
-	for i, c := range content {
-		if err := t.checkFormatFunc(tags[i], c); err != nil {
-			errBuilder.AddWithContent(c, err)
+	for _, tag := range tags {
+		// the content of the tag is located by the column index
+		if tag.ColumnIndex < 0 || tag.ColumnIndex >= len(content) {
+			continue
+		}
+
+		c := content[tag.ColumnIndex]
+		if err := t.checkFormatFunc(tag, c); err != nil {
+			errBuilder.AddCellWithContent(tag.ColumnIndex, c, err)
 		}
 	}
 	return errBuilder.Build()
@@ -44,8 +50,20 @@ func (t *TagFormatChecker) CheckContents(content []string, tags []*excel_import.
 
 // checkFormatFunc checks the type of the given string.
 func (t *TagFormatChecker) checkFormatFunc(tag *excel_import.ExcelImportTagAttr, str string) error {
-	// if tcf is not set or str is empty, return true
-	if len(tag.FCF) == 0 || len(str) == 0 {
+	// if str is empty, return true
+	if len(str) == 0 {
+		return nil
+	}
+
+	// check the allowed values if enum is set
+	if len(tag.Enum) > 0 {
+		if err := util.CheckIsEnum(str, tag.Enum); err != nil {
+			return err
+		}
+	}
+
+	// if tcf is not set, return true
+	if len(tag.FCF) == 0 {
 		return nil
 	}
 
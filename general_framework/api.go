@@ -34,3 +34,19 @@ type GeneralMiddleware interface {
 	SectionImportPostHandler
 	excel_import.PostHandler
 }
+
+// BatchFlushPreHandler is implemented by the middlewares which handle the pending effects,
+// e.g. snapshot the records before they are updated by the batch.
+type BatchFlushPreHandler interface {
+	// PreBatchFlushHandle pre handle before the batch sqls are executed
+	PreBatchFlushHandle(tx *gorm.DB) error
+}
+
+// SectionUpdateObserver is implemented by the middlewares which observe the updates executed by the importers,
+// e.g. refuse the update whose previous values aren't recorded, since the middlewares only see the effects.
+// the updates of the gorm models, the upserts and the raw update sqls are observed.
+type SectionUpdateObserver interface {
+	// PreSectionUpdate pre handle before the update of the importer is executed, the error aborts the update.
+	// the table is empty if unknown, e.g. the raw sql.
+	PreSectionUpdate(tx *gorm.DB, s *RawContent, table string) error
+}
@@ -12,8 +12,13 @@ import (
 // It will cache sqls until the batch size is reached, then execute them.
 // unlike other middlewares, this is an in-framework middleware.
 type batchSupportFeature struct {
-	BatchSize int
-	contents  []string
+	BatchSize   int
+	contents    []string
+	retryPolicy *util.RetryPolicy
+	onRetry     util.OnRetryFunc
+	rateLimiter *util.RateLimiter
+	// called before the batch is executed
+	preFlushHandlers []BatchFlushPreHandler
 }
 
 func newBatchSupportFeature(batchSize int) *batchSupportFeature {
@@ -27,6 +32,22 @@ func newBatchSupportFeature(batchSize int) *batchSupportFeature {
 	}
 }
 
+// setRetryPolicy set the retry policy of the batch execution
+func (b *batchSupportFeature) setRetryPolicy(policy *util.RetryPolicy, onRetry util.OnRetryFunc) {
+	b.retryPolicy = policy
+	b.onRetry = onRetry
+}
+
+// setRateLimiter set the rate limiter of the batch execution
+func (b *batchSupportFeature) setRateLimiter(limiter *util.RateLimiter) {
+	b.rateLimiter = limiter
+}
+
+// setPreFlushHandlers set the handlers which are called before the batch is executed
+func (b *batchSupportFeature) setPreFlushHandlers(handlers []BatchFlushPreHandler) {
+	b.preFlushHandlers = handlers
+}
+
 // AddModel add a model to the batch.
 func (b *batchSupportFeature) AddModel(tx *gorm.DB, model any) error {
 	tableName, err := getModelTableName(model)
@@ -74,15 +95,23 @@ func (b *batchSupportFeature) executeBatch(tx *gorm.DB) error {
 		return nil
 	}
 
+	for _, handler := range b.preFlushHandlers {
+		if err := handler.PreBatchFlushHandle(tx); err != nil {
+			return err
+		}
+	}
+
 	sqls := b.contents
 	b.contents = b.contents[:0]
 	sql := strings.Join(sqls, "\n")
 
-	if err := tx.Exec(sql).Error; err != nil {
-		return err
-	}
+	_, err := b.retryPolicy.Run(tx, func(tx *gorm.DB) error {
+		return b.rateLimiter.Run(len(sqls), len(sqls), func() error {
+			return tx.Exec(sql).Error
+		})
+	}, b.onRetry)
 
-	return nil
+	return err
 }
 
 func (b *batchSupportFeature) PreImportHandle(tx *gorm.DB, whole *RawWhole) error {
@@ -4,25 +4,35 @@ import (
 	"excel_import"
 	util "excel_import/utils"
 	"gorm.io/gorm"
+	"sync"
 )
 
 type ExcelRewriterMiddleware struct {
-	path     string
-	contents map[int][]string
+	path       string
+	outputPath string
+	// column index -> row -> content
+	contents map[int]map[int]string
 	attrs    []*excel_import.ExcelImportTagAttr
-	startRow int
+	mu       sync.Mutex
 }
 
 func NewExcelRewriterMiddleware(path string) *ExcelRewriterMiddleware {
 	return &ExcelRewriterMiddleware{
-		path:     path,
-		contents: make(map[int][]string),
-		startRow: 1,
+		path:       path,
+		outputPath: path,
+		contents:   make(map[int]map[int]string),
 	}
 }
 
+// SetStartRow set the start row of the content.
+// Deprecated: the rewritten content is written by the original row of RawContent.
 func (e *ExcelRewriterMiddleware) SetStartRow(startRow int) {
-	e.startRow = startRow
+}
+
+// SetOutputPath set the output path of the rewritten file.
+// the source file is overwritten if not set.
+func (e *ExcelRewriterMiddleware) SetOutputPath(outputPath string) {
+	e.outputPath = outputPath
 }
 
 func (e *ExcelRewriterMiddleware) PreImportHandle(tx *gorm.DB, whole *RawWhole) error {
@@ -39,10 +49,15 @@ func (e *ExcelRewriterMiddleware) PreImportHandle(tx *gorm.DB, whole *RawWhole)
 	return nil
 }
 
+// PostImportSectionHandle record the rewrite content keyed by the original row.
+// thread safe.
 func (e *ExcelRewriterMiddleware) PostImportSectionHandle(tx *gorm.DB, s *RawContent) error {
 	// get models
 	model := s.GetModel()
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	// iterate models and write to content
 	for i, attr := range e.attrs {
 		if !attr.Rewrite || attr.ColumnIndex < 0 {
@@ -55,7 +70,10 @@ func (e *ExcelRewriterMiddleware) PostImportSectionHandle(tx *gorm.DB, s *RawCon
 			return err
 		}
 
-		e.contents[attr.ColumnIndex] = append(e.contents[attr.ColumnIndex], c)
+		if _, ok := e.contents[attr.ColumnIndex]; !ok {
+			e.contents[attr.ColumnIndex] = make(map[int]string)
+		}
+		e.contents[attr.ColumnIndex][s.GetRow()] = c
 	}
 
 	return nil
@@ -63,7 +81,7 @@ func (e *ExcelRewriterMiddleware) PostImportSectionHandle(tx *gorm.DB, s *RawCon
 
 func (e *ExcelRewriterMiddleware) PostHandle(tx *gorm.DB) error {
 	// write to excel
-	if err := util.WriteExcelColumnContentByStartRow(e.path, e.contents, e.startRow); err != nil {
+	if err := util.WriteExcelCellContentTo(e.path, e.outputPath, e.contents); err != nil {
 		return err
 	}
 
@@ -28,6 +28,8 @@ type ImportFramework struct {
 	progressReporter *util.ProgressReporter
 	middlewares      []GeneralMiddleware
 	correctCheckers  []excel_import.CorrectnessChecker
+	// the middlewares which observe the updates of the importers
+	updateObservers []SectionUpdateObserver
 
 	featureMgr *features.FeatureMgr
 }
@@ -90,12 +92,66 @@ func WithRowFilter(filter excel_import.RowFilter) OptionFunc {
 	}
 }
 
+func WithStartRow(row int) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.StartRow = row
+	}
+}
+
+// WithSheetName only read the sheet of the xlsx file
+func WithSheetName(sheet string) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.SheetName = sheet
+	}
+}
+
 func WithEndFunc(ef excel_import.EndFunc) OptionFunc {
 	return func(framework *ImportFramework) {
 		framework.control.Ef = ef
 	}
 }
 
+func WithRecorder(recorder *util.UnexpectedRecorder) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.recorder = recorder
+	}
+}
+
+func WithProgressReporter(progressReporter *util.ProgressReporter) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.progressReporter = progressReporter
+	}
+}
+
+func WithRowOffset(offset int) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.RowOffset = offset
+	}
+}
+
+func WithRetryPolicy(policy *util.RetryPolicy) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.RetryPolicy = policy
+	}
+}
+
+func WithRateLimiter(limiter *util.RateLimiter) OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.RateLimiter = limiter
+	}
+}
+
+func WithDryRun() OptionFunc {
+	return func(framework *ImportFramework) {
+		framework.control.DryRun = true
+	}
+}
+
+// CheckDryRun check if the import only parses and checks the file
+func (k *ImportFramework) CheckDryRun() bool {
+	return k.control.DryRun
+}
+
 func NewImporterFramework(db *gorm.DB, importers map[RowType]SectionImporter, recognizer SectionRecognizer, options ...OptionFunc) *ImportFramework {
 	ki := &ImportFramework{
 		db:               db,
@@ -112,7 +168,10 @@ func NewImporterFramework(db *gorm.DB, importers map[RowType]SectionImporter, re
 	}
 
 	if ki.control.EnableBatch {
-		ki.middlewares = append(ki.middlewares, newBatchSupportFeature(ki.control.BatchSize))
+		batchFeature := newBatchSupportFeature(ki.control.BatchSize)
+		batchFeature.setRetryPolicy(ki.control.RetryPolicy, ki.onRetry)
+		batchFeature.setRateLimiter(ki.control.RateLimiter)
+		ki.middlewares = append(ki.middlewares, batchFeature)
 	}
 
 	ki.featureMgr.EnableTagFormatChecker()
@@ -151,9 +210,23 @@ func NewImporterOneSectionFramework(db *gorm.DB, importer SectionImporter, optio
 }
 
 func (k *ImportFramework) Import(path string) error {
+	k.registerRecordObservers()
+	k.registerBatchFlushPreHandlers()
 	defer k.recorder.Flush()
 	defer k.progressReporter.Report()
 
+	if err := k.registerSectionUpdateObservers(); err != nil {
+		fmt.Printf("register section update observers failed: %v\n", err)
+		return err
+	}
+
+	if k.checkAllowImportParallel() {
+		if err := k.control.RetryPolicy.CheckParallel(k.db); err != nil {
+			fmt.Printf("check retry policy failed: %v\n", err)
+			return err
+		}
+	}
+
 	content, err := k.parseContent(path)
 	if err != nil {
 		fmt.Printf("read file content failed: %v\n", err)
@@ -165,6 +238,10 @@ func (k *ImportFramework) Import(path string) error {
 		return err
 	}
 
+	if k.control.DryRun {
+		return nil
+	}
+
 	for _, middleware := range k.middlewares {
 		if err = middleware.PreImportHandle(k.db, content); err != nil {
 			fmt.Printf("middleware pre handle failed: %v\n", err)
@@ -192,8 +269,36 @@ func (k *ImportFramework) Import(path string) error {
 	return nil
 }
 
+// registerRecordObservers register the middlewares which observe the recorded errors
+func (k *ImportFramework) registerRecordObservers() {
+	for _, middleware := range k.middlewares {
+		if observer, ok := middleware.(util.RecordObserver); ok {
+			k.recorder.AddObserver(observer)
+		}
+	}
+}
+
+// registerBatchFlushPreHandlers register the middlewares which handle the pending effects before the batch is executed
+func (k *ImportFramework) registerBatchFlushPreHandlers() {
+	var batchFeature *batchSupportFeature
+	handlers := make([]BatchFlushPreHandler, 0)
+	for _, middleware := range k.middlewares {
+		if feature, ok := middleware.(*batchSupportFeature); ok {
+			batchFeature = feature
+			continue
+		}
+		if handler, ok := middleware.(BatchFlushPreHandler); ok {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	if batchFeature != nil {
+		batchFeature.setPreFlushHandlers(handlers)
+	}
+}
+
 func (k *ImportFramework) parseContent(path string) (*RawWhole, error) {
-	content, err := util.ReadExcelContent(path)
+	content, err := util.ReadExcelSheetContent(path, k.control.SheetName)
 	if err != nil {
 		return nil, err
 	}
@@ -204,8 +309,8 @@ func (k *ImportFramework) parseContent(path string) (*RawWhole, error) {
 }
 
 func (k *ImportFramework) preHandleRawContent(contents [][]string) [][]string {
-	// skip the header default
-	contents = contents[k.control.StartRow:]
+	// skip the header default, the start row may be after the content
+	contents = contents[min(k.control.StartRow, len(contents)):]
 
 	// end row with func
 	if k.control.Ef != nil {
@@ -271,7 +376,7 @@ func (k *ImportFramework) parseRawWhole(contents [][]string) (*RawWhole, error)
 			SectionType: sectionType,
 			Content:     content,
 			Model:       model,
-			Row:         i + k.control.StartRow,
+			Row:         i + k.control.StartRow + k.control.RowOffset,
 			whole:       whole,
 		})
 	}
@@ -385,9 +490,22 @@ func (k *ImportFramework) importContentSerial(whole *RawWhole) error {
 
 func (k *ImportFramework) importSection(importer SectionImporter, content *RawContent) error {
 	status := util.ProgressStatusSuccess
-	defer k.progressReporter.CommitProgress(1, status)
+	defer func() {
+		k.progressReporter.CommitProgress(1, status)
+	}()
+
+	// the batch feature limits the rows when flushing
+	limiter := k.control.RateLimiter
+	if k.control.EnableBatch {
+		limiter = nil
+	}
 
-	if err := importer.ImportSection(k.db, content); err != nil {
+	_, err := k.control.RetryPolicy.Run(k.db, func(tx *gorm.DB) error {
+		return limiter.Run(1, 1, func() error {
+			return importer.ImportSection(k.sectionTx(tx, content), content)
+		})
+	}, k.onRetry)
+	if err != nil {
 		status = util.ProgressStatusFailed
 		fmt.Printf("import row %d section failed: %v\n", content.GetRow(), err)
 		k.recorder.RecordImportError(util.CombineErrors(content.GetRow(), err))
@@ -405,6 +523,12 @@ func (k *ImportFramework) importSection(importer SectionImporter, content *RawCo
 	return nil
 }
 
+// onRetry count the retried attempt into the progress
+func (k *ImportFramework) onRetry(attempt int, err error) {
+	fmt.Printf("attempt %d failed, retrying: %v\n", attempt, err)
+	k.progressReporter.CommitRetry(1)
+}
+
 func (k *ImportFramework) checkAllowImportParallel() bool {
 	return k.control.EnableParallel && k.control.MaxParallel > 1
 }
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"excel_import/correct_checker"
 	util "excel_import/utils"
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -408,3 +411,107 @@ type doNothingImporter struct {
 func (di *doNothingImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
 	return nil
 }
+
+func TestImportFramework_ImportOneSectionWithRewriteFilteredRows(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rewrite_filtered.xlsx")
+	outputPath := filepath.Join(dir, "rewrite_filtered_output.xlsx")
+	contents := [][]string{
+		{"X", "Y", "Sum"},
+		{"1", "1", ""},
+		{"skip", "", ""},
+		{"2", "3", ""},
+		{"5", "7", ""},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	rewriteMiddleware := NewExcelRewriterMiddleware(path)
+	rewriteMiddleware.SetOutputPath(outputPath)
+	filter := func(s []string) bool {
+		return s[0] == "skip"
+	}
+
+	framework := NewImporterOneSectionFramework(nil, &simpleTestDataSupportMiddlewareImporter{}, WithRowRawModel(&calculateExampleFac{}),
+		WithMiddlewares(rewriteMiddleware), WithRowFilter(filter))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the filtered row keeps empty, and the other rows get its own sum
+	expectedSum := []string{"Sum", "2", "", "5", "12"}
+	rewriteColIndex := 2
+	content, err := util.ReadExcelContent(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, s := range expectedSum {
+		if content[i][rewriteColIndex] != s {
+			t.Fatalf("row %d sum is %s, expected %s", i, content[i][rewriteColIndex], s)
+		}
+	}
+
+	// the source file should not be overwritten
+	source, err := util.ReadExcelContent(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source[1][rewriteColIndex] != "" {
+		t.Fatalf("source file should not be rewritten, got %s", source[1][rewriteColIndex])
+	}
+}
+
+type flakyImporter struct {
+	failures map[string]int
+}
+
+func (fi *flakyImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
+	name := s.GetModel().(*shardedExcelModel).Name
+	if fi.failures[name] > 0 {
+		fi.failures[name]--
+		return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	}
+
+	return nil
+}
+
+func TestImportFramework_ImportOneSectionWithRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"名称", "备注"}, {"name0", ""}, {"name1", ""}, {"name2", ""}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := util.NewProgressReporter(false)
+	policy := &util.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	importer := &flakyImporter{failures: map[string]int{"name0": 1, "name2": 2}}
+	framework := NewImporterOneSectionFramework(nil, importer, WithSimpleModelFactory(&shardedExcelModel{}),
+		WithRetryPolicy(policy), WithProgressReporter(reporter))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if reporter.GetSuccess() != 3 || reporter.GetFailed() != 0 || reporter.GetRetry() != 3 {
+		t.Fatalf("success %d, failed %d, retry %d", reporter.GetSuccess(), reporter.GetFailed(), reporter.GetRetry())
+	}
+}
+
+func TestImportFramework_ImportStartRowAfterContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "start_row.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"名称", "备注"}, {"name0", ""}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the start row after the content imports nothing
+	reporter := util.NewProgressReporter(false)
+	importer := &flakyImporter{failures: map[string]int{}}
+	framework := NewImporterOneSectionFramework(nil, importer, WithSimpleModelFactory(&shardedExcelModel{}),
+		WithStartRow(5), WithProgressReporter(reporter))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+	if reporter.GetTotal() != 0 {
+		t.Fatalf("got %d rows, expected none", reporter.GetTotal())
+	}
+}
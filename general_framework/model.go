@@ -23,6 +23,11 @@ type ModelsInfo struct {
 	excelModelTags []*excel_import.ExcelImportTagAttr
 }
 
+// GetRawContents get the parsed contents
+func (r *RawWhole) GetRawContents() []*RawContent {
+	return r.rawContents
+}
+
 func (r *RawWhole) GetModelTags() []*excel_import.ExcelImportTagAttr {
 	return r.modelInfo.excelModelTags
 }
@@ -119,6 +124,20 @@ type ImportControl struct {
 	BatchSize int
 	// the row filter function
 	RowFilter excel_import.RowFilter
+	// the offset added to the row number.
+	// used when the file is a part of the original file, so the original row number is kept.
+	RowOffset int
+	// the retry policy of the transient db errors in the section import and the batch flush.
+	// no retry if nil
+	RetryPolicy *util.RetryPolicy
+	// the write rate limiter of the section import and the batch flush. no limit if nil.
+	// the section counts as one row and one statement,
+	// and the rows are limited when the batch is flushed if the batch is enabled.
+	RateLimiter *util.RateLimiter
+	// only parse and check the content, the importers, the middlewares and the post handlers aren't called
+	DryRun bool
+	// the sheet of the xlsx file, all the sheets are read if empty
+	SheetName string
 }
 
 var defaultImportControl = ImportControl{
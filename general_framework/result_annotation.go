@@ -0,0 +1,44 @@
+package general_framework
+
+import (
+	util "excel_import/utils"
+	"gorm.io/gorm"
+)
+
+// ResultAnnotationMiddleware annotates the import result of every row into the returned workbook.
+// the imported rows are marked success with the new id, and the errors are taken from the recorder,
+// so the workbook is written when the recorder is flushed, even if the check failed.
+type ResultAnnotationMiddleware struct {
+	*util.ResultAnnotator
+	successFormatter func(rc *RawContent) string
+}
+
+func NewResultAnnotationMiddleware(path string) *ResultAnnotationMiddleware {
+	return &ResultAnnotationMiddleware{
+		ResultAnnotator:  util.NewResultAnnotator(path),
+		successFormatter: defaultSuccessFormatter,
+	}
+}
+
+// SetSuccessFormatter set the formatter of the success result.
+func (r *ResultAnnotationMiddleware) SetSuccessFormatter(f func(rc *RawContent) string) {
+	r.successFormatter = f
+}
+
+// defaultSuccessFormatter show the id of the inserted model if exists
+func defaultSuccessFormatter(rc *RawContent) string {
+	return util.SuccessMessage(util.GetModelID(rc.GetInsertModel()))
+}
+
+func (r *ResultAnnotationMiddleware) PreImportHandle(tx *gorm.DB, whole *RawWhole) error {
+	return nil
+}
+
+func (r *ResultAnnotationMiddleware) PostImportSectionHandle(tx *gorm.DB, rc *RawContent) error {
+	r.MarkSuccess([]int{rc.GetRow()}, r.successFormatter(rc))
+	return nil
+}
+
+func (r *ResultAnnotationMiddleware) PostHandle(tx *gorm.DB) error {
+	return nil
+}
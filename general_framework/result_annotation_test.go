@@ -0,0 +1,122 @@
+package general_framework
+
+import (
+	util "excel_import/utils"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type annotationExcelModel struct {
+	Name  string `exi:"index:0,fcf:cn"`
+	Price string `exi:"index:1,fcf:float"`
+}
+
+type annotationInsertModel struct {
+	ID   int64
+	Name string
+}
+
+type annotationImporter struct {
+	nextID int64
+}
+
+func (ai *annotationImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
+	ai.nextID++
+	model := s.GetModel().(*annotationExcelModel)
+	s.SetInsertModel(&annotationInsertModel{ID: ai.nextID, Name: model.Name})
+	return nil
+}
+
+func TestResultAnnotationMiddleware_Success(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "annotation_success.xlsx")
+	if err := util.WriteExcelContent(path, [][]string{{"名称", "价格"}, {"苹果", "1.5"}, {"香蕉", "2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	annotation := NewResultAnnotationMiddleware(path)
+	framework := NewImporterOneSectionFramework(nil, &annotationImporter{}, WithSimpleModelFactory(&annotationExcelModel{}), WithMiddlewares(annotation))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(annotation.GetOutputPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	expected := map[string]string{"C1": "导入结果", "C2": "成功, ID: 1", "C3": "成功, ID: 2"}
+	for cell, value := range expected {
+		got, err := f.GetCellValue(f.GetSheetName(0), cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != value {
+			t.Fatalf("cell %s is %s, expected %s", cell, got, value)
+		}
+	}
+}
+
+func TestResultAnnotationMiddleware_CheckFailed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "annotation_failed.csv")
+	outputPath := filepath.Join(dir, "annotation_failed_result.xlsx")
+	if err := util.WriteExcelContent(path, [][]string{{"名称", "价格"}, {"苹果", "1.5"}, {"apple", "abc"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	annotation := NewResultAnnotationMiddleware(path)
+	annotation.SetOutputPath(outputPath)
+	framework := NewImporterOneSectionFramework(nil, &annotationImporter{}, WithSimpleModelFactory(&annotationExcelModel{}), WithMiddlewares(annotation))
+	if err := framework.Import(path); err == nil {
+		t.Fatal("expected check error")
+	}
+
+	f, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+
+	// the failed cells are highlighted and commented
+	comments, err := f.GetComments(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commented := make(map[string]string)
+	for _, comment := range comments {
+		commented[comment.Cell] = comment.Text
+	}
+	if !strings.Contains(commented["A3"], util.ErrInvalidChinese.Error()) {
+		t.Fatalf("comment of A3 is %s, expected %s", commented["A3"], util.ErrInvalidChinese.Error())
+	}
+	if _, ok := commented["B3"]; !ok {
+		t.Fatal("comment of B3 is missing")
+	}
+	if _, ok := commented["A2"]; ok {
+		t.Fatal("comment of A2 is unexpected")
+	}
+
+	for _, cell := range []string{"A3", "B3", "C3"} {
+		style, err := f.GetCellStyle(sheet, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style == 0 {
+			t.Fatalf("cell %s is not highlighted", cell)
+		}
+	}
+
+	result, err := f.GetCellValue(sheet, "C3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "A3") || !strings.Contains(result, "B3") {
+		t.Fatalf("result is %s, expected the failed cells", result)
+	}
+}
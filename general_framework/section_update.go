@@ -0,0 +1,112 @@
+package general_framework
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"sync"
+)
+
+const sectionUpdateCallback = "excel_import:section_update"
+
+// guard the registration of the callbacks, which are shared by the sessions of the db
+var sectionUpdateMu sync.Mutex
+
+type sectionCtxKey struct{}
+
+// sectionCtx the section imported by the tx, carried in the context
+type sectionCtx struct {
+	content   *RawContent
+	observers []SectionUpdateObserver
+}
+
+// GetSectionContent get the section imported by the tx, nil if the tx isn't passed into the importer
+// or no middleware observes the updates
+func GetSectionContent(tx *gorm.DB) *RawContent {
+	if tx == nil || tx.Statement.Context == nil {
+		return nil
+	}
+
+	sc, ok := tx.Statement.Context.Value(sectionCtxKey{}).(*sectionCtx)
+	if !ok || sc == nil {
+		return nil
+	}
+
+	return sc.content
+}
+
+// registerSectionUpdateObservers register the middlewares which observe the updates executed by the importers,
+// the callbacks are registered into the db once.
+func (k *ImportFramework) registerSectionUpdateObservers() error {
+	k.updateObservers = nil
+	for _, middleware := range k.middlewares {
+		if observer, ok := middleware.(SectionUpdateObserver); ok {
+			k.updateObservers = append(k.updateObservers, observer)
+		}
+	}
+	if len(k.updateObservers) == 0 || k.db == nil {
+		return nil
+	}
+
+	sectionUpdateMu.Lock()
+	defer sectionUpdateMu.Unlock()
+
+	callback := k.db.Callback()
+	if callback.Update().Get(sectionUpdateCallback) != nil {
+		return nil
+	}
+
+	if err := callback.Update().Before("gorm:update").Register(sectionUpdateCallback, func(tx *gorm.DB) {
+		observeSectionUpdate(tx, tx.Statement.Table)
+	}); err != nil {
+		return err
+	}
+	// the upsert updates the conflicted records
+	if err := callback.Create().Before("gorm:create").Register(sectionUpdateCallback, func(tx *gorm.DB) {
+		if c, ok := tx.Statement.Clauses["ON CONFLICT"]; ok {
+			if onConflict, ok := c.Expression.(clause.OnConflict); ok && (onConflict.UpdateAll || len(onConflict.DoUpdates) > 0) {
+				observeSectionUpdate(tx, tx.Statement.Table)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	// the table of the raw sql is unknown
+	return callback.Raw().Before("gorm:raw").Register(sectionUpdateCallback, func(tx *gorm.DB) {
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(tx.Statement.SQL.String())), "UPDATE") {
+			observeSectionUpdate(tx, "")
+		}
+	})
+}
+
+// sectionTx carry the section in the context of the tx passed into the importer, so its updates are observed
+func (k *ImportFramework) sectionTx(tx *gorm.DB, content *RawContent) *gorm.DB {
+	if len(k.updateObservers) == 0 || tx == nil {
+		return tx
+	}
+
+	return tx.WithContext(context.WithValue(tx.Statement.Context, sectionCtxKey{},
+		&sectionCtx{content: content, observers: k.updateObservers}))
+}
+
+// observeSectionUpdate call the observers before the update statement of the importer is executed,
+// the error of the observers aborts the statement
+func observeSectionUpdate(tx *gorm.DB, table string) {
+	if tx.Error != nil {
+		return
+	}
+	sc, ok := tx.Statement.Context.Value(sectionCtxKey{}).(*sectionCtx)
+	if !ok || sc == nil {
+		return
+	}
+
+	// the observers query by the new session, whose statements aren't observed
+	session := tx.Session(&gorm.Session{NewDB: true}).WithContext(context.WithValue(tx.Statement.Context, sectionCtxKey{}, nil))
+	for _, observer := range sc.observers {
+		if err := observer.PreSectionUpdate(session, sc.content, table); err != nil {
+			_ = tx.AddError(err)
+			return
+		}
+	}
+}
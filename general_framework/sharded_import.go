@@ -0,0 +1,245 @@
+package general_framework
+
+import (
+	"errors"
+	"excel_import/utils"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	shardFileName       = "shard_%d.csv"
+	shardCheckFailed    = "shard_%d_check_failed.csv"
+	shardImportFailed   = "shard_%d_import_failed.csv"
+	shardUnexpectedJson = "shard_%d_unexpected.jsonl"
+	shardTempDirPattern = "sharded_import_"
+)
+
+// FrameworkFactory create the framework which imports the shard.
+// a new framework must be created for every shard, since the framework keeps the import state.
+type FrameworkFactory func(shard int) *ImportFramework
+
+type ShardedOptionFunc func(*ShardedImportRunner)
+
+// ShardedImportRunner splits the file into shards and imports the shards concurrently.
+// every shard is imported by its own framework with its own recorder,
+// the row numbers of the shards are kept as the original file,
+// and the recorded files of the shards are merged into the recorder of the runner.
+//
+// the shards are imported independently, so the check failure of a shard doesn't stop the others.
+type ShardedImportRunner struct {
+	factory     FrameworkFactory
+	shardCount  int
+	maxParallel int
+	recorder    *util.UnexpectedRecorder
+	tempDir     string
+}
+
+// ShardResult the import result of the shard
+type ShardResult struct {
+	Index int
+	// the original rows of the shard, [StartRow, EndRow)
+	StartRow, EndRow int
+	// the success and failed count of the imported rows
+	Success, Failed int
+	// the retried attempts
+	Retry int
+	// the import error of the shard
+	Err error
+}
+
+// ShardedImportReport the merged import result of the shards
+type ShardedImportReport struct {
+	Total, Success, Failed, Retry int
+	Shards                        []*ShardResult
+}
+
+func (r *ShardedImportReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Sharded Import: Total: %d, Success: %d, Failed: %d, Retry: %d \n", r.Total, r.Success, r.Failed, r.Retry))
+	for _, shard := range r.Shards {
+		sb.WriteString(fmt.Sprintf("Shard %d, Rows: %d-%d, Success: %d, Failed: %d, Retry: %d", shard.Index, shard.StartRow+1, shard.EndRow, shard.Success, shard.Failed, shard.Retry))
+		if shard.Err != nil {
+			sb.WriteString(fmt.Sprintf(", Error: %v", shard.Err))
+		}
+		sb.WriteString(" \n")
+	}
+
+	return sb.String()
+}
+
+// WithShardCount set the count of the shards, the cpu count by default
+func WithShardCount(count int) ShardedOptionFunc {
+	return func(runner *ShardedImportRunner) {
+		runner.shardCount = count
+	}
+}
+
+// WithShardMaxParallel set the max count of the shards imported at the same time, the shard count by default
+func WithShardMaxParallel(maxParallel int) ShardedOptionFunc {
+	return func(runner *ShardedImportRunner) {
+		runner.maxParallel = maxParallel
+	}
+}
+
+// WithShardRecorder set the recorder which the recorded files of the shards are merged into
+func WithShardRecorder(recorder *util.UnexpectedRecorder) ShardedOptionFunc {
+	return func(runner *ShardedImportRunner) {
+		runner.recorder = recorder
+	}
+}
+
+// WithShardTempDir set the directory of the shard files, the default temp directory by default
+func WithShardTempDir(dir string) ShardedOptionFunc {
+	return func(runner *ShardedImportRunner) {
+		runner.tempDir = dir
+	}
+}
+
+func NewShardedImportRunner(factory FrameworkFactory, options ...ShardedOptionFunc) *ShardedImportRunner {
+	r := &ShardedImportRunner{
+		factory:    factory,
+		shardCount: runtime.NumCPU(),
+		recorder:   util.NewDefaultUnexpectedRecorder(),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	r.shardCount = max(r.shardCount, 1)
+	if r.maxParallel <= 0 {
+		r.maxParallel = r.shardCount
+	}
+
+	return r
+}
+
+// Run split the file into shards and import them concurrently.
+// the error is joined by the errors of the failed shards.
+func (r *ShardedImportRunner) Run(path string) (*ShardedImportReport, error) {
+	content, err := util.ReadExcelContent(path)
+	if err != nil {
+		fmt.Printf("read file content failed: %v\n", err)
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(r.tempDir, shardTempDirPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// the control of the first framework decides how to split the content
+	first := r.factory(0)
+	header, body := r.splitHeader(first.control, content)
+	ranges := splitShardRanges(len(body), r.shardCount)
+
+	frameworks := make([]*ImportFramework, len(ranges))
+	recorders := make([]*util.UnexpectedRecorder, len(ranges))
+	reporters := make([]*util.ProgressReporter, len(ranges))
+	paths := make([]string, len(ranges))
+	for i, rg := range ranges {
+		paths[i] = filepath.Join(dir, fmt.Sprintf(shardFileName, i))
+		shardContent := append(append([][]string{}, header...), body[rg[0]:rg[1]]...)
+		if err = util.WriteExcelContent(paths[i], shardContent); err != nil {
+			return nil, err
+		}
+
+		framework := first
+		if i > 0 {
+			framework = r.factory(i)
+		}
+
+		recorders[i] = util.NewUnexpectedRecorder(filepath.Join(dir, fmt.Sprintf(shardCheckFailed, i)),
+			filepath.Join(dir, fmt.Sprintf(shardImportFailed, i)), filepath.Join(dir, fmt.Sprintf(shardUnexpectedJson, i)))
+		reporters[i] = util.NewProgressReporter(false)
+		frameworks[i] = framework.WithOption(WithRecorder(recorders[i])).
+			WithOption(WithProgressReporter(reporters[i])).
+			WithOption(WithRowOffset(rg[0]))
+	}
+
+	// import the shards concurrently
+	report := &ShardedImportReport{Shards: make([]*ShardResult, len(ranges))}
+	var eg errgroup.Group
+	eg.SetLimit(r.maxParallel)
+	for i, rg := range ranges {
+		result := &ShardResult{
+			Index:    i,
+			StartRow: rg[0] + len(header),
+			EndRow:   rg[1] + len(header),
+		}
+		report.Shards[i] = result
+
+		framework, shardPath := frameworks[i], paths[i]
+		eg.Go(func() error {
+			result.Err = framework.Import(shardPath)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	// merge the results of the shards
+	if err = r.recorder.Merge(recorders...); err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for i, result := range report.Shards {
+		result.Success = reporters[i].GetSuccess()
+		result.Failed = reporters[i].GetFailed()
+		result.Retry = reporters[i].GetRetry()
+		report.Total += result.EndRow - result.StartRow
+		report.Success += result.Success
+		report.Failed += result.Failed
+		report.Retry += result.Retry
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", i, result.Err))
+		}
+	}
+
+	fmt.Print(report.String())
+	return report, errors.Join(errs...)
+}
+
+// splitHeader split the content into the header and the body which is cut by the end func
+func (r *ShardedImportRunner) splitHeader(control ImportControl, content [][]string) ([][]string, [][]string) {
+	if len(content) <= control.StartRow {
+		return content, nil
+	}
+
+	header, body := content[:control.StartRow], content[control.StartRow:]
+	if control.Ef != nil {
+		for i, row := range body {
+			if control.Ef(row) {
+				body = body[:i]
+				break
+			}
+		}
+	}
+
+	return header, body
+}
+
+// splitShardRanges split the rows into count ranges evenly, the empty ranges are skipped
+func splitShardRanges(rows, count int) [][2]int {
+	count = min(count, rows)
+
+	ranges := make([][2]int, 0, count)
+	start := 0
+	for i := 0; i < count; i++ {
+		size := rows / count
+		if i < rows%count {
+			size++
+		}
+
+		ranges = append(ranges, [2]int{start, start + size})
+		start += size
+	}
+
+	return ranges
+}
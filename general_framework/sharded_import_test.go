@@ -0,0 +1,93 @@
+package general_framework
+
+import (
+	"errors"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type shardedExcelModel struct {
+	Name   string `exi:"index:0"`
+	Remark string `exi:"index:1"`
+}
+
+type shardedImporter struct {
+	failed   map[string]bool
+	imported *sync.Map
+}
+
+func (si *shardedImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
+	name := s.GetModel().(*shardedExcelModel).Name
+	if si.failed[name] {
+		return errors.New("import failed")
+	}
+
+	si.imported.Store(name, s.GetRow())
+	return nil
+}
+
+func TestShardedImportRunner_Run(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sharded.csv")
+	content := [][]string{{"名称", "备注"}}
+	for i := 0; i < 10; i++ {
+		content = append(content, []string{fmt.Sprintf("name%d", i), ""})
+	}
+	content = append(content, []string{"", ""}, []string{"ignored", ""})
+	if err := util.WriteExcelContent(path, content); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &sync.Map{}
+	failed := map[string]bool{"name1": true, "name8": true}
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"), filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	runner := NewShardedImportRunner(func(shard int) *ImportFramework {
+		control := defaultImportControl
+		control.EnableParallel = true
+		control.MaxParallel = 2
+		return NewImporterOneSectionFramework(nil, &shardedImporter{failed: failed, imported: imported},
+			WithSimpleModelFactory(&shardedExcelModel{}), WithControl(control))
+	}, WithShardCount(3), WithShardRecorder(recorder), WithShardTempDir(dir))
+
+	report, err := runner.Run(path)
+	if err == nil {
+		t.Fatal("expected import error")
+	}
+
+	if report.Total != 10 || report.Success != 8 || report.Failed != 2 || len(report.Shards) != 3 {
+		t.Fatalf("unexpected report: %s", report)
+	}
+	if report.Shards[0].Err == nil || report.Shards[1].Err != nil || report.Shards[2].Err == nil {
+		t.Fatalf("unexpected shard errors: %s", report)
+	}
+
+	// the original row numbers are kept
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("name%d", i)
+		row, ok := imported.Load(name)
+		if failed[name] {
+			continue
+		}
+		if !ok || row.(int) != i+1 {
+			t.Fatalf("%s is imported at row %v, expected %d", name, row, i+1)
+		}
+	}
+
+	// the failure files of the shards are merged
+	failures, err := util.ReadExcelContent(recorder.GetImportFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || !strings.HasPrefix(failures[0][0], "第3行") || !strings.HasPrefix(failures[1][0], "第10行") {
+		t.Fatalf("unexpected merged failures: %v", failures)
+	}
+	if _, err = os.Stat(recorder.GetCheckFailedPath()); !os.IsNotExist(err) {
+		t.Fatalf("check failed file should not be created: %v", err)
+	}
+}
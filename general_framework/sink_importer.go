@@ -0,0 +1,68 @@
+package general_framework
+
+import (
+	"excel_import"
+	"gorm.io/gorm"
+)
+
+// SectionModelMapper map the section into the model written into the sink.
+// the section is skipped if the model is nil.
+type SectionModelMapper func(s *RawContent) (any, error)
+
+// SinkSectionImporter writes the model of the section into the sink.
+// the excel model of the section is written if the mapper is nil.
+type SinkSectionImporter struct {
+	sink   excel_import.Sink
+	mapper SectionModelMapper
+}
+
+func NewSinkSectionImporter(sink excel_import.Sink, mapper SectionModelMapper) *SinkSectionImporter {
+	return &SinkSectionImporter{
+		sink:   sink,
+		mapper: mapper,
+	}
+}
+
+func (si *SinkSectionImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
+	model := s.GetModel()
+	if si.mapper != nil {
+		var err error
+		if model, err = si.mapper(s); err != nil {
+			return err
+		}
+	}
+
+	if model == nil {
+		return nil
+	}
+
+	return si.sink.Write(&excel_import.SinkRecord{
+		Model: model,
+		Rows:  []int{s.GetRow()},
+	})
+}
+
+// sinkFlushMiddleware flushes the sink after the import
+type sinkFlushMiddleware struct {
+	sink excel_import.Sink
+}
+
+func (m *sinkFlushMiddleware) PreImportHandle(tx *gorm.DB, whole *RawWhole) error {
+	return nil
+}
+
+func (m *sinkFlushMiddleware) PostImportSectionHandle(tx *gorm.DB, s *RawContent) error {
+	return nil
+}
+
+func (m *sinkFlushMiddleware) PostHandle(tx *gorm.DB) error {
+	return m.sink.Flush()
+}
+
+// NewSinkFramework create the one section framework which writes the models into the sink instead of the db.
+// the parsing, checking, progress and recording are the same as the db import,
+// and the sink is flushed after the import.
+func NewSinkFramework(sink excel_import.Sink, mapper SectionModelMapper, options ...OptionFunc) *ImportFramework {
+	options = append(options, WithMiddlewares(&sinkFlushMiddleware{sink: sink}))
+	return NewImporterOneSectionFramework(nil, NewSinkSectionImporter(sink, mapper), options...)
+}
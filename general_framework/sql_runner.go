@@ -30,6 +30,11 @@ func NewSqlRunnerMiddleware(sqlPath string, db *gorm.DB, tableName string, enabl
 	}
 }
 
+// SetRateLimiter set the rate limiter of the direct execution
+func (s *SqlRunnerMiddleware) SetRateLimiter(limiter *util.RateLimiter) {
+	s.runner.SetRateLimiter(limiter)
+}
+
 func (s *SqlRunnerMiddleware) PreImportHandle(tx *gorm.DB, whole *RawWhole) error {
 	// do nothing
 	return nil
@@ -0,0 +1,101 @@
+package general_framework
+
+import (
+	util "excel_import/utils"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type templateExcelModel struct {
+	Name     string    `exi:"index:0,name:名称,fcf:cn"`
+	Level    string    `exi:"index:1,name:等级,enum:A|B|C"`
+	Count    int       `exi:"index:2,name:数量"`
+	Price    string    `exi:"index:3,name:价格,fcf:float"`
+	Birthday time.Time `exi:"index:4,name:日期"`
+}
+
+type templateImporter struct {
+	models []*templateExcelModel
+}
+
+func (ti *templateImporter) ImportSection(tx *gorm.DB, s *RawContent) error {
+	ti.models = append(ti.models, s.GetModel().(*templateExcelModel))
+	return nil
+}
+
+func TestGenerateTemplate_Import(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.xlsx")
+	if err := util.GenerateTemplate(&templateExcelModel{}, path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := f.GetSheetName(0)
+
+	// check the headers and the validations
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedHeaders := []string{"名称", "等级", "数量", "价格", "日期"}
+	if len(rows) != 1 || len(rows[0]) != len(expectedHeaders) {
+		t.Fatalf("unexpected template rows: %v", rows)
+	}
+	for i, header := range expectedHeaders {
+		if rows[0][i] != header {
+			t.Fatalf("header %d is %s, expected %s", i, rows[0][i], header)
+		}
+	}
+
+	dvs, err := f.GetDataValidations(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTypes := map[string]string{"B2:B1048576": "list", "C2:C1048576": "whole", "D2:D1048576": "decimal", "E2:E1048576": "date"}
+	if len(dvs) != len(expectedTypes) {
+		t.Fatalf("got %d validations, expected %d", len(dvs), len(expectedTypes))
+	}
+	for _, dv := range dvs {
+		if expectedTypes[dv.Sqref] != dv.Type {
+			t.Fatalf("validation of %s is %s, expected %s", dv.Sqref, dv.Type, expectedTypes[dv.Sqref])
+		}
+	}
+
+	comments, err := f.GetComments(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != len(expectedHeaders) {
+		t.Fatalf("got %d header comments, expected %d", len(comments), len(expectedHeaders))
+	}
+
+	// fill the template and import it with the same model
+	if err = f.SetSheetRow(sheet, "A2", &[]any{"苹果", "B", 3, "1.5", "2024-01-02"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	importer := &templateImporter{}
+	framework := NewImporterOneSectionFramework(nil, importer, WithSimpleModelFactory(&templateExcelModel{}))
+	if err = framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(importer.models) != 1 {
+		t.Fatalf("imported %d models, expected 1", len(importer.models))
+	}
+	model := importer.models[0]
+	if model.Name != "苹果" || model.Level != "B" || model.Count != 3 || model.Price != "1.5" ||
+		!model.Birthday.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected model: %+v", model)
+	}
+}
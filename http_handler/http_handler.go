@@ -0,0 +1,364 @@
+package http_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"excel_import/job_manager"
+	"excel_import/utils"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxFileSize  = 32 << 20
+	defaultMaxRows      = 100000
+	defaultPollInterval = 200 * time.Millisecond
+	defaultUploadDir    = "import_uploads"
+	maxMultipartMemory  = 8 << 20
+	uploadFilePattern   = "upload_*"
+
+	formFieldFile     = "file"
+	formFieldDryRun   = "dry_run"
+	formFieldForce    = "force"
+	formFieldOperator = "operator"
+
+	fileKindCheckFailed  = "check_failed"
+	fileKindImportFailed = "import_failed"
+	fileKindResult       = "result"
+
+	sseEventProgress = "progress"
+	sseEventDone     = "done"
+)
+
+var (
+	errImporterNotFound = errors.New("importer not found")
+	errFileTooLarge     = errors.New("file is too large")
+	errTooManyRows      = errors.New("file has too many rows")
+	errUnsupportedFile  = errors.New("only csv and xlsx files are supported")
+	errInvalidJobID     = errors.New("invalid job id")
+	errFileNotFound     = errors.New("file not found")
+)
+
+type OptionFunc func(*Handler)
+
+// Handler serves the uploads of the importers registered by name.
+//
+//	POST /imports/{name}          upload the multipart "file" and start the import job,
+//	                              the form "dry_run", "force" and "operator" are optional
+//	GET  /jobs/{id}               poll the job record
+//	GET  /jobs/{id}/events        stream the progress of the job by server-sent events
+//	GET  /jobs/{id}/files/{kind}  download the check_failed, import_failed or result file
+type Handler struct {
+	manager      *job_manager.JobManager
+	importers    map[string]job_manager.ImporterFactory
+	uploadDir    string
+	maxFileSize  int64
+	maxRows      int
+	pollInterval time.Duration
+	mux          *http.ServeMux
+	mu           sync.RWMutex
+}
+
+// WithUploadDir set the directory of the uploaded files
+func WithUploadDir(dir string) OptionFunc {
+	return func(handler *Handler) {
+		handler.uploadDir = dir
+	}
+}
+
+// WithMaxFileSize set the max size of the uploaded file in bytes
+func WithMaxFileSize(size int64) OptionFunc {
+	return func(handler *Handler) {
+		handler.maxFileSize = size
+	}
+}
+
+// WithMaxRows set the max rows of the uploaded file, including the header
+func WithMaxRows(rows int) OptionFunc {
+	return func(handler *Handler) {
+		handler.maxRows = rows
+	}
+}
+
+// WithPollInterval set the interval of the progress events
+func WithPollInterval(interval time.Duration) OptionFunc {
+	return func(handler *Handler) {
+		handler.pollInterval = interval
+	}
+}
+
+func NewHandler(manager *job_manager.JobManager, options ...OptionFunc) *Handler {
+	h := &Handler{
+		manager:      manager,
+		importers:    make(map[string]job_manager.ImporterFactory),
+		uploadDir:    defaultUploadDir,
+		maxFileSize:  defaultMaxFileSize,
+		maxRows:      defaultMaxRows,
+		pollInterval: defaultPollInterval,
+		mux:          http.NewServeMux(),
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	h.mux.HandleFunc("POST /imports/{name}", h.handleImport)
+	h.mux.HandleFunc("GET /jobs/{id}", h.handleGetJob)
+	h.mux.HandleFunc("GET /jobs/{id}/events", h.handleJobEvents)
+	h.mux.HandleFunc("GET /jobs/{id}/files/{kind}", h.handleJobFile)
+
+	return h
+}
+
+// Register register the importer by name.
+// the factory should pass the env into the framework, e.g. WithRecorder, WithProgressReporter and WithDryRun,
+// the dry run job fails if the importer ignores the dry run.
+func (h *Handler) Register(name string, factory job_manager.ImporterFactory) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.importers[name] = factory
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) getImporter(name string) (job_manager.ImporterFactory, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	factory, ok := h.importers[name]
+	return factory, ok
+}
+
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	factory, ok := h.getImporter(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", errImporterNotFound, name))
+		return
+	}
+
+	// limit the file size
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize)
+	if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, errFileTooLarge)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile(formFieldFile)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer file.Close()
+
+	path, status, err := h.saveUpload(file, header)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	record, err := h.manager.RunAsync(&job_manager.JobRequest{
+		Name:     name,
+		Path:     path,
+		Operator: r.FormValue(formFieldOperator),
+		Force:    parseBool(r.FormValue(formFieldForce)),
+		DryRun:   parseBool(r.FormValue(formFieldDryRun)),
+		Factory:  factory,
+	})
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, job_manager.ErrDuplicateFile) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, record)
+}
+
+// saveUpload save the uploaded file and check the rows, return the status code if failed
+func (h *Handler) saveUpload(file multipart.File, header *multipart.FileHeader) (string, int, error) {
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext != ".csv" && ext != ".xlsx" {
+		return "", http.StatusBadRequest, errUnsupportedFile
+	}
+
+	if err := os.MkdirAll(h.uploadDir, os.ModePerm); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	out, err := os.CreateTemp(h.uploadDir, uploadFilePattern+ext)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	_, err = io.Copy(out, file)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", http.StatusInternalServerError, err
+	}
+
+	// limit the rows
+	content, err := util.ReadExcelContent(out.Name())
+	if err != nil {
+		os.Remove(out.Name())
+		return "", http.StatusBadRequest, err
+	}
+	if len(content) > h.maxRows {
+		os.Remove(out.Name())
+		return "", http.StatusRequestEntityTooLarge, fmt.Errorf("%w: %d > %d", errTooManyRows, len(content), h.maxRows)
+	}
+
+	return out.Name(), http.StatusOK, nil
+}
+
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	record, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+// handleJobEvents stream the job record as the progress event until the job is finished
+func (h *Handler) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	record, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		event := sseEventProgress
+		if record.CheckFinished() {
+			event = sseEventDone
+		}
+
+		if err := writeEvent(w, event, record); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		if event == sseEventDone {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if record, err = h.manager.GetJob(record.ID); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) handleJobFile(w http.ResponseWriter, r *http.Request) {
+	record, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	var path string
+	switch r.PathValue("kind") {
+	case fileKindCheckFailed:
+		path = record.CheckFailedPath
+	case fileKindImportFailed:
+		path = record.ImportFailedPath
+	case fileKindResult:
+		path = record.ResultPath
+	}
+	if len(path) == 0 {
+		writeError(w, http.StatusNotFound, errFileNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request) (*job_manager.JobRecord, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidJobID)
+		return nil, false
+	}
+
+	record, err := h.manager.GetJob(id)
+	if err != nil {
+		if errors.Is(err, job_manager.ErrJobNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return nil, false
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return record, true
+}
+
+func writeEvent(w io.Writer, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		fmt.Printf("write response failed: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func parseBool(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
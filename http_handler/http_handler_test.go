@@ -0,0 +1,302 @@
+package http_handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"excel_import"
+	"excel_import/general_framework"
+	"excel_import/job_manager"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type uploadExcelModel struct {
+	Name  string `exi:"index:0"`
+	Price string `exi:"index:1,fcf:float"`
+}
+
+type uploadProduct struct {
+	ID    int64
+	Name  string
+	Price string
+}
+
+type uploadImporter struct{}
+
+func (ui *uploadImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	model := s.GetModel().(*uploadExcelModel)
+	if model.Name == "bad" {
+		return errors.New("bad product")
+	}
+
+	product := &uploadProduct{Name: model.Name, Price: model.Price}
+	if err := tx.Create(product).Error; err != nil {
+		return err
+	}
+	s.SetInsertModel(product)
+
+	return nil
+}
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite allows only one writer
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	return db
+}
+
+func newTestServer(t *testing.T, options ...OptionFunc) (*httptest.Server, *gorm.DB) {
+	dataDB := openTestDB(t, "data.db")
+	if err := dataDB.AutoMigrate(&uploadProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	manager, err := job_manager.NewJobManager(openTestDB(t, "job.db"), job_manager.WithJobDir(filepath.Join(dir, "jobs")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler(manager, append([]OptionFunc{WithUploadDir(filepath.Join(dir, "uploads")),
+		WithPollInterval(10 * time.Millisecond)}, options...)...)
+	handler.Register("product", func(env *job_manager.JobEnv) excel_import.ExcelImporter {
+		annotation := general_framework.NewResultAnnotationMiddleware(env.Path)
+		annotation.SetOutputPath(env.ResultPath)
+
+		options := []general_framework.OptionFunc{
+			general_framework.WithSimpleModelFactory(&uploadExcelModel{}),
+			general_framework.WithRecorder(env.Recorder),
+			general_framework.WithProgressReporter(env.ProgressReporter),
+			general_framework.WithMiddlewares(annotation),
+		}
+		if env.DryRun {
+			options = append(options, general_framework.WithDryRun())
+		}
+
+		return general_framework.NewImporterOneSectionFramework(dataDB, &uploadImporter{}, options...)
+	})
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server, dataDB
+}
+
+func upload(t *testing.T, server *httptest.Server, importer, filename string, content []byte, fields map[string]string) (int, map[string]any) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(formFieldFile, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range fields {
+		if err = writer.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(server.URL+"/imports/"+importer, writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	result := make(map[string]any)
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, result
+}
+
+// waitJob poll the job until it's finished
+func waitJob(t *testing.T, server *httptest.Server, id int64) *job_manager.JobRecord {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(fmt.Sprintf("%s/jobs/%d", server.URL, id))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record := &job_manager.JobRecord{}
+		err = json.NewDecoder(resp.Body).Decode(record)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.CheckFinished() {
+			return record
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job %d is not finished", id)
+	return nil
+}
+
+func jobID(result map[string]any) int64 {
+	return int64(result["id"].(float64))
+}
+
+func TestHandler_DryRunAndImport(t *testing.T) {
+	server, dataDB := newTestServer(t)
+	content := []byte("名称,价格\n苹果,1.5\n香蕉,2\n")
+
+	// the dry run doesn't import
+	status, result := upload(t, server, "product", "products.csv", content, map[string]string{formFieldDryRun: "true"})
+	if status != http.StatusAccepted {
+		t.Fatalf("status %d, result %v", status, result)
+	}
+	if record := waitJob(t, server, jobID(result)); record.Status != job_manager.JobStatusSuccess || !record.DryRun {
+		t.Fatalf("unexpected dry run record: %+v", record)
+	}
+
+	var count int64
+	if err := dataDB.Model(&uploadProduct{}).Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("dry run imported %d products, err %v", count, err)
+	}
+
+	// the real import streams the progress until done
+	status, result = upload(t, server, "product", "products.csv", content, map[string]string{formFieldOperator: "tester"})
+	if status != http.StatusAccepted {
+		t.Fatalf("status %d, result %v", status, result)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/jobs/%d/events", server.URL, jobID(result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+
+	var lastEvent, lastData string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			lastEvent = strings.TrimPrefix(line, "event: ")
+		} else if strings.HasPrefix(line, "data: ") {
+			lastData = strings.TrimPrefix(line, "data: ")
+		}
+	}
+
+	record := &job_manager.JobRecord{}
+	if err = json.Unmarshal([]byte(lastData), record); err != nil {
+		t.Fatal(err)
+	}
+	if lastEvent != sseEventDone || record.Status != job_manager.JobStatusSuccess || record.Success != 2 || record.Operator != "tester" {
+		t.Fatalf("unexpected last event %s: %+v", lastEvent, record)
+	}
+	if err = dataDB.Model(&uploadProduct{}).Count(&count).Error; err != nil || count != 2 {
+		t.Fatalf("imported %d products, err %v", count, err)
+	}
+
+	// the annotated workbook is served
+	resp, err = http.Get(fmt.Sprintf("%s/jobs/%d/files/%s", server.URL, record.ID, fileKindResult))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	f, err := excelize.OpenReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if v, _ := f.GetCellValue(f.GetSheetName(0), "C2"); v != "成功, ID: 1" {
+		t.Fatalf("unexpected result cell %s", v)
+	}
+
+	// the identical file is refused unless forced
+	if status, result = upload(t, server, "product", "products.csv", content, nil); status != http.StatusConflict {
+		t.Fatalf("status %d, result %v", status, result)
+	}
+}
+
+func TestHandler_ImportFailed(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	status, result := upload(t, server, "product", "products.csv", []byte("名称,价格\n苹果,1.5\nbad,2\n"), nil)
+	if status != http.StatusAccepted {
+		t.Fatalf("status %d, result %v", status, result)
+	}
+	record := waitJob(t, server, jobID(result))
+	if record.Status != job_manager.JobStatusFailed || record.Failed != 1 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// the failure file is served
+	resp, err := http.Get(fmt.Sprintf("%s/jobs/%d/files/%s", server.URL, record.ID, fileKindImportFailed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(b), "bad product") {
+		t.Fatalf("status %d, failure file %s", resp.StatusCode, b)
+	}
+
+	// the file not recorded isn't found
+	resp, err = http.Get(fmt.Sprintf("%s/jobs/%d/files/%s", server.URL, record.ID, fileKindCheckFailed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status %d, expected %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHandler_Limits(t *testing.T) {
+	server, _ := newTestServer(t, WithMaxFileSize(1024), WithMaxRows(3))
+
+	tests := []struct {
+		importer string
+		filename string
+		content  []byte
+		status   int
+	}{
+		{importer: "unknown", filename: "products.csv", content: []byte("名称,价格\n"), status: http.StatusNotFound},
+		{importer: "product", filename: "products.txt", content: []byte("名称,价格\n"), status: http.StatusBadRequest},
+		{importer: "product", filename: "products.csv", content: bytes.Repeat([]byte("a,1\n"), 512), status: http.StatusRequestEntityTooLarge},
+		{importer: "product", filename: "products.csv", content: []byte("名称,价格\na,1\nb,2\nc,3\n"), status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		if status, result := upload(t, server, test.importer, test.filename, test.content, nil); status != test.status {
+			t.Fatalf("upload %s to %s status %d, expected %d, result %v", test.filename, test.importer, status, test.status, result)
+		}
+	}
+}
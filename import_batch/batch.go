@@ -0,0 +1,235 @@
+package import_batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/import_effect"
+	"excel_import/tree_framework"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrPrimaryKeyUnknown   = errors.New("the primary key of the inserted model is unknown")
+	errStampColumnNotFound = errors.New("stamp column not found")
+)
+
+// Batch stamp the records imported by the frameworks with the batch id, so that they can be reverted by Revert.
+// the inserted records are recorded by the primary key into the record table, or stamped through the stamp column.
+// the previous values of the updated records are recorded before the update, so the update effects
+// should be executed by the batch feature or the sql runner middleware, instead of the importer.
+// the importer which updates directly should call RecordUpdate with its tx before, otherwise the update is refused.
+type Batch struct {
+	id  string
+	cfg *config
+	// the tables which have been stamped through the stamp column
+	stamped map[string]bool
+	// guard the stamped tables, the middleware runs concurrently under the parallel import
+	mu sync.Mutex
+	// the tables recorded by the importers, which can be updated directly
+	recorded import_effect.RecordedUpdates
+}
+
+func NewBatch(batchID string, options ...OptionFunc) *Batch {
+	return &Batch{
+		id:      batchID,
+		cfg:     newConfig(options...),
+		stamped: make(map[string]bool),
+	}
+}
+
+func (b *Batch) GetID() string {
+	return b.id
+}
+
+// Migrate create the record table if not exists
+func (b *Batch) Migrate(tx *gorm.DB) error {
+	return tx.Table(b.cfg.recordTable).AutoMigrate(&Record{})
+}
+
+// RecordInsert record the inserted record of the table by the primary key.
+// the record is stamped through the stamp column if set.
+func (b *Batch) RecordInsert(tx *gorm.DB, table, primaryKey string, value any) error {
+	if len(b.cfg.stampColumn) > 0 {
+		if err := tx.Table(table).Where(map[string]any{primaryKey: value}).Update(b.cfg.stampColumn, b.id).Error; err != nil {
+			return err
+		}
+
+		return b.recordStamp(tx, table)
+	}
+
+	return b.create(tx, &Record{Table: table, Op: OpInsert, Column: primaryKey, Value: fmt.Sprint(value)})
+}
+
+// RecordModelInsert record the inserted model, the model must be parsed by gorm.
+// the model which isn't inserted yet, e.g. inserted by the batch feature, has no primary key,
+// so it can only be stamped through the stamp column, which is set into the model before inserted.
+func (b *Batch) RecordModelInsert(tx *gorm.DB, model any) error {
+	s, err := import_effect.ParseSchema(tx, model)
+	if err != nil {
+		return err
+	}
+	pk := s.PrioritizedPrimaryField
+	if pk == nil {
+		return fmt.Errorf("%w: %s has no primary key", ErrPrimaryKeyUnknown, s.Table)
+	}
+
+	rv := reflect.ValueOf(model)
+	value, isZero := pk.ValueOf(context.Background(), rv)
+	if !isZero {
+		return b.RecordInsert(tx, s.Table, pk.DBName, value)
+	}
+	if len(b.cfg.stampColumn) == 0 {
+		return fmt.Errorf("%w: %s isn't inserted yet, use the stamp column instead", ErrPrimaryKeyUnknown, s.Table)
+	}
+
+	field := s.LookUpField(b.cfg.stampColumn)
+	if field == nil {
+		return fmt.Errorf("%w: %s of %s", errStampColumnNotFound, b.cfg.stampColumn, s.Table)
+	}
+	if err = field.Set(context.Background(), rv, b.id); err != nil {
+		return err
+	}
+
+	return b.recordStamp(tx, s.Table)
+}
+
+// RecordUpdate record the previous values of the records which are going to be updated.
+// the matched records are recorded one by one by the primary key.
+func (b *Batch) RecordUpdate(tx *gorm.DB, table, primaryKey string, columns []string, wheres map[string]any) error {
+	b.recorded.Mark(tx, table)
+
+	rows := make([]map[string]any, 0)
+	if err := tx.Table(table).Select(append([]string{primaryKey}, columns...)).Where(wheres).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		import_effect.NormalizeRow(row)
+		previous := make(map[string]any, len(columns))
+		for _, column := range columns {
+			previous[column] = row[column]
+		}
+
+		data, err := json.Marshal(previous)
+		if err != nil {
+			return err
+		}
+		record := &Record{Table: table, Op: OpUpdate, Column: primaryKey, Value: fmt.Sprint(row[primaryKey]), Previous: string(data)}
+		if err = b.create(tx, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordStamp record the stamped table once
+func (b *Batch) recordStamp(tx *gorm.DB, table string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stamped[table] {
+		return nil
+	}
+
+	if err := b.create(tx, &Record{Table: table, Op: OpStamp, Column: b.cfg.stampColumn, Value: b.id}); err != nil {
+		return err
+	}
+	b.stamped[table] = true
+
+	return nil
+}
+
+func (b *Batch) create(tx *gorm.DB, record *Record) error {
+	record.BatchID = b.id
+	return tx.Table(b.cfg.recordTable).Create(record).Error
+}
+
+// Middleware the middleware of the general framework, which records the effects of the sections.
+// it should be added by WithMiddlewares, so that it runs before the batch feature.
+func (b *Batch) Middleware() general_framework.GeneralMiddleware {
+	return &generalMiddleware{batch: b}
+}
+
+// TreeMiddleware the middleware of the tree framework, which records the imported nodes by the id.
+// the tables of the nodes are resolved by import_effect.NodeTable, the nodes without the id are skipped.
+func (b *Batch) TreeMiddleware(tables ...string) tree_framework.TreeMiddleware {
+	return &treeMiddleware{batch: b, tables: tables}
+}
+
+type generalMiddleware struct {
+	batch *Batch
+}
+
+func (m *generalMiddleware) PreImportHandle(tx *gorm.DB, whole *general_framework.RawWhole) error {
+	return m.batch.Migrate(tx)
+}
+
+func (m *generalMiddleware) PostImportSectionHandle(tx *gorm.DB, rc *general_framework.RawContent) error {
+	m.batch.recorded.Release(rc)
+
+	if im := rc.GetInsertModel(); im != nil {
+		if err := m.batch.RecordModelInsert(tx, im); err != nil {
+			return err
+		}
+	}
+
+	update, err := m.batch.cfg.target.ResolveUpdate(tx, rc)
+	if err != nil || update == nil {
+		return err
+	}
+
+	return m.batch.RecordUpdate(tx, update.Table, update.PrimaryKey, sortedKeys(update.Updates), update.Wheres)
+}
+
+// PreSectionUpdate refuse the direct update of the importer which isn't recorded by RecordUpdate before,
+// since the revert can't restore it
+func (m *generalMiddleware) PreSectionUpdate(tx *gorm.DB, rc *general_framework.RawContent, table string) error {
+	if m.batch.recorded.Check(rc, table) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: table %q of the row %d", import_effect.ErrUnrecordedUpdate, table, rc.GetRow())
+}
+
+func (m *generalMiddleware) PostHandle(tx *gorm.DB) error {
+	return nil
+}
+
+type treeMiddleware struct {
+	batch  *Batch
+	tables []string
+}
+
+func (m *treeMiddleware) PreImportHandle(tx *gorm.DB, info tree_framework.TreeInfo) error {
+	return m.batch.Migrate(tx)
+}
+
+func (m *treeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *tree_framework.TreeNode) error {
+	table, ok := import_effect.NodeTable(m.tables, node)
+	if !ok {
+		return nil
+	}
+
+	return m.batch.RecordInsert(tx, table, m.batch.cfg.target.PrimaryKey, node.GetID())
+}
+
+func (m *treeMiddleware) PostHandle(tx *gorm.DB) error {
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
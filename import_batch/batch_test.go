@@ -0,0 +1,338 @@
+package import_batch
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/import_effect"
+	"excel_import/tree_framework"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type batchExcelModel struct {
+	Code  string  `exi:"index:0"`
+	Price float64 `exi:"index:1,fcf:float"`
+}
+
+type batchProduct struct {
+	ID      int64   `gorm:"column:id;primaryKey"`
+	Code    string  `gorm:"column:code"`
+	Price   float64 `gorm:"column:price"`
+	BatchID string  `gorm:"column:batch_id"`
+}
+
+func (p *batchProduct) TableName() string {
+	return "batch_products"
+}
+
+// batchImporter insert the new products and update the existing ones by the code.
+// the effects are executed by the batch feature if deferred, otherwise by the importer.
+type batchImporter struct {
+	batch    *Batch
+	deferred bool
+}
+
+func (bi *batchImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	model := s.GetModel().(*batchExcelModel)
+
+	var count int64
+	if err := tx.Model(&batchProduct{}).Where("code = ?", model.Code).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		product := &batchProduct{Code: model.Code, Price: model.Price}
+		if !bi.deferred {
+			if err := tx.Create(product).Error; err != nil {
+				return err
+			}
+		}
+		s.SetInsertModel(product)
+		return nil
+	}
+
+	updates, wheres := map[string]any{"price": model.Price}, map[string]any{"code": model.Code}
+	if bi.deferred {
+		s.SetUpdateModelCond(&batchProduct{}, updates, wheres)
+		return nil
+	}
+
+	// the previous values are recorded before the update
+	if err := bi.batch.RecordUpdate(tx, "batch_products", "id", []string{"price"}, wheres); err != nil {
+		return err
+	}
+	return tx.Model(&batchProduct{}).Where(wheres).Updates(updates).Error
+}
+
+func openBatchDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "batch.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&batchProduct{}, &batchCategory{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Create(&batchProduct{Code: "P1", Price: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func importProducts(t *testing.T, db *gorm.DB, batch *Batch, deferred bool) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "products.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P2", "2"}, {"P1", "5"}, {"P3", "3"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	options := []general_framework.OptionFunc{
+		general_framework.WithSimpleModelFactory(&batchExcelModel{}),
+		general_framework.WithMiddlewares(batch.Middleware()),
+		general_framework.WithRecorder(recorder),
+		general_framework.WithProgressReporter(util.NewProgressReporter(false)),
+	}
+	if deferred {
+		control := general_framework.ImportControl{StartRow: 1, Ef: util.DefaultRowEndFunc, EnableBatch: true, BatchSize: 2}
+		options = append([]general_framework.OptionFunc{general_framework.WithControl(control)}, options...)
+	}
+
+	framework := general_framework.NewImporterOneSectionFramework(db, &batchImporter{batch: batch, deferred: deferred}, options...)
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkReverted(t *testing.T, db *gorm.DB, batchID string, options ...OptionFunc) {
+	var products []*batchProduct
+	if err := db.Order("id").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Code != "P1" || products[0].Price != 1 {
+		t.Fatalf("unexpected products after reverted %+v", products)
+	}
+
+	// the batch can't be reverted twice
+	plan, err := PlanRevert(db, batchID, options...)
+	if err != nil || !plan.CheckEmpty() {
+		t.Fatalf("unexpected plan after reverted, err %v:\n%s", err, plan.Preview())
+	}
+}
+
+func TestRevert_RecordTable(t *testing.T) {
+	db := openBatchDB(t)
+	batch := NewBatch("b1")
+	importProducts(t, db, batch, false)
+
+	var count int64
+	if err := db.Model(&batchProduct{}).Count(&count).Error; err != nil || count != 3 {
+		t.Fatalf("got %d products, err %v", count, err)
+	}
+
+	// the dry run lists the changes without changing anything
+	plan, err := PlanRevert(db, "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview := plan.Preview()
+	for _, line := range []string{"batch b1: 2 deletes, 1 restores", "- batch_products id=3\n- batch_products id=2", "~ batch_products id=1: price=1"} {
+		if !strings.Contains(preview, line) {
+			t.Fatalf("preview doesn't contain %q:\n%s", line, preview)
+		}
+	}
+	if err = db.Model(&batchProduct{}).Count(&count).Error; err != nil || count != 3 {
+		t.Fatalf("the dry run changed the products, got %d, err %v", count, err)
+	}
+
+	if _, err = Revert(db, "b1"); err != nil {
+		t.Fatal(err)
+	}
+	checkReverted(t, db, "b1")
+}
+
+func TestRevert_StampColumn(t *testing.T) {
+	db := openBatchDB(t)
+	batch := NewBatch("b2", WithStampColumn("batch_id"), WithRecordTable("batch_records"))
+	importProducts(t, db, batch, true)
+
+	// the deferred insertions carry the batch id
+	var products []*batchProduct
+	if err := db.Order("id").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 3 || products[0].Price != 5 || products[1].BatchID != "b2" || products[2].BatchID != "b2" {
+		t.Fatalf("unexpected products %+v", products)
+	}
+
+	plan, err := Revert(db, "b2", WithRecordTable("batch_records"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview := plan.Preview(); !strings.Contains(preview, "- batch_products batch_id=b2 (2 records)") {
+		t.Fatalf("unexpected preview:\n%s", preview)
+	}
+	checkReverted(t, db, "b2", WithRecordTable("batch_records"))
+
+	// the deferred insertion can't be recorded without the stamp column
+	if err = NewBatch("b3").RecordModelInsert(db, &batchProduct{Code: "P4"}); err == nil {
+		t.Fatal("expected the unknown primary key error")
+	}
+}
+
+// unrecordedImporter update the price directly without recording the previous values
+type unrecordedImporter struct {
+	raw bool
+}
+
+func (ui *unrecordedImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	model := s.GetModel().(*batchExcelModel)
+	if ui.raw {
+		return tx.Exec("UPDATE batch_products SET price = ? WHERE code = ?", model.Price, model.Code).Error
+	}
+
+	return tx.Model(&batchProduct{}).Where("code = ?", model.Code).Update("price", model.Price).Error
+}
+
+func TestBatch_UnrecordedUpdate(t *testing.T) {
+	db := openBatchDB(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "products.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P1", "5"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, raw := range []bool{false, true} {
+		framework := general_framework.NewImporterOneSectionFramework(db, &unrecordedImporter{raw: raw},
+			general_framework.WithSimpleModelFactory(&batchExcelModel{}),
+			general_framework.WithMiddlewares(NewBatch("b5").Middleware()),
+			general_framework.WithRecorder(util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+				filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))),
+			general_framework.WithProgressReporter(util.NewProgressReporter(false)))
+		if err := framework.Import(path); !errors.Is(err, import_effect.ErrUnrecordedUpdate) {
+			t.Fatalf("expected the unrecorded update error of raw %v, got %v", raw, err)
+		}
+	}
+
+	// the refused updates aren't executed
+	product := &batchProduct{}
+	if err := db.First(product, "code = ?", "P1").Error; err != nil || product.Price != 1 {
+		t.Fatalf("unexpected product %+v, err %v", product, err)
+	}
+}
+
+func TestBatch_ConcurrentStamp(t *testing.T) {
+	db := openBatchDB(t)
+	// sqlite allows only one writer
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	batch := NewBatch("b4", WithStampColumn("batch_id"))
+	if err = batch.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- batch.RecordModelInsert(db, &batchProduct{Code: fmt.Sprintf("C%d", i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err = range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the table is stamped once
+	var count int64
+	if err = db.Table(defaultRecordTable).Where("batch_id = ? AND op = ?", "b4", OpStamp).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("the table is stamped %d times", count)
+	}
+}
+
+type batchCategory struct {
+	ID       int64  `gorm:"column:id;primaryKey"`
+	Name     string `gorm:"column:name"`
+	ParentID int64  `gorm:"column:parent_id"`
+}
+
+type categoryModelFac struct {
+}
+
+func (mf *categoryModelFac) GetModel() any {
+	return &categoryModel{}
+}
+
+func (mf *categoryModelFac) MinColumnCount() int {
+	return 2
+}
+
+type categoryModel struct {
+	L1, L2 string
+}
+
+type categoryImporter struct {
+}
+
+func (ci *categoryImporter) ImportLevelNode(tx *gorm.DB, node *tree_framework.TreeNode) error {
+	category := &batchCategory{Name: node.GetValue(), ParentID: node.GetParent().GetID()}
+	if err := tx.Create(category).Error; err != nil {
+		return err
+	}
+	node.SetID(category.ID)
+
+	return nil
+}
+
+func TestRevert_Tree(t *testing.T) {
+	db := openBatchDB(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "categories.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"一级", "二级"}, {"水果", "苹果"}, {"水果", "香蕉"}, {"蔬菜", "白菜"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := NewBatch("b4")
+	cfg := &tree_framework.TreeImportCfg{LevelOrder: []int{0, 1}, TreeBoundary: 1, ColumnCount: 2, ModelFac: &categoryModelFac{}}
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	ci := &categoryImporter{}
+	framework := tree_framework.NewTreeImportFramework(db, cfg, nil, []tree_framework.LevelImporter{ci, ci},
+		tree_framework.WithMiddlewares(batch.TreeMiddleware("batch_categories")),
+		tree_framework.WithRecorder(recorder), tree_framework.WithProgressReporter(util.NewProgressReporter(false)))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Revert(db, "b4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Deletes) != 5 {
+		t.Fatalf("unexpected plan:\n%s", plan.Preview())
+	}
+
+	var count int64
+	if err = db.Model(&batchCategory{}).Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("got %d categories after reverted, err %v", count, err)
+	}
+}
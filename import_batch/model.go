@@ -0,0 +1,159 @@
+package import_batch
+
+import (
+	"excel_import/import_effect"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Op string
+
+const (
+	// the inserted record, identified by the primary key
+	OpInsert Op = "insert"
+	// the updated record, identified by the primary key with the previous values
+	OpUpdate Op = "update"
+	// the table whose inserted records are stamped through the stamp column
+	OpStamp Op = "stamp"
+
+	defaultRecordTable = "import_batch_records"
+)
+
+type OptionFunc func(*config)
+
+type config struct {
+	stampColumn string
+	recordTable string
+	target      import_effect.Target
+}
+
+func newConfig(options ...OptionFunc) *config {
+	cfg := &config{
+		recordTable: defaultRecordTable,
+		target:      import_effect.NewTarget(),
+	}
+
+	for _, option := range options {
+		option(cfg)
+	}
+
+	return cfg
+}
+
+// WithStampColumn stamp the inserted records with the batch id through the column of the model,
+// instead of recording them one by one into the record table.
+// the column must exist in the tables of the inserted records.
+func WithStampColumn(column string) OptionFunc {
+	return func(cfg *config) {
+		cfg.stampColumn = column
+	}
+}
+
+// WithRecordTable set the table of the batch records, default is import_batch_records
+func WithRecordTable(table string) OptionFunc {
+	return func(cfg *config) {
+		cfg.recordTable = table
+	}
+}
+
+// WithTable set the table of the update effects without the update model
+func WithTable(table string) OptionFunc {
+	return func(cfg *config) {
+		cfg.target.Table = table
+	}
+}
+
+// WithPrimaryKey set the primary key column of the tables without the model, default is id
+func WithPrimaryKey(column string) OptionFunc {
+	return func(cfg *config) {
+		cfg.target.PrimaryKey = column
+	}
+}
+
+// Record the record of the batch, which is used to revert the batch
+type Record struct {
+	ID      int64  `gorm:"column:id;primaryKey"`
+	BatchID string `gorm:"column:batch_id;index"`
+	Table   string `gorm:"column:table_name"`
+	Op      Op     `gorm:"column:op"`
+	// the primary key column for the insertion and the update, the stamp column for the stamp
+	Column string `gorm:"column:column_name"`
+	// the primary key value for the insertion and the update, the batch id for the stamp
+	Value string `gorm:"column:value"`
+	// the previous values of the updated columns in json
+	Previous  string    `gorm:"column:previous"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (r *Record) TableName() string {
+	return defaultRecordTable
+}
+
+// RevertItem the change of the revert
+type RevertItem struct {
+	Table  string
+	Column string
+	Value  string
+	// the restored values of the update
+	Values map[string]any
+	// the count of the stamped records
+	Count int64
+}
+
+// RevertPlan the changes which revert the batch.
+// the deletions are in the reverse order of the import, so are the restorations.
+type RevertPlan struct {
+	BatchID  string
+	Deletes  []*RevertItem
+	Restores []*RevertItem
+}
+
+// CheckEmpty check if nothing is reverted
+func (p *RevertPlan) CheckEmpty() bool {
+	return len(p.Deletes) == 0 && len(p.Restores) == 0
+}
+
+// Preview the readable changes of the plan, one change per line.
+// the deletion starts with "-" and the restoration starts with "~", e.g. "~ products id=2: price=2".
+func (p *RevertPlan) Preview() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("batch %s: %d deletes, %d restores\n", p.BatchID, p.countDeletes(), len(p.Restores)))
+
+	for _, item := range p.Deletes {
+		if item.Count > 0 {
+			sb.WriteString(fmt.Sprintf("- %s %s=%s (%d records)\n", item.Table, item.Column, item.Value, item.Count))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- %s %s=%s\n", item.Table, item.Column, item.Value))
+	}
+	for _, item := range p.Restores {
+		sb.WriteString(fmt.Sprintf("~ %s %s=%s: %s\n", item.Table, item.Column, item.Value, formatValues(item.Values)))
+	}
+
+	return sb.String()
+}
+
+// countDeletes the count of the deleted records, the stamped records are counted one by one
+func (p *RevertPlan) countDeletes() int64 {
+	var count int64
+	for _, item := range p.Deletes {
+		if item.Count > 0 {
+			count += item.Count
+			continue
+		}
+		count++
+	}
+
+	return count
+}
+
+func formatValues(values map[string]any) string {
+	columns := sortedKeys(values)
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		parts = append(parts, fmt.Sprintf("%s=%v", column, values[column]))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,109 @@
+package import_batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PlanRevert list the changes which revert the batch, nothing is changed
+func PlanRevert(db *gorm.DB, batchID string, options ...OptionFunc) (*RevertPlan, error) {
+	cfg := newConfig(options...)
+
+	var records []*Record
+	if err := db.Table(cfg.recordTable).Where("batch_id = ?", batchID).Order("id DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	plan := &RevertPlan{BatchID: batchID}
+	stamped := make(map[string]bool)
+	for _, record := range records {
+		item := &RevertItem{Table: record.Table, Column: record.Column, Value: record.Value}
+
+		switch record.Op {
+		case OpInsert:
+			plan.Deletes = append(plan.Deletes, item)
+		case OpStamp:
+			// the table may be stamped by several imports of the batch
+			key := record.Table + "." + record.Column
+			if stamped[key] {
+				continue
+			}
+			stamped[key] = true
+
+			if err := db.Table(record.Table).Where(clause.Eq{Column: clause.Column{Name: record.Column}, Value: record.Value}).
+				Count(&item.Count).Error; err != nil {
+				return nil, err
+			}
+			if item.Count > 0 {
+				plan.Deletes = append(plan.Deletes, item)
+			}
+		case OpUpdate:
+			values, err := decodeValues(record.Previous)
+			if err != nil {
+				return nil, err
+			}
+			item.Values = values
+			plan.Restores = append(plan.Restores, item)
+		}
+	}
+
+	return plan, nil
+}
+
+// Revert delete the inserted records and restore the previous values of the updated records in a transaction,
+// then the records of the batch are removed, so the batch can't be reverted twice.
+func Revert(db *gorm.DB, batchID string, options ...OptionFunc) (*RevertPlan, error) {
+	cfg := newConfig(options...)
+	plan, err := PlanRevert(db, batchID, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range plan.Deletes {
+			if err := tx.Exec("DELETE FROM ? WHERE ? = ?", clause.Table{Name: item.Table}, clause.Column{Name: item.Column}, item.Value).Error; err != nil {
+				return err
+			}
+		}
+		for _, item := range plan.Restores {
+			if err := tx.Table(item.Table).Where(clause.Eq{Column: clause.Column{Name: item.Column}, Value: item.Value}).
+				Updates(item.Values).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Table(cfg.recordTable).Where("batch_id = ?", batchID).Delete(&Record{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// decodeValues decode the previous values, the integers are kept as int64
+func decodeValues(data string) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+
+	values := make(map[string]any)
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	for column, value := range values {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if i, err := number.Int64(); err == nil {
+			values[column] = i
+		} else if f, err := number.Float64(); err == nil {
+			values[column] = f
+		}
+	}
+
+	return values, nil
+}
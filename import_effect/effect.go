@@ -0,0 +1,89 @@
+package import_effect
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/tree_framework"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const DefaultPrimaryKey = "id"
+
+var ErrTableUnknown = errors.New("the table of the update is unknown")
+
+// Target the table and the primary key of the effects without the model
+type Target struct {
+	// the table of the update effects without the update model
+	Table string
+	// the primary key column of the tables without the model, default is id
+	PrimaryKey string
+}
+
+func NewTarget() Target {
+	return Target{PrimaryKey: DefaultPrimaryKey}
+}
+
+// Update the update effect of the section, resolved into the table
+type Update struct {
+	Table      string
+	PrimaryKey string
+	Updates    map[string]any
+	Wheres     map[string]any
+}
+
+// ResolveUpdate resolve the update effect of the section, nil if the section has no update effect.
+// the table and the primary key of the update model are prior to the target.
+func (t Target) ResolveUpdate(tx *gorm.DB, rc *general_framework.RawContent) (*Update, error) {
+	um, upCond, whereCond := rc.GetUpdateCond()
+	if len(upCond) == 0 || len(whereCond) == 0 {
+		return nil, nil
+	}
+
+	update := &Update{Table: t.Table, PrimaryKey: t.PrimaryKey, Updates: upCond, Wheres: whereCond}
+	if um != nil {
+		s, err := ParseSchema(tx, um)
+		if err != nil {
+			return nil, err
+		}
+		update.Table = s.Table
+		if s.PrioritizedPrimaryField != nil {
+			update.PrimaryKey = s.PrioritizedPrimaryField.DBName
+		}
+	}
+	if len(update.Table) == 0 {
+		return nil, ErrTableUnknown
+	}
+
+	return update, nil
+}
+
+// NodeTable the table of the tree node by the rank, false if the node has no id or no table.
+// the tables are the tables of the root and the levels in order, the last table is used for the rest levels,
+// e.g. ["categories"] for the tree in one table.
+func NodeTable(tables []string, node *tree_framework.TreeNode) (string, bool) {
+	if node.GetID() == 0 || len(tables) == 0 {
+		return "", false
+	}
+
+	return tables[min(node.GetRank(), len(tables)-1)], true
+}
+
+// ParseSchema parse the gorm schema of the model
+func ParseSchema(tx *gorm.DB, model any) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	return stmt.Schema, nil
+}
+
+// NormalizeRow normalize the text scanned as bytes, which is encoded as base64 in json
+func NormalizeRow(row map[string]any) {
+	for column, value := range row {
+		if bs, ok := value.([]byte); ok {
+			row[column] = string(bs)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package import_effect
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"excel_import/tree_framework"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+type effectProduct struct {
+	Code  string  `gorm:"column:code;primaryKey"`
+	Price float64 `gorm:"column:price"`
+}
+
+func (p *effectProduct) TableName() string {
+	return "effect_products"
+}
+
+func TestTarget_ResolveUpdate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "effect.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, wheres := map[string]any{"price": 1}, map[string]any{"code": "P1"}
+
+	// the section without the update effect
+	target := NewTarget()
+	if update, err := target.ResolveUpdate(db, &general_framework.RawContent{}); err != nil || update != nil {
+		t.Fatalf("unexpected update %+v, err %v", update, err)
+	}
+
+	// the table of the update model is prior to the target
+	rc := &general_framework.RawContent{}
+	rc.SetUpdateModelCond(&effectProduct{}, updates, wheres)
+	update, err := target.ResolveUpdate(db, rc)
+	if err != nil || update.Table != "effect_products" || update.PrimaryKey != "code" {
+		t.Fatalf("unexpected update %+v, err %v", update, err)
+	}
+
+	rc = &general_framework.RawContent{}
+	rc.SetUpdateCond(updates, wheres)
+	if _, err = target.ResolveUpdate(db, rc); !errors.Is(err, ErrTableUnknown) {
+		t.Fatalf("expected the unknown table, got %v", err)
+	}
+	target.Table = "products"
+	if update, err = target.ResolveUpdate(db, rc); err != nil || update.Table != "products" || update.PrimaryKey != DefaultPrimaryKey {
+		t.Fatalf("unexpected update %+v, err %v", update, err)
+	}
+}
+
+func TestNodeTable(t *testing.T) {
+	root := tree_framework.NewRootNode()
+	food := root.AddChild("食品")
+	fruit := food.AddChild("水果")
+	food.SetID(1)
+	fruit.SetID(2)
+
+	tables := []string{"roots", "categories", "subcategories"}
+	if table, ok := NodeTable(tables, food); !ok || table != "categories" {
+		t.Fatalf("unexpected table %s of the first level", table)
+	}
+	if table, ok := NodeTable(tables[:2], fruit); !ok || table != "categories" {
+		t.Fatalf("the last table should be used for the rest levels, got %s", table)
+	}
+	if _, ok := NodeTable(tables, root); ok {
+		t.Fatal("the node without the id should be skipped")
+	}
+}
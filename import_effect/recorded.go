@@ -0,0 +1,59 @@
+package import_effect
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// ErrUnrecordedUpdate the importer updates the table directly without recording it,
+// so the previous values of the records are lost
+var ErrUnrecordedUpdate = errors.New("the direct update of the importer isn't recorded before executed")
+
+// RecordedUpdates the tables whose updates are recorded by the importers in the sections.
+// the direct update of the importer is allowed only if it's recorded before, since the middlewares only see the effects.
+type RecordedUpdates struct {
+	mu     sync.Mutex
+	tables map[*general_framework.RawContent]map[string]bool
+}
+
+// Mark mark the table is recorded in the section imported by the tx, ignored out of the importer
+func (r *RecordedUpdates) Mark(tx *gorm.DB, table string) {
+	rc := general_framework.GetSectionContent(tx)
+	if rc == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tables == nil {
+		r.tables = make(map[*general_framework.RawContent]map[string]bool)
+	}
+	if r.tables[rc] == nil {
+		r.tables[rc] = make(map[string]bool)
+	}
+	r.tables[rc][table] = true
+}
+
+// Check check if the table is recorded in the section, the unknown table matches any recorded table
+func (r *RecordedUpdates) Check(rc *general_framework.RawContent, table string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tables := r.tables[rc]
+	if len(table) == 0 {
+		return len(tables) > 0
+	}
+
+	return tables[table]
+}
+
+// Release release the tables of the imported section
+func (r *RecordedUpdates) Release(rc *general_framework.RawContent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tables, rc)
+}
@@ -0,0 +1,200 @@
+package import_spec
+
+import (
+	"errors"
+	util "excel_import/utils"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrRequired        = errors.New("required")
+	ErrPatternMismatch = errors.New("pattern mismatch")
+	ErrOutOfRange      = errors.New("out of range")
+	ErrInvalidBool     = errors.New("invalid bool")
+)
+
+// the strings parsed as the bool, besides the ones of strconv.ParseBool
+var boolValues = map[string]bool{
+	"是": true, "否": false,
+	"yes": true, "no": false,
+	"y": true, "n": false,
+}
+
+// columnConverter convert and validate the cell of the column
+type columnConverter struct {
+	column  *ColumnSpec
+	index   int
+	pattern *regexp.Regexp
+}
+
+func newColumnConverters(columns []*ColumnSpec, indexes []int) []*columnConverter {
+	converters := make([]*columnConverter, 0, len(columns))
+	for i, column := range columns {
+		converter := &columnConverter{column: column, index: indexes[i]}
+		if len(column.Pattern) > 0 {
+			converter.pattern = regexp.MustCompile(column.Pattern)
+		}
+		converters = append(converters, converter)
+	}
+
+	return converters
+}
+
+// convertRow convert the model into the values of the fields.
+// the empty cells without the default are omitted, so that the defaults of the table are used.
+// the errors are located to the cells.
+func convertRow(converters []*columnConverter, model any) (map[string]any, error) {
+	v := reflect.ValueOf(model).Elem()
+	values := make(map[string]any)
+	errBuilder := util.NewErrBuilder()
+	for i, converter := range converters {
+		cell := v.Field(i).String()
+		value, err := converter.convert(cell)
+		if err != nil {
+			errBuilder.AddCellWithContent(converter.index, cell, err)
+			continue
+		}
+
+		if value != nil && len(converter.column.Field) > 0 {
+			values[converter.column.Field] = value
+		}
+	}
+
+	if err := errBuilder.Build(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// convert apply the converters and the validations on the cell, nil if the cell is empty
+func (c *columnConverter) convert(cell string) (any, error) {
+	for _, name := range c.column.Converters {
+		cell = applyConverter(name, cell, c.column.Mapping)
+	}
+	if len(cell) == 0 {
+		cell = c.column.Default
+	}
+
+	if len(cell) == 0 {
+		if c.column.Required {
+			return nil, ErrRequired
+		}
+		return nil, nil
+	}
+
+	if len(c.column.Enum) > 0 {
+		if err := util.CheckIsEnum(cell, c.column.Enum); err != nil {
+			return nil, err
+		}
+	}
+	if c.pattern != nil && !c.pattern.MatchString(cell) {
+		return nil, fmt.Errorf("%w: %s", ErrPatternMismatch, c.column.Pattern)
+	}
+
+	value, err := c.parse(cell)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.checkRange(value, cell); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (c *columnConverter) parse(cell string) (any, error) {
+	switch c.column.Type {
+	case ColumnTypeInt:
+		v, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return nil, util.ErrInvalidInt
+		}
+		return v, nil
+	case ColumnTypeFloat:
+		v, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, util.ErrInvalidFloat
+		}
+		return v, nil
+	case ColumnTypeBool:
+		if v, ok := boolValues[strings.ToLower(cell)]; ok {
+			return v, nil
+		}
+		v, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, ErrInvalidBool
+		}
+		return v, nil
+	case ColumnTypeTime:
+		if len(c.column.Layout) > 0 {
+			return time.ParseInLocation(c.column.Layout, cell, time.Local)
+		}
+		return util.ParseTime(cell)
+	default:
+		return cell, nil
+	}
+}
+
+// checkRange check the range of the number, or the range of the length of the string
+func (c *columnConverter) checkRange(value any, cell string) error {
+	if c.column.Min == nil && c.column.Max == nil {
+		return nil
+	}
+
+	var n float64
+	switch v := value.(type) {
+	case int64:
+		n = float64(v)
+	case float64:
+		n = v
+	case string:
+		n = float64(utf8.RuneCountInString(v))
+	default:
+		return nil
+	}
+
+	if (c.column.Min != nil && n < *c.column.Min) || (c.column.Max != nil && n > *c.column.Max) {
+		return fmt.Errorf("%w: %s not in [%s, %s]", ErrOutOfRange, cell, formatBound(c.column.Min), formatBound(c.column.Max))
+	}
+
+	return nil
+}
+
+func formatBound(bound *float64) string {
+	if bound == nil {
+		return "-"
+	}
+
+	return strconv.FormatFloat(*bound, 'f', -1, 64)
+}
+
+func applyConverter(name, cell string, mapping map[string]string) string {
+	switch name {
+	case ConverterUpper:
+		return strings.ToUpper(cell)
+	case ConverterLower:
+		return strings.ToLower(cell)
+	case ConverterStripSpaces:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, cell)
+	case ConverterMap:
+		if v, ok := mapping[cell]; ok {
+			return v
+		}
+	}
+
+	return cell
+}
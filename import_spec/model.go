@@ -0,0 +1,111 @@
+package import_spec
+
+type ColumnType string
+type ImportMode string
+type Format string
+
+const (
+	ColumnTypeString ColumnType = "string"
+	ColumnTypeInt    ColumnType = "int"
+	ColumnTypeFloat  ColumnType = "float"
+	ColumnTypeBool   ColumnType = "bool"
+	ColumnTypeTime   ColumnType = "time"
+
+	ImportModeInsert ImportMode = "insert"
+	ImportModeUpdate ImportMode = "update"
+	ImportModeUpsert ImportMode = "upsert"
+
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+
+	ConverterUpper = "upper"
+	ConverterLower = "lower"
+	// remove all the spaces of the cell
+	ConverterStripSpaces = "strip_spaces"
+	// replace the cell by the mapping
+	ConverterMap = "map"
+)
+
+// Spec the declarative import of a sheet into a table
+type Spec struct {
+	// the name of the import, used in the messages
+	Name    string        `json:"name" yaml:"name"`
+	Source  SourceSpec    `json:"source" yaml:"source"`
+	Columns []*ColumnSpec `json:"columns" yaml:"columns"`
+	Target  TargetSpec    `json:"target" yaml:"target"`
+	Expect  *ExpectSpec   `json:"expect" yaml:"expect"`
+}
+
+// SourceSpec where the rows are read from
+type SourceSpec struct {
+	// the sheet of the xlsx file, all the sheets are read if empty
+	Sheet string `json:"sheet" yaml:"sheet"`
+	// the row of the header, start from 0. the header names of the columns are resolved from it
+	HeaderRow int `json:"header_row" yaml:"header_row"`
+	// the first row of the data, start from 0. the row after the header by default
+	StartRow *int `json:"start_row" yaml:"start_row"`
+	// the end condition, the row whose first cell is empty ends the data by default
+	End *EndSpec `json:"end" yaml:"end"`
+}
+
+// EndSpec the data ends at the first row whose cell of the column equals the value
+type EndSpec struct {
+	// the header name of the column
+	Header string `json:"header" yaml:"header"`
+	// the column index, used if the header is empty
+	Index int `json:"index" yaml:"index"`
+	// the value which ends the data, the empty cell by default
+	Value string `json:"value" yaml:"value"`
+}
+
+// ColumnSpec the column of the sheet
+type ColumnSpec struct {
+	// the header name of the column
+	Header string `json:"header" yaml:"header"`
+	// the column index, resolved by the header if not set
+	Index *int `json:"index" yaml:"index"`
+	// the type of the cell, string by default
+	Type ColumnType `json:"type" yaml:"type"`
+	// the format check function, e.g. int, float, cn, url
+	FCF string `json:"fcf" yaml:"fcf"`
+	// the target column which the cell is imported into, not imported if empty
+	Field string `json:"field" yaml:"field"`
+
+	// the validations
+	Required bool     `json:"required" yaml:"required"`
+	Enum     []string `json:"enum" yaml:"enum"`
+	// the regular expression which the cell should match
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// the range of the number, or the range of the length of the string
+	Min *float64 `json:"min" yaml:"min"`
+	Max *float64 `json:"max" yaml:"max"`
+
+	// the converters applied in order before the validations, e.g. upper, lower, strip_spaces, map
+	Converters []string `json:"converters" yaml:"converters"`
+	// the mapping of the map converter
+	Mapping map[string]string `json:"mapping" yaml:"mapping"`
+	// the value of the empty cell
+	Default string `json:"default" yaml:"default"`
+	// the layout of the time cell, the common layouts are tried if empty
+	Layout string `json:"layout" yaml:"layout"`
+}
+
+// TargetSpec the table which the rows are imported into
+type TargetSpec struct {
+	Table string `json:"table" yaml:"table"`
+	// insert by default
+	Mode ImportMode `json:"mode" yaml:"mode"`
+	// the target columns which identify the record, required by the update and upsert mode
+	Keys []string `json:"keys" yaml:"keys"`
+}
+
+// ExpectSpec the expectations of the correctness
+type ExpectSpec struct {
+	// the range of the data rows, no limit if 0
+	MinRows int `json:"min_rows" yaml:"min_rows"`
+	MaxRows int `json:"max_rows" yaml:"max_rows"`
+	// the target columns whose values should be unique in the sheet
+	Unique []string `json:"unique" yaml:"unique"`
+	// the expected change of the table count after the import
+	CountDelta *int64 `json:"count_delta" yaml:"count_delta"`
+}
@@ -0,0 +1,310 @@
+package import_spec
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDuplicated        = errors.New("duplicated")
+	ErrRowsUnexpected    = errors.New("unexpected rows count")
+	ErrCountDeltaFailed  = errors.New("unexpected table count delta")
+	errMissingKey        = errors.New("missing key value")
+	errHeaderRowNotFound = errors.New("header row not found")
+	errStartRowNotFound  = errors.New("start row not found")
+)
+
+// Runtime drive the general framework by the spec.
+// the framework options are applied after the ones of the spec, e.g. WithRecorder, WithProgressReporter.
+type Runtime struct {
+	spec    *Spec
+	db      *gorm.DB
+	options []general_framework.OptionFunc
+	checker *rowChecker
+}
+
+func NewRuntime(spec *Spec, db *gorm.DB, options ...general_framework.OptionFunc) *Runtime {
+	return &Runtime{
+		spec:    spec,
+		db:      db,
+		options: options,
+	}
+}
+
+// Validate only parse and check the file by the spec
+func (r *Runtime) Validate(path string) error {
+	framework, err := r.newFramework(path, general_framework.WithDryRun())
+	if err != nil {
+		return err
+	}
+
+	if err = framework.Import(path); err != nil {
+		return err
+	}
+
+	// the middlewares aren't called in the dry run
+	return r.checker.checkRows()
+}
+
+// Import check and import the file by the spec, the count delta of the table is checked after the import.
+func (r *Runtime) Import(path string) error {
+	framework, err := r.newFramework(path)
+	if err != nil {
+		return err
+	}
+
+	expect := r.spec.Expect
+	if expect != nil && expect.CountDelta != nil {
+		if err = framework.EnableCorrectnessCheck(&countDeltaChecker{table: r.spec.Target.Table, delta: *expect.CountDelta}); err != nil {
+			return err
+		}
+	}
+
+	if err = framework.Import(path); err != nil {
+		return err
+	}
+
+	return framework.CheckCorrect()
+}
+
+// GetRows get the count of the checked rows of the last run
+func (r *Runtime) GetRows() int {
+	if r.checker == nil {
+		return 0
+	}
+
+	return r.checker.rows
+}
+
+func (r *Runtime) newFramework(path string, options ...general_framework.OptionFunc) (*general_framework.ImportFramework, error) {
+	source := r.spec.Source
+	content, err := util.ReadExcelSheetContent(path, source.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	if source.HeaderRow >= len(content) {
+		return nil, fmt.Errorf("%w: %d", errHeaderRowNotFound, source.HeaderRow)
+	}
+
+	if startRow := r.spec.getStartRow(); startRow > len(content) {
+		return nil, fmt.Errorf("%w: %d", errStartRowNotFound, startRow)
+	}
+
+	header := content[source.HeaderRow]
+	indexes, err := r.spec.resolveIndexes(header)
+	if err != nil {
+		return nil, err
+	}
+	ef, err := r.spec.endFunc(header)
+	if err != nil {
+		return nil, err
+	}
+
+	converters := newColumnConverters(r.spec.Columns, indexes)
+	r.checker = newRowChecker(r.spec, converters)
+	importer := &specImporter{target: &r.spec.Target, converters: converters}
+
+	frameworkOptions := []general_framework.OptionFunc{
+		general_framework.WithSimpleModelFactory(r.spec.newModel(indexes)),
+		general_framework.WithSheetName(source.Sheet),
+		general_framework.WithStartRow(r.spec.getStartRow()),
+		general_framework.WithEndFunc(ef),
+		general_framework.WithOneSectionCheckers(r.checker),
+		general_framework.WithMiddlewares(r.checker),
+	}
+	frameworkOptions = append(frameworkOptions, r.options...)
+	frameworkOptions = append(frameworkOptions, options...)
+
+	return general_framework.NewImporterOneSectionFramework(r.db, importer, frameworkOptions...), nil
+}
+
+// rowChecker convert and check the rows, and check the expectations of the rows
+type rowChecker struct {
+	expect     *ExpectSpec
+	converters []*columnConverter
+	rows       int
+	// the first rows of the unique values by the field
+	uniqueRows map[string]map[any]int
+}
+
+func newRowChecker(spec *Spec, converters []*columnConverter) *rowChecker {
+	checker := &rowChecker{
+		expect:     spec.Expect,
+		converters: converters,
+		uniqueRows: make(map[string]map[any]int),
+	}
+	if spec.Expect != nil {
+		for _, field := range spec.Expect.Unique {
+			checker.uniqueRows[field] = make(map[any]int)
+		}
+	}
+
+	return checker
+}
+
+func (c *rowChecker) CheckValid(s *general_framework.RawContent) error {
+	c.rows++
+
+	values, err := convertRow(c.converters, s.GetModel())
+	if err != nil {
+		return err
+	}
+
+	errBuilder := util.NewErrBuilder()
+	for field, rows := range c.uniqueRows {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+
+		if row, ok := rows[value]; ok {
+			errBuilder.Add(fmt.Errorf("%w: %s %v is the same as the row %d", ErrDuplicated, field, value, row+1))
+			continue
+		}
+		rows[value] = s.GetRow()
+	}
+
+	return errBuilder.Build()
+}
+
+// checkRows check the count of the rows is expected
+func (c *rowChecker) checkRows() error {
+	if c.expect == nil {
+		return nil
+	}
+
+	if c.rows < c.expect.MinRows || (c.expect.MaxRows > 0 && c.rows > c.expect.MaxRows) {
+		return fmt.Errorf("%w: %d not in [%d, %d]", ErrRowsUnexpected, c.rows, c.expect.MinRows, c.expect.MaxRows)
+	}
+
+	return nil
+}
+
+func (c *rowChecker) PreImportHandle(tx *gorm.DB, whole *general_framework.RawWhole) error {
+	return c.checkRows()
+}
+
+func (c *rowChecker) PostImportSectionHandle(tx *gorm.DB, s *general_framework.RawContent) error {
+	return nil
+}
+
+func (c *rowChecker) PostHandle(tx *gorm.DB) error {
+	return nil
+}
+
+// specImporter import the row into the target table by the mode
+type specImporter struct {
+	target     *TargetSpec
+	converters []*columnConverter
+}
+
+func (si *specImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	values, err := convertRow(si.converters, s.GetModel())
+	if err != nil {
+		return err
+	}
+
+	switch si.target.Mode {
+	case ImportModeUpdate:
+		return si.update(tx, values)
+	case ImportModeUpsert:
+		return si.upsert(tx, values)
+	default:
+		return tx.Table(si.target.Table).Create(values).Error
+	}
+}
+
+// update update the record identified by the keys, the record should exist
+func (si *specImporter) update(tx *gorm.DB, values map[string]any) error {
+	wheres, updates, err := si.splitKeys(values)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err = tx.Table(si.target.Table).Where(wheres).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %v", gorm.ErrRecordNotFound, wheres)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return tx.Table(si.target.Table).Where(wheres).Updates(updates).Error
+}
+
+// upsert insert the record, or update it if the keys conflict.
+// the keys should be the unique index of the table.
+func (si *specImporter) upsert(tx *gorm.DB, values map[string]any) error {
+	_, updates, err := si.splitKeys(values)
+	if err != nil {
+		return err
+	}
+
+	onConflict := clause.OnConflict{}
+	for _, key := range si.target.Keys {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: key})
+	}
+	if len(updates) == 0 {
+		onConflict.DoNothing = true
+	} else {
+		columns := make([]string, 0, len(updates))
+		for column := range updates {
+			columns = append(columns, column)
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(columns)
+	}
+
+	return tx.Table(si.target.Table).Clauses(onConflict).Create(values).Error
+}
+
+// splitKeys split the values into the keys and the others
+func (si *specImporter) splitKeys(values map[string]any) (map[string]any, map[string]any, error) {
+	keys := make(map[string]any, len(si.target.Keys))
+	for _, key := range si.target.Keys {
+		value, ok := values[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", errMissingKey, key)
+		}
+		keys[key] = value
+	}
+
+	others := make(map[string]any, len(values))
+	for field, value := range values {
+		if _, ok := keys[field]; !ok {
+			others[field] = value
+		}
+	}
+
+	return keys, others, nil
+}
+
+// countDeltaChecker check the count change of the table
+type countDeltaChecker struct {
+	table    string
+	delta    int64
+	preCount int64
+}
+
+func (c *countDeltaChecker) PreCollect(tx *gorm.DB) error {
+	return tx.Table(c.table).Count(&c.preCount).Error
+}
+
+func (c *countDeltaChecker) CheckCorrect(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Table(c.table).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count != c.preCount+c.delta {
+		return fmt.Errorf("%w: table %s expect %d, but got %d", ErrCountDeltaFailed, c.table, c.preCount+c.delta, count)
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package import_spec
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type specProduct struct {
+	ID        int64
+	Code      string `gorm:"uniqueIndex"`
+	Name      string
+	Price     float64
+	Stock     int64
+	OnSale    bool
+	Kind      string
+	CreatedAt time.Time
+}
+
+const productSpecYAML = `
+name: product
+source:
+  sheet: 商品
+  header_row: 1
+  end: {header: 编码, value: 合计}
+columns:
+  - {header: 编码, field: code, required: true, converters: [strip_spaces, upper], pattern: "^P[0-9]+$"}
+  - {header: 名称, field: name, required: true, max: 8}
+  - {header: 价格, type: float, field: price, min: 0}
+  - {header: 库存, type: int, fcf: int, field: stock, default: "0"}
+  - {header: 在售, type: bool, field: on_sale}
+  - {header: 类型, field: kind, converters: [map], mapping: {水果: fruit, 蔬菜: vegetable}, enum: [fruit, vegetable]}
+  - {header: 上架时间, type: time, layout: "2006-01-02", field: created_at}
+target:
+  table: spec_products
+  mode: upsert
+  keys: [code]
+expect:
+  min_rows: 1
+  unique: [code]
+`
+
+func openSpecDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "spec.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&specProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// writeProductWorkbook write the rows into the product sheet after the title and the header,
+// the other sheet is added before it.
+func writeProductWorkbook(t *testing.T, rows ...[]string) string {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if _, err := f.NewSheet("商品"); err != nil {
+		t.Fatal(err)
+	}
+	// the header is in another order than the spec
+	content := append([][]string{{"商品导入"}, {"类型", "名称", "编码", "价格", "库存", "在售", "上架时间"}}, rows...)
+	content = append(content, []string{"", "", "合计"})
+	for i, row := range content {
+		for j, cell := range row {
+			name, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			if err := f.SetCellStr("商品", name, cell); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := f.SetCellStr("Sheet1", "A1", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "products.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func newTestRuntime(t *testing.T, spec *Spec, db *gorm.DB) (*Runtime, *util.UnexpectedRecorder) {
+	dir := t.TempDir()
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+
+	return NewRuntime(spec, db, general_framework.WithRecorder(recorder),
+		general_framework.WithProgressReporter(util.NewProgressReporter(false))), recorder
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse([]byte(productSpecYAML), FormatYAML); err != nil {
+		t.Fatal(err)
+	}
+
+	json := `{"columns": [{"header": "名称", "field": "name"}], "target": {"table": "t", "mode": "update", "keys": ["name"]}}`
+	if _, err := Parse([]byte(json), FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	invalids := []string{
+		`columns: []`,
+		`columns: [{field: name}]`,
+		`columns: [{header: a, type: decimal}]`,
+		`columns: [{header: a, converters: [map]}]`,
+		`columns: [{header: a, field: name}]
+target: {mode: update}`,
+		`columns: [{header: a, field: name}]
+expect: {unique: [code]}`,
+		`columns: [{header: "a,b"}]`,
+		`columns: [{header: 'a"b'}]`,
+	}
+	for _, invalid := range invalids {
+		if _, err := Parse([]byte(invalid), FormatYAML); !errors.Is(err, ErrInvalidSpec) {
+			t.Fatalf("spec %s expected invalid, got %v", invalid, err)
+		}
+	}
+}
+
+func TestRuntime_StartRowNotFound(t *testing.T) {
+	spec, err := Parse([]byte(`{"columns": [{"header": "名称", "field": "name"}], "source": {"header_row": 0, "start_row": 5}}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "names.csv")
+	if err = util.WriteExcelContent(path, [][]string{{"名称"}, {"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime, _ := newTestRuntime(t, spec, openSpecDB(t))
+	if err = runtime.Validate(path); !errors.Is(err, errStartRowNotFound) {
+		t.Fatalf("expected start row not found, got %v", err)
+	}
+}
+
+func TestRuntime_Upsert(t *testing.T) {
+	spec, err := Parse([]byte(productSpecYAML), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openSpecDB(t)
+
+	path := writeProductWorkbook(t,
+		[]string{"水果", "苹果", " p1 ", "1.5", "10", "是", "2024-01-02"},
+		[]string{"蔬菜", "白菜", "P2", "2", "", "false", ""})
+	runtime, _ := newTestRuntime(t, spec, db)
+	if err = runtime.Import(path); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GetRows() != 2 {
+		t.Fatalf("got %d rows, expected 2", runtime.GetRows())
+	}
+
+	// the existing product is updated by the code
+	path = writeProductWorkbook(t, []string{"水果", "红苹果", "P1", "3", "5", "否", ""})
+	runtime, _ = newTestRuntime(t, spec, db)
+	if err = runtime.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var products []*specProduct
+	if err = db.Order("code").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("unexpected products %+v", products)
+	}
+	apple, cabbage := products[0], products[1]
+	if apple.Name != "红苹果" || apple.Price != 3 || apple.Stock != 5 || apple.OnSale || apple.Kind != "fruit" ||
+		apple.CreatedAt.Format("2006-01-02") != "2024-01-02" {
+		t.Fatalf("unexpected apple %+v", apple)
+	}
+	if cabbage.Stock != 0 || cabbage.Kind != "vegetable" {
+		t.Fatalf("unexpected cabbage %+v", cabbage)
+	}
+}
+
+func TestRuntime_CheckFailed(t *testing.T) {
+	spec, err := Parse([]byte(productSpecYAML), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeProductWorkbook(t,
+		[]string{"水果", "苹果", "P1", "1.5", "10", "是", ""},
+		[]string{"肉", "", "X3", "-1", "1.5", "maybe", "2024/13/01"},
+		[]string{"水果", "香蕉", "p1", "1", "1", "", ""})
+	runtime, recorder := newTestRuntime(t, spec, nil)
+	if err = runtime.Validate(path); err == nil {
+		t.Fatal("expected check error")
+	}
+
+	failures, err := util.ReadExcelContent(recorder.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+
+	// all the invalid cells of the row are reported
+	expected := []error{ErrPatternMismatch, ErrRequired, ErrOutOfRange, util.ErrInvalidInt, ErrInvalidBool, util.ErrInvalidEnum}
+	for _, err = range expected {
+		if !strings.Contains(failures[0][0], err.Error()) {
+			t.Fatalf("failure %s doesn't contain %v", failures[0][0], err)
+		}
+	}
+	if !strings.Contains(failures[1][0], ErrDuplicated.Error()) || !strings.Contains(failures[1][0], "row 3") {
+		t.Fatalf("unexpected duplicated failure %s", failures[1][0])
+	}
+}
+
+func TestRuntime_UpdateAndExpect(t *testing.T) {
+	db := openSpecDB(t)
+	if err := db.Create(&specProduct{Code: "P1", Name: "苹果"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P1", "9.9"}, {"P9", "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := Parse([]byte(`
+columns:
+  - {index: 0, field: code}
+  - {index: 1, type: float, field: price}
+target: {table: spec_products, mode: update, keys: [code]}
+expect: {max_rows: 1}
+`), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rows are more than expected
+	runtime, _ := newTestRuntime(t, spec, db)
+	if err = runtime.Validate(path); !errors.Is(err, ErrRowsUnexpected) {
+		t.Fatalf("expected rows error, got %v", err)
+	}
+	if err = runtime.Import(path); !errors.Is(err, ErrRowsUnexpected) {
+		t.Fatalf("expected rows error, got %v", err)
+	}
+
+	// the missing record fails the update
+	spec.Expect = nil
+	runtime, recorder := newTestRuntime(t, spec, db)
+	if err = runtime.Import(path); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	failures, err := util.ReadExcelContent(recorder.GetImportFailedPath())
+	if err != nil || len(failures) != 1 {
+		t.Fatalf("unexpected failures %v, err %v", failures, err)
+	}
+
+	product := &specProduct{}
+	if err = db.First(product, "code = ?", "P1").Error; err != nil || product.Price != 9.9 || product.Name != "苹果" {
+		t.Fatalf("unexpected product %+v, err %v", product, err)
+	}
+
+	// the update doesn't change the count
+	delta := int64(1)
+	spec.Expect = &ExpectSpec{CountDelta: &delta}
+	if err = util.WriteExcelContent(path, [][]string{{"编码", "价格"}, {"P1", "8"}}); err != nil {
+		t.Fatal(err)
+	}
+	runtime, _ = newTestRuntime(t, spec, db)
+	if err = runtime.Import(path); !errors.Is(err, ErrCountDeltaFailed) {
+		t.Fatalf("expected count delta error, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package import_spec
+
+import (
+	"encoding/json"
+	"errors"
+	"excel_import"
+	util "excel_import/utils"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	modelFieldPrefix = "F"
+	// the characters which can't be used in the exi tag values, the quote and the backslash break the struct tag
+	tagReservedChars = ",:\"\\"
+)
+
+var (
+	ErrInvalidSpec = errors.New("invalid import spec")
+)
+
+// Load load the spec from the yaml or json file, the format is decided by the extension.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := FormatYAML
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		format = FormatJSON
+	}
+
+	return Parse(data, format)
+}
+
+// Parse parse and check the spec
+func Parse(data []byte, format Format) (*Spec, error) {
+	spec := &Spec{}
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, spec)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, spec)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidSpec, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+
+	if err = spec.Check(); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// Check check the spec is complete and consistent
+func (s *Spec) Check() error {
+	if len(s.Columns) == 0 {
+		return s.invalid("columns are required")
+	}
+	if s.Source.HeaderRow < 0 || (s.Source.StartRow != nil && *s.Source.StartRow <= s.Source.HeaderRow) {
+		return s.invalid("start_row should be after header_row")
+	}
+
+	fields := make(map[string]bool)
+	for i, column := range s.Columns {
+		if err := s.checkColumn(i, column); err != nil {
+			return err
+		}
+		if len(column.Field) > 0 {
+			if fields[column.Field] {
+				return s.invalid("field %s is duplicated", column.Field)
+			}
+			fields[column.Field] = true
+		}
+	}
+
+	switch s.Target.Mode {
+	case "", ImportModeInsert:
+	case ImportModeUpdate, ImportModeUpsert:
+		if len(s.Target.Keys) == 0 {
+			return s.invalid("keys are required by the %s mode", s.Target.Mode)
+		}
+	default:
+		return s.invalid("unsupported mode %s", s.Target.Mode)
+	}
+	for _, key := range s.Target.Keys {
+		if !fields[key] {
+			return s.invalid("key %s is not a field of the columns", key)
+		}
+	}
+
+	if s.Expect != nil {
+		for _, field := range s.Expect.Unique {
+			if !fields[field] {
+				return s.invalid("unique %s is not a field of the columns", field)
+			}
+		}
+		if s.Expect.MaxRows > 0 && s.Expect.MinRows > s.Expect.MaxRows {
+			return s.invalid("min_rows is greater than max_rows")
+		}
+	}
+
+	return nil
+}
+
+func (s *Spec) checkColumn(i int, column *ColumnSpec) error {
+	if len(column.Header) == 0 && column.Index == nil {
+		return s.invalid("column %d requires header or index", i)
+	}
+	if column.Index != nil && *column.Index < 0 {
+		return s.invalid("column %d index should not be negative", i)
+	}
+
+	switch column.Type {
+	case "", ColumnTypeString, ColumnTypeInt, ColumnTypeFloat, ColumnTypeBool, ColumnTypeTime:
+	default:
+		return s.invalid("column %d has unsupported type %s", i, column.Type)
+	}
+
+	// the header and fcf are kept in the exi tag
+	for _, v := range []string{column.Header, column.FCF} {
+		if strings.ContainsAny(v, tagReservedChars) {
+			return s.invalid("column %d contains the reserved characters %q: %s", i, tagReservedChars, v)
+		}
+	}
+
+	if len(column.Pattern) > 0 {
+		if _, err := regexp.Compile(column.Pattern); err != nil {
+			return s.invalid("column %d pattern: %v", i, err)
+		}
+	}
+
+	for _, converter := range column.Converters {
+		switch converter {
+		case ConverterUpper, ConverterLower, ConverterStripSpaces:
+		case ConverterMap:
+			if len(column.Mapping) == 0 {
+				return s.invalid("column %d requires the mapping of the map converter", i)
+			}
+		default:
+			return s.invalid("column %d has unsupported converter %s", i, converter)
+		}
+	}
+
+	return nil
+}
+
+func (s *Spec) invalid(format string, args ...any) error {
+	return fmt.Errorf("%w %s: %s", ErrInvalidSpec, s.Name, fmt.Sprintf(format, args...))
+}
+
+func (s *Spec) getStartRow() int {
+	if s.Source.StartRow == nil {
+		return s.Source.HeaderRow + 1
+	}
+
+	return *s.Source.StartRow
+}
+
+// resolveIndexes resolve the column indexes by the header row
+func (s *Spec) resolveIndexes(header []string) ([]int, error) {
+	positions := make(map[string]int)
+	for i, name := range header {
+		name = util.FormatCell(name)
+		if _, ok := positions[name]; !ok && len(name) > 0 {
+			positions[name] = i
+		}
+	}
+
+	indexes := make([]int, 0, len(s.Columns))
+	for _, column := range s.Columns {
+		if column.Index != nil {
+			indexes = append(indexes, *column.Index)
+			continue
+		}
+
+		index, ok := positions[column.Header]
+		if !ok {
+			return nil, fmt.Errorf("%w %s: header %s not found", ErrInvalidSpec, s.Name, column.Header)
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// endFunc the end condition of the source
+func (s *Spec) endFunc(header []string) (excel_import.EndFunc, error) {
+	end := s.Source.End
+	if end == nil {
+		return util.DefaultRowEndFunc, nil
+	}
+
+	index := end.Index
+	if len(end.Header) > 0 {
+		index = -1
+		for i, name := range header {
+			if util.FormatCell(name) == end.Header {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w %s: end header %s not found", ErrInvalidSpec, s.Name, end.Header)
+		}
+	}
+
+	return func(row []string) bool {
+		var cell string
+		if index < len(row) {
+			cell = util.FormatCell(row[index])
+		}
+		return cell == end.Value
+	}, nil
+}
+
+// newModel create the model of the sheet, the field i is the raw cell of the column i.
+// the cells are kept as strings, so that the invalid cells are reported as the check errors.
+// the enum isn't tagged, since it's validated after the converters.
+func (s *Spec) newModel(indexes []int) any {
+	fields := make([]reflect.StructField, 0, len(s.Columns))
+	for i, column := range s.Columns {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("%s%d", modelFieldPrefix, i),
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(fmt.Sprintf(`exi:"%s"`, column.tag(indexes[i]))),
+		})
+	}
+
+	return reflect.New(reflect.StructOf(fields)).Interface()
+}
+
+func (c *ColumnSpec) tag(index int) string {
+	parts := []string{fmt.Sprintf("index:%d", index)}
+	if len(c.Header) > 0 {
+		parts = append(parts, "name:"+c.Header)
+	}
+	if len(c.FCF) > 0 {
+		parts = append(parts, "fcf:"+c.FCF)
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,337 @@
+package job_manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"excel_import"
+	"excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicateFile = errors.New("the identical file has been imported")
+	ErrJobNotFound   = errors.New("job not found")
+	// the importer of the dry run job doesn't report the dry run, so it may import the file
+	ErrDryRunIgnored = errors.New("the importer ignores the dry run")
+	errNilFactory    = errors.New("importer factory should not be nil")
+)
+
+const (
+	checkFailedFileName   = "check_failed.csv"
+	importFailedFileName  = "import_failed.csv"
+	unexpectedLogFileName = "unexpected.jsonl"
+	resultFileName        = "result.xlsx"
+)
+
+// JobManager runs the import jobs and persists the job records into the db.
+// the identical file is refused unless forced, and the async jobs run with bounded workers.
+type JobManager struct {
+	db         *gorm.DB
+	jobDir     string
+	maxWorkers int
+	workers    chan struct{}
+	// the envs of the running jobs, used for the live progress
+	running map[int64]*JobEnv
+	// guard the duplicate check and the running envs
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// WithMaxWorkers set the max count of the async jobs running at the same time
+func WithMaxWorkers(maxWorkers int) OptionFunc {
+	return func(manager *JobManager) {
+		manager.maxWorkers = maxWorkers
+	}
+}
+
+// WithJobDir set the directory of the failure files, the files of the job are in the sub directory named by the job id
+func WithJobDir(dir string) OptionFunc {
+	return func(manager *JobManager) {
+		manager.jobDir = dir
+	}
+}
+
+// NewJobManager create the job manager, the job table is migrated automatically.
+func NewJobManager(db *gorm.DB, options ...OptionFunc) (*JobManager, error) {
+	m := &JobManager{
+		db:         db,
+		jobDir:     defaultJobDir,
+		maxWorkers: defaultMaxWorkers,
+		running:    make(map[int64]*JobEnv),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	m.maxWorkers = max(m.maxWorkers, 1)
+	m.workers = make(chan struct{}, m.maxWorkers)
+
+	if err := db.AutoMigrate(&JobRecord{}); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Run run the job and wait for it finished.
+// the job record is returned even if the import failed, and the error is the import error.
+func (m *JobManager) Run(req *JobRequest) (*JobRecord, error) {
+	record, err := m.createJob(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.workers <- struct{}{}
+	defer func() { <-m.workers }()
+
+	return m.execute(req, record)
+}
+
+// RunAsync create the pending job and run it when a worker is available.
+// the returned record is the pending one, use GetJob to query the status.
+func (m *JobManager) RunAsync(req *JobRequest) (*JobRecord, error) {
+	record, err := m.createJob(req)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := *record
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		m.workers <- struct{}{}
+		defer func() { <-m.workers }()
+
+		_, _ = m.execute(req, record)
+	}()
+
+	return &pending, nil
+}
+
+// Wait wait for all the async jobs finished
+func (m *JobManager) Wait() {
+	m.wg.Wait()
+}
+
+// GetJob get the job record, the counts of the running job are the live progress.
+func (m *JobManager) GetJob(id int64) (*JobRecord, error) {
+	record := &JobRecord{}
+	if err := m.db.First(record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	m.mu.Lock()
+	env, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		fillProgress(record, env.ProgressReporter)
+	}
+
+	return record, nil
+}
+
+// GetJobEnv get the env of the running job, false if the job isn't running
+func (m *JobManager) GetJobEnv(id int64) (*JobEnv, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	env, ok := m.running[id]
+	return env, ok
+}
+
+// ListJobs list the job records in the created order, filtered by the status if set.
+func (m *JobManager) ListJobs(status ...JobStatus) ([]*JobRecord, error) {
+	query := m.db.Order("id")
+	if len(status) > 0 {
+		query = query.Where("status IN ?", status)
+	}
+
+	var records []*JobRecord
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// createJob create the pending job record.
+// the identical file which is imported or importing is refused unless forced.
+func (m *JobManager) createJob(req *JobRequest) (*JobRecord, error) {
+	if req.Factory == nil {
+		return nil, errNilFactory
+	}
+
+	hash, err := hashFile(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !req.Force && !req.DryRun {
+		var count int64
+		if err = m.db.Model(&JobRecord{}).
+			Where("file_hash = ? AND dry_run = ? AND status IN ?", hash, false, []JobStatus{JobStatusPending, JobStatusRunning, JobStatusSuccess}).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateFile, req.Path)
+		}
+	}
+
+	record := &JobRecord{
+		Name:      req.Name,
+		FilePath:  req.Path,
+		FileHash:  hash,
+		Operator:  req.Operator,
+		Status:    JobStatusPending,
+		DryRun:    req.DryRun,
+		CreatedAt: time.Now(),
+	}
+	if err = m.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// execute run the import of the job and persist the result
+func (m *JobManager) execute(req *JobRequest, record *JobRecord) (result *JobRecord, err error) {
+	dir := filepath.Join(m.jobDir, strconv.FormatInt(record.ID, 10))
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return record, m.finish(record, nil, err)
+	}
+
+	env := &JobEnv{
+		JobID: record.ID,
+		Recorder: util.NewUnexpectedRecorder(filepath.Join(dir, checkFailedFileName),
+			filepath.Join(dir, importFailedFileName), filepath.Join(dir, unexpectedLogFileName)),
+		ProgressReporter: util.NewProgressReporter(false),
+		Path:             req.Path,
+		Dir:              dir,
+		ResultPath:       filepath.Join(dir, resultFileName),
+		DryRun:           req.DryRun,
+	}
+
+	now := time.Now()
+	record.Status = JobStatusRunning
+	record.StartedAt = &now
+	if err = m.db.Save(record).Error; err != nil {
+		return record, m.abandon(record, err)
+	}
+
+	m.mu.Lock()
+	m.running[record.ID] = env
+	m.mu.Unlock()
+
+	// the panic of the importer fails the job instead of the manager
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = record, m.finish(record, env, fmt.Errorf("import panic: %v", r))
+		}
+	}()
+
+	importer := req.Factory(env)
+	if req.DryRun {
+		if dryRunner, ok := importer.(excel_import.DryRunImporter); !ok || !dryRunner.CheckDryRun() {
+			return record, m.finish(record, env, ErrDryRunIgnored)
+		}
+	}
+
+	importErr := importer.Import(req.Path)
+	return record, m.finish(record, env, importErr)
+}
+
+// finish persist the result of the job, return the import error
+func (m *JobManager) finish(record *JobRecord, env *JobEnv, importErr error) error {
+	// the files of the job recorder are closed with the job
+	if env != nil {
+		if err := env.Recorder.Close(); err != nil {
+			importErr = errors.Join(importErr, err)
+		}
+	}
+
+	now := time.Now()
+	record.FinishedAt = &now
+	record.Status = JobStatusSuccess
+	if importErr != nil {
+		record.Status = JobStatusFailed
+		record.Error = importErr.Error()
+	}
+
+	if env != nil {
+		fillProgress(record, env.ProgressReporter)
+		record.CheckFailedPath = existingPath(env.Recorder.GetCheckFailedPath())
+		record.ImportFailedPath = existingPath(env.Recorder.GetImportFailedPath())
+		record.ResultPath = existingPath(env.ResultPath)
+
+		m.mu.Lock()
+		delete(m.running, record.ID)
+		m.mu.Unlock()
+	}
+
+	if err := m.db.Save(record).Error; err != nil {
+		return errors.Join(importErr, err)
+	}
+
+	return importErr
+}
+
+// abandon fail the job which can't be started, since the pending record refuses the identical file forever.
+// the record is deleted if it can't be failed either.
+func (m *JobManager) abandon(record *JobRecord, err error) error {
+	now := time.Now()
+	record.Status = JobStatusFailed
+	record.Error = err.Error()
+	record.FinishedAt = &now
+	if saveErr := m.db.Save(record).Error; saveErr != nil {
+		return errors.Join(err, saveErr, m.db.Delete(&JobRecord{}, record.ID).Error)
+	}
+
+	return err
+}
+
+func fillProgress(record *JobRecord, reporter *util.ProgressReporter) {
+	record.Total = reporter.GetTotal()
+	record.Success = reporter.GetSuccess()
+	record.Failed = reporter.GetFailed()
+	record.Retry = reporter.GetRetry()
+}
+
+func existingPath(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,283 @@
+package job_manager
+
+import (
+	"errors"
+	"excel_import"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type jobExcelModel struct {
+	Name string `exi:"index:0"`
+}
+
+type jobImporter struct {
+	running, maxRunning *atomic.Int32
+	delay               time.Duration
+}
+
+func (ji *jobImporter) ImportSection(tx *gorm.DB, s *general_framework.RawContent) error {
+	if s.GetModel().(*jobExcelModel).Name == "bad" {
+		return errors.New("bad name")
+	}
+
+	if ji.running != nil {
+		running := ji.running.Add(1)
+		defer ji.running.Add(-1)
+		for {
+			maxRunning := ji.maxRunning.Load()
+			if running <= maxRunning || ji.maxRunning.CompareAndSwap(maxRunning, running) {
+				break
+			}
+		}
+	}
+	time.Sleep(ji.delay)
+
+	return nil
+}
+
+func newJobFactory(importer *jobImporter) ImporterFactory {
+	return func(env *JobEnv) excel_import.ExcelImporter {
+		return general_framework.NewImporterOneSectionFramework(nil, importer,
+			general_framework.WithSimpleModelFactory(&jobExcelModel{}),
+			general_framework.WithRecorder(env.Recorder),
+			general_framework.WithProgressReporter(env.ProgressReporter))
+	}
+}
+
+func newTestJobManager(t *testing.T, options ...OptionFunc) (*JobManager, string) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "job.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite allows only one writer
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	manager, err := NewJobManager(db, append([]OptionFunc{WithJobDir(filepath.Join(dir, "jobs"))}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manager, dir
+}
+
+func writeJobFile(t *testing.T, dir, name string, rows ...string) string {
+	path := filepath.Join(dir, name)
+	content := [][]string{{"名称"}}
+	for _, row := range rows {
+		content = append(content, []string{row})
+	}
+	if err := util.WriteExcelContent(path, content); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestJobManager_Run(t *testing.T) {
+	manager, dir := newTestJobManager(t)
+	path := writeJobFile(t, dir, "job.csv", "a", "b")
+	factory := newJobFactory(&jobImporter{})
+
+	record, err := manager.Run(&JobRequest{Name: "product", Path: path, Operator: "tester", Factory: factory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != JobStatusSuccess || record.Total != 2 || record.Success != 2 || len(record.FileHash) == 0 ||
+		record.StartedAt == nil || record.FinishedAt == nil {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// the identical file is refused unless forced
+	if _, err = manager.Run(&JobRequest{Path: path, Factory: factory}); !errors.Is(err, ErrDuplicateFile) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+	if _, err = manager.Run(&JobRequest{Path: path, Factory: factory, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := manager.GetJob(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != JobStatusSuccess || stored.Operator != "tester" || stored.Name != "product" {
+		t.Fatalf("unexpected stored record: %+v", stored)
+	}
+	if _, err = manager.GetJob(100); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestJobManager_RunFailed(t *testing.T) {
+	manager, dir := newTestJobManager(t)
+	path := writeJobFile(t, dir, "job_failed.csv", "a", "bad")
+	factory := newJobFactory(&jobImporter{})
+
+	record, err := manager.Run(&JobRequest{Path: path, Factory: factory})
+	if err == nil {
+		t.Fatal("expected import error")
+	}
+	if record.Status != JobStatusFailed || record.Failed != 1 || len(record.ImportFailedPath) == 0 || len(record.CheckFailedPath) != 0 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	failures, err := util.ReadExcelContent(record.ImportFailedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+
+	// the failed file can be imported again
+	if _, err = manager.Run(&JobRequest{Path: path, Factory: factory}); errors.Is(err, ErrDuplicateFile) {
+		t.Fatal("the failed file should be allowed to import again")
+	}
+}
+
+type panicImporter struct {
+	recorder *util.UnexpectedRecorder
+}
+
+func (pi *panicImporter) Import(string) error {
+	if err := pi.recorder.RecordCheckError(errors.New("bad row")); err != nil {
+		return err
+	}
+	panic("importer panic")
+}
+
+func TestJobManager_RunPanic(t *testing.T) {
+	manager, dir := newTestJobManager(t)
+	path := writeJobFile(t, dir, "job_panic.csv", "a")
+	factory := func(env *JobEnv) excel_import.ExcelImporter {
+		return &panicImporter{recorder: env.Recorder}
+	}
+
+	record, err := manager.Run(&JobRequest{Path: path, Factory: factory})
+	if err == nil || record == nil {
+		t.Fatalf("expected the failed record of the panic, got %+v, err %v", record, err)
+	}
+	if record.Status != JobStatusFailed || len(record.CheckFailedPath) == 0 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// the recorded check errors are flushed although the importer didn't flush them
+	failures, err := util.ReadExcelContent(record.CheckFailedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0][0] != "bad row" {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+}
+
+func TestJobManager_RunStartFailed(t *testing.T) {
+	manager, dir := newTestJobManager(t)
+	path := writeJobFile(t, dir, "job_start_failed.csv", "a")
+	factory := newJobFactory(&jobImporter{})
+
+	// fail the update which marks the job running
+	errStart := errors.New("start failed")
+	if err := manager.db.Callback().Update().Before("gorm:update").Register("test:fail_start", func(tx *gorm.DB) {
+		if record, ok := tx.Statement.Dest.(*JobRecord); ok && record.Status == JobStatusRunning {
+			_ = tx.AddError(errStart)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := manager.Run(&JobRequest{Path: path, Factory: factory})
+	if !errors.Is(err, errStart) {
+		t.Fatalf("expected start error, got %v", err)
+	}
+	stored, err := manager.GetJob(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != JobStatusFailed || stored.Error != errStart.Error() {
+		t.Fatalf("unexpected stored record: %+v", stored)
+	}
+
+	// the job which isn't started doesn't refuse the identical file
+	if err = manager.db.Callback().Update().Remove("test:fail_start"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = manager.Run(&JobRequest{Path: path, Factory: factory}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobManager_RunDryRun(t *testing.T) {
+	manager, dir := newTestJobManager(t)
+	path := writeJobFile(t, dir, "job_dry_run.csv", "a")
+	importer := &jobImporter{running: &atomic.Int32{}, maxRunning: &atomic.Int32{}}
+
+	// the factory which ignores the dry run fails the job before the import
+	record, err := manager.Run(&JobRequest{Path: path, Factory: newJobFactory(importer), DryRun: true})
+	if !errors.Is(err, ErrDryRunIgnored) {
+		t.Fatalf("expected dry run ignored error, got %v", err)
+	}
+	if record.Status != JobStatusFailed || importer.maxRunning.Load() != 0 {
+		t.Fatalf("unexpected record: %+v, imported %d", record, importer.maxRunning.Load())
+	}
+
+	dryRunFactory := func(env *JobEnv) excel_import.ExcelImporter {
+		return general_framework.NewImporterOneSectionFramework(nil, importer,
+			general_framework.WithSimpleModelFactory(&jobExcelModel{}),
+			general_framework.WithRecorder(env.Recorder),
+			general_framework.WithProgressReporter(env.ProgressReporter),
+			general_framework.WithDryRun())
+	}
+	if record, err = manager.Run(&JobRequest{Path: path, Factory: dryRunFactory, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != JobStatusSuccess || !record.DryRun || importer.maxRunning.Load() != 0 {
+		t.Fatalf("unexpected record: %+v, imported %d", record, importer.maxRunning.Load())
+	}
+}
+
+func TestJobManager_RunAsync(t *testing.T) {
+	manager, dir := newTestJobManager(t, WithMaxWorkers(2))
+	importer := &jobImporter{running: &atomic.Int32{}, maxRunning: &atomic.Int32{}, delay: 20 * time.Millisecond}
+	factory := newJobFactory(importer)
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		path := writeJobFile(t, dir, fmt.Sprintf("job_async_%d.csv", i), fmt.Sprintf("name%d", i))
+		record, err := manager.RunAsync(&JobRequest{Path: path, Factory: factory})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Status != JobStatusPending {
+			t.Fatalf("unexpected async record: %+v", record)
+		}
+
+		ids = append(ids, record.ID)
+	}
+	manager.Wait()
+
+	if importer.maxRunning.Load() > 2 {
+		t.Fatalf("%d jobs ran at the same time, expected 2 at most", importer.maxRunning.Load())
+	}
+
+	records, err := manager.ListJobs(JobStatusSuccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(ids) {
+		t.Fatalf("got %d success jobs, expected %d", len(records), len(ids))
+	}
+}
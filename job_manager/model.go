@@ -0,0 +1,93 @@
+package job_manager
+
+import (
+	"excel_import"
+	util "excel_import/utils"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "pending"
+	JobStatusRunning JobStatus = "running"
+	JobStatusSuccess JobStatus = "success"
+	JobStatusFailed  JobStatus = "failed"
+
+	defaultMaxWorkers = 4
+	defaultJobDir     = "import_jobs"
+	jobTableName      = "excel_import_job"
+)
+
+type OptionFunc func(*JobManager)
+
+// JobRecord the persistent record of the import job
+type JobRecord struct {
+	ID int64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	// the name of the job, e.g. the importer name
+	Name     string    `gorm:"column:name;size:128" json:"name"`
+	FilePath string    `gorm:"column:file_path;size:1024" json:"file_path"`
+	FileHash string    `gorm:"column:file_hash;size:64;index" json:"file_hash"`
+	Operator string    `gorm:"column:operator;size:128" json:"operator"`
+	Status   JobStatus `gorm:"column:status;size:16;index" json:"status"`
+	// the dry run only parses and checks the file
+	DryRun bool `gorm:"column:dry_run" json:"dry_run"`
+	// the error message of the failed job
+	Error string `gorm:"column:error;type:text" json:"error"`
+	// the counts of the progress
+	Total   int `gorm:"column:total" json:"total"`
+	Success int `gorm:"column:success" json:"success"`
+	Failed  int `gorm:"column:failed" json:"failed"`
+	Retry   int `gorm:"column:retry" json:"retry"`
+	// the locations of the failure files, empty if not recorded
+	CheckFailedPath  string `gorm:"column:check_failed_path;size:1024" json:"check_failed_path"`
+	ImportFailedPath string `gorm:"column:import_failed_path;size:1024" json:"import_failed_path"`
+	// the location of the annotated workbook, empty if not written
+	ResultPath string     `gorm:"column:result_path;size:1024" json:"result_path"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`
+}
+
+func (JobRecord) TableName() string {
+	return jobTableName
+}
+
+// CheckFinished check if the job is finished
+func (r *JobRecord) CheckFinished() bool {
+	return r.Status == JobStatusSuccess || r.Status == JobStatusFailed
+}
+
+// JobEnv the environment of the job.
+// the recorder and the progress reporter should be passed into the framework,
+// so the failure files and the counts are kept in the job record.
+type JobEnv struct {
+	JobID            int64
+	Recorder         *util.UnexpectedRecorder
+	ProgressReporter *util.ProgressReporter
+	// the path of the imported file
+	Path string
+	// the directory of the job files
+	Dir string
+	// the path which the annotated workbook should be written into, e.g. by the result annotation middleware
+	ResultPath string
+	// only parse and check the file, e.g. by the WithDryRun option
+	DryRun bool
+}
+
+// ImporterFactory create the importer of the job with the env.
+// e.g. the general framework with WithRecorder and WithProgressReporter options.
+type ImporterFactory func(env *JobEnv) excel_import.ExcelImporter
+
+// JobRequest the request of the import job
+type JobRequest struct {
+	Name     string
+	Path     string
+	Operator string
+	// import even if the identical file has been imported
+	Force bool
+	// only parse and check the file, the dry run isn't counted as the imported file.
+	// the importer should implement excel_import.DryRunImporter and report the dry run, otherwise the job fails.
+	DryRun  bool
+	Factory ImporterFactory
+}
@@ -0,0 +1,123 @@
+package mirror_sync
+
+import (
+	"excel_import/general_framework"
+	"fmt"
+	"strings"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeInsert ChangeType = "insert"
+	ChangeTypeUpdate ChangeType = "update"
+	ChangeTypeDelete ChangeType = "delete"
+
+	// the deletions are refused by default
+	defaultMaxDeletes = 0
+	// no limit of the deletions
+	UnlimitedDeletes = -1
+)
+
+type OptionFunc func(*Syncer)
+
+// FieldDiff the different field of the updated record
+type FieldDiff struct {
+	Column string
+	Before any
+	After  any
+}
+
+// Change the change of the record
+type Change struct {
+	Type ChangeType
+	// the row of the file, start from 0. -1 for the deletion
+	Row int
+	// the values of the key columns
+	Key map[string]any
+	// the parsed model for the insertion and the update, the current model for the deletion
+	Model any
+	// the current model for the update
+	Current any
+	// the different fields for the update
+	Diffs []*FieldDiff
+}
+
+// Plan the changes which make the table the same as the file in the scope
+type Plan struct {
+	Table     string
+	Keys      []string
+	Inserts   []*Change
+	Updates   []*Change
+	Deletes   []*Change
+	Unchanged int
+}
+
+// CheckEmpty check if the table is the same as the file
+func (p *Plan) CheckEmpty() bool {
+	return len(p.Inserts) == 0 && len(p.Updates) == 0 && len(p.Deletes) == 0
+}
+
+// Preview the readable changes of the plan, one change per line.
+// the insertion starts with "+", the update starts with "~" and the deletion starts with "-",
+// e.g. "~ [row 2] code=P1: name 苹果 -> 红苹果".
+func (p *Plan) Preview() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("table %s: %d inserts, %d updates, %d deletes, %d unchanged\n",
+		p.Table, len(p.Inserts), len(p.Updates), len(p.Deletes), p.Unchanged))
+
+	for _, change := range p.Inserts {
+		sb.WriteString(fmt.Sprintf("+ [row %d] %s\n", change.Row+1, p.formatKey(change.Key)))
+	}
+	for _, change := range p.Updates {
+		diffs := make([]string, 0, len(change.Diffs))
+		for _, diff := range change.Diffs {
+			diffs = append(diffs, fmt.Sprintf("%s %v -> %v", diff.Column, diff.Before, diff.After))
+		}
+		sb.WriteString(fmt.Sprintf("~ [row %d] %s: %s\n", change.Row+1, p.formatKey(change.Key), strings.Join(diffs, ", ")))
+	}
+	for _, change := range p.Deletes {
+		sb.WriteString(fmt.Sprintf("- %s\n", p.formatKey(change.Key)))
+	}
+
+	return sb.String()
+}
+
+func (p *Plan) formatKey(key map[string]any) string {
+	parts := make([]string, 0, len(p.Keys))
+	for _, column := range p.Keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", column, key[column]))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// WithScope limit the current records which are synced, e.g. WithScope("category = ?", "fruit").
+// the records out of the scope are never updated or deleted.
+func WithScope(query any, args ...any) OptionFunc {
+	return func(syncer *Syncer) {
+		syncer.scope = append([]any{query}, args...)
+	}
+}
+
+// WithInsertValues set the values of the columns of the inserted records, e.g. the columns of the scope
+func WithInsertValues(values map[string]any) OptionFunc {
+	return func(syncer *Syncer) {
+		syncer.insertValues = values
+	}
+}
+
+// WithMaxDeletes set the max deletions of the applied plan, UnlimitedDeletes for no limit.
+// the deletions are refused by default.
+func WithMaxDeletes(maxDeletes int) OptionFunc {
+	return func(syncer *Syncer) {
+		syncer.maxDeletes = maxDeletes
+	}
+}
+
+// WithFrameworkOptions set the options of the framework which parses the file, e.g. WithStartRow, WithRecorder
+func WithFrameworkOptions(options ...general_framework.OptionFunc) OptionFunc {
+	return func(syncer *Syncer) {
+		syncer.frameworkOptions = append(syncer.frameworkOptions, options...)
+	}
+}
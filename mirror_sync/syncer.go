@@ -0,0 +1,402 @@
+package mirror_sync
+
+import (
+	"context"
+	"errors"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	excelImportTag = "exi"
+	keySep         = "\x00"
+)
+
+var (
+	ErrTooManyDeletes = errors.New("too many deletions")
+	ErrDuplicatedKey  = errors.New("duplicated key")
+	errColumnNotFound = errors.New("column not found")
+	errNoComparable   = errors.New("no comparable field, the fields should have the exi tag")
+	errUntaggedField  = errors.New("the untagged field should be after the exi tagged fields")
+)
+
+// Syncer sync the table with the file, the file is the source of truth in the scope.
+// the model is both the excel model and the gorm model, the fields with the exi tag are compared,
+// and the records are matched by the key columns.
+// the fields without the exi tag, e.g. the primary key, should be after the tagged ones, since the excel model
+// is filled field by field in order. they are reset after parsed, so the extra columns of the file are ignored.
+type Syncer struct {
+	db               *gorm.DB
+	model            any
+	keys             []string
+	scope            []any
+	insertValues     map[string]any
+	maxDeletes       int
+	frameworkOptions []general_framework.OptionFunc
+
+	schema *schema.Schema
+	// the compared fields, the key fields are excluded
+	fields    []*schema.Field
+	keyFields []*schema.Field
+	// the indexes of the struct fields without the exi tag
+	untagged []int
+}
+
+func NewSyncer(db *gorm.DB, model any, keys []string, options ...OptionFunc) (*Syncer, error) {
+	s := &Syncer{
+		db:         db,
+		model:      model,
+		maxDeletes: defaultMaxDeletes,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	s.schema = stmt.Schema
+
+	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
+	for i := 0; i < modelType.NumField(); i++ {
+		if len(modelType.Field(i).Tag.Get(excelImportTag)) == 0 {
+			s.untagged = append(s.untagged, i)
+			continue
+		}
+		if len(s.untagged) > 0 {
+			return nil, fmt.Errorf("%w: %s is before %s", errUntaggedField,
+				modelType.Field(s.untagged[0]).Name, modelType.Field(i).Name)
+		}
+	}
+
+	// the keys are the field names or the column names, kept as the column names
+	s.keys = make([]string, 0, len(keys))
+	for _, key := range keys {
+		field := s.schema.LookUpField(key)
+		if field == nil || len(field.DBName) == 0 {
+			return nil, fmt.Errorf("%w: %s", errColumnNotFound, key)
+		}
+		s.keyFields = append(s.keyFields, field)
+		s.keys = append(s.keys, field.DBName)
+	}
+
+	for _, field := range s.schema.Fields {
+		if len(field.DBName) == 0 || len(field.Tag.Get(excelImportTag)) == 0 || s.isKey(field) {
+			continue
+		}
+		s.fields = append(s.fields, field)
+	}
+	if len(s.fields) == 0 {
+		return nil, errNoComparable
+	}
+	for column := range s.insertValues {
+		if field := s.schema.LookUpField(column); field == nil || len(field.DBName) == 0 {
+			return nil, fmt.Errorf("%w: %s", errColumnNotFound, column)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Syncer) isKey(field *schema.Field) bool {
+	for _, keyField := range s.keyFields {
+		if keyField == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Plan parse the file and diff it against the current records in the scope.
+// the check errors, including the duplicated keys of the file, are recorded and fail the plan.
+func (s *Syncer) Plan(path string) (*Plan, error) {
+	collector := &modelCollector{syncer: s, rows: make(map[string]int)}
+	options := append([]general_framework.OptionFunc{
+		general_framework.WithSimpleModelFactory(s.model),
+		general_framework.WithOneSectionCheckers(collector),
+	}, s.frameworkOptions...)
+	options = append(options, general_framework.WithDryRun())
+
+	framework := general_framework.NewImporterOneSectionFramework(s.db, nil, options...)
+	if err := framework.Import(path); err != nil {
+		return nil, err
+	}
+
+	return s.PlanModels(collector.models, collector.modelRows)
+}
+
+// PlanModels diff the models against the current records in the scope, the rows are the original rows of the models.
+func (s *Syncer) PlanModels(models []any, rows []int) (*Plan, error) {
+	records, currents, err := s.loadCurrents()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Table: s.schema.Table, Keys: s.keys}
+	matched := make(map[string]bool, len(models))
+	for i, model := range models {
+		key := s.keyString(model)
+		matched[key] = true
+
+		change := &Change{Row: rows[i], Key: s.keyValues(model), Model: model}
+		current, ok := currents[key]
+		if !ok {
+			change.Type = ChangeTypeInsert
+			plan.Inserts = append(plan.Inserts, change)
+			continue
+		}
+
+		change.Diffs = s.diff(current, model)
+		if len(change.Diffs) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		change.Type = ChangeTypeUpdate
+		change.Current = current
+		plan.Updates = append(plan.Updates, change)
+	}
+
+	// keep the order of the loaded records
+	for _, current := range records {
+		if matched[s.keyString(current)] {
+			continue
+		}
+		plan.Deletes = append(plan.Deletes, &Change{
+			Type:  ChangeTypeDelete,
+			Row:   -1,
+			Key:   s.keyValues(current),
+			Model: current,
+		})
+	}
+
+	return plan, nil
+}
+
+// ExportSQL export the plan as the sql sentences, one sentence per line
+func (s *Syncer) ExportSQL(plan *Plan, sqlPath string) error {
+	runner := util.NewSqlSentencesRunner(sqlPath, s.db, plan.Table)
+	defer runner.Close()
+
+	for _, change := range plan.Inserts {
+		if err := runner.WriteSqlSentences([]string{util.GenerateInsertSqlWithMap(plan.Table, s.getInsertValues(change.Model))}); err != nil {
+			return err
+		}
+	}
+	for _, change := range plan.Updates {
+		if err := runner.GenerateSqlUpdateSentences(updateValues(change), s.whereOf(change.Current)); err != nil {
+			return err
+		}
+	}
+	for _, change := range plan.Deletes {
+		if err := runner.GenerateSqlDeleteSentences(s.whereOf(change.Model)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply apply the plan in a transaction, refused if the deletions are more than the max deletions
+func (s *Syncer) Apply(plan *Plan) error {
+	if s.maxDeletes != UnlimitedDeletes && len(plan.Deletes) > s.maxDeletes {
+		return fmt.Errorf("%w: %d > %d", ErrTooManyDeletes, len(plan.Deletes), s.maxDeletes)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range plan.Inserts {
+			if err := s.setInsertValues(change.Model); err != nil {
+				return err
+			}
+			if err := tx.Create(change.Model).Error; err != nil {
+				return fmt.Errorf("insert row %d failed: %w", change.Row+1, err)
+			}
+		}
+		for _, change := range plan.Updates {
+			if err := tx.Table(plan.Table).Where(s.whereOf(change.Current)).Updates(updateValues(change)).Error; err != nil {
+				return fmt.Errorf("update row %d failed: %w", change.Row+1, err)
+			}
+		}
+		for _, change := range plan.Deletes {
+			if err := tx.Where(s.whereOf(change.Model)).Delete(s.newModel()).Error; err != nil {
+				return fmt.Errorf("delete %v failed: %w", change.Key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Syncer) newModel() any {
+	return reflect.New(s.schema.ModelType).Interface()
+}
+
+// loadCurrents load the current records in the scope, ordered by the primary key if exists, and indexed by the key
+func (s *Syncer) loadCurrents() ([]any, map[string]any, error) {
+	records := reflect.New(reflect.SliceOf(reflect.PointerTo(s.schema.ModelType)))
+	query := s.db.Model(s.newModel())
+	if len(s.scope) > 0 {
+		query = query.Where(s.scope[0], s.scope[1:]...)
+	}
+	if s.schema.PrioritizedPrimaryField != nil {
+		query = query.Order(s.schema.PrioritizedPrimaryField.DBName)
+	}
+	if err := query.Find(records.Interface()).Error; err != nil {
+		return nil, nil, err
+	}
+
+	list := make([]any, 0, records.Elem().Len())
+	currents := make(map[string]any, records.Elem().Len())
+	for i := 0; i < records.Elem().Len(); i++ {
+		current := records.Elem().Index(i).Interface()
+		list = append(list, current)
+		currents[s.keyString(current)] = current
+	}
+
+	return list, currents, nil
+}
+
+func (s *Syncer) keyValues(model any) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	values := make(map[string]any, len(s.keyFields))
+	for _, field := range s.keyFields {
+		values[field.DBName] = v.FieldByIndex(field.StructField.Index).Interface()
+	}
+
+	return values
+}
+
+// whereOf the condition of the current record, the primary key is used if exists,
+// since the key may be not unique out of the scope.
+func (s *Syncer) whereOf(current any) map[string]any {
+	field := s.schema.PrioritizedPrimaryField
+	if field == nil {
+		return s.keyValues(current)
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(current))
+	return map[string]any{field.DBName: v.FieldByIndex(field.StructField.Index).Interface()}
+}
+
+func (s *Syncer) keyString(model any) string {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	parts := make([]string, 0, len(s.keyFields))
+	for _, field := range s.keyFields {
+		parts = append(parts, fmt.Sprint(v.FieldByIndex(field.StructField.Index).Interface()))
+	}
+
+	return strings.Join(parts, keySep)
+}
+
+// diff compare the fields of the models field by field
+func (s *Syncer) diff(current, model any) []*FieldDiff {
+	cv := reflect.Indirect(reflect.ValueOf(current))
+	mv := reflect.Indirect(reflect.ValueOf(model))
+
+	var diffs []*FieldDiff
+	for _, field := range s.fields {
+		before := cv.FieldByIndex(field.StructField.Index)
+		after := mv.FieldByIndex(field.StructField.Index)
+		if equalValue(before, after) {
+			continue
+		}
+
+		diffs = append(diffs, &FieldDiff{Column: field.DBName, Before: before.Interface(), After: after.Interface()})
+	}
+
+	return diffs
+}
+
+// setInsertValues set the insert values into the inserted model
+func (s *Syncer) setInsertValues(model any) error {
+	for column, value := range s.insertValues {
+		if err := s.schema.LookUpField(column).Set(context.Background(), reflect.ValueOf(model), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getInsertValues the values of the key, the compared fields and the insert values
+func (s *Syncer) getInsertValues(model any) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	values := s.keyValues(model)
+	for _, field := range s.fields {
+		fv := v.FieldByIndex(field.StructField.Index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		values[field.DBName] = fv.Interface()
+	}
+	for column, value := range s.insertValues {
+		values[s.schema.LookUpField(column).DBName] = value
+	}
+
+	return values
+}
+
+func updateValues(change *Change) map[string]any {
+	updates := make(map[string]any, len(change.Diffs))
+	for _, diff := range change.Diffs {
+		updates[diff.Column] = diff.After
+	}
+
+	return updates
+}
+
+// equalValue compare the values, the times are compared by the instant
+func equalValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+
+	if at, ok := a.Interface().(time.Time); ok {
+		return at.Equal(b.Interface().(time.Time))
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// modelCollector collect the parsed models and check the duplicated keys
+type modelCollector struct {
+	syncer    *Syncer
+	models    []any
+	modelRows []int
+	// the rows of the keys
+	rows map[string]int
+}
+
+func (c *modelCollector) CheckValid(s *general_framework.RawContent) error {
+	model := s.GetModel()
+	// the untagged fields are filled by the columns after the tagged ones
+	v := reflect.ValueOf(model).Elem()
+	for _, i := range c.syncer.untagged {
+		v.Field(i).SetZero()
+	}
+
+	key := c.syncer.keyString(model)
+	if row, ok := c.rows[key]; ok {
+		return fmt.Errorf("%w: %s is the same as the row %d", ErrDuplicatedKey,
+			strings.ReplaceAll(key, keySep, ", "), row+1)
+	}
+	c.rows[key] = s.GetRow()
+
+	c.models = append(c.models, model)
+	c.modelRows = append(c.modelRows, s.GetRow())
+	return nil
+}
@@ -0,0 +1,165 @@
+package mirror_sync
+
+import (
+	"errors"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the untagged fields are after the tagged ones, as required by the syncer
+type syncProduct struct {
+	Code     string  `exi:"index:0,name:编码" gorm:"column:code;uniqueIndex:uk_category_code"`
+	Name     string  `exi:"index:1,name:名称" gorm:"column:name"`
+	Price    float64 `exi:"index:2,name:价格,fcf:float" gorm:"column:price"`
+	ID       int64   `gorm:"column:id;primaryKey"`
+	Category string  `gorm:"column:category;uniqueIndex:uk_category_code"`
+}
+
+func newTestSyncer(t *testing.T, options ...OptionFunc) (*Syncer, *gorm.DB) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sync.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&syncProduct{}); err != nil {
+		t.Fatal(err)
+	}
+
+	products := []*syncProduct{
+		{Code: "P1", Name: "苹果", Price: 1.5, Category: "fruit"},
+		{Code: "P2", Name: "香蕉", Price: 2, Category: "fruit"},
+		{Code: "P3", Name: "梨", Price: 3, Category: "fruit"},
+		{Code: "P1", Name: "白菜", Price: 1, Category: "vegetable"},
+	}
+	if err = db.Create(products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	options = append([]OptionFunc{
+		WithScope("category = ?", "fruit"),
+		WithInsertValues(map[string]any{"category": "fruit"}),
+		WithFrameworkOptions(general_framework.WithRecorder(recorder),
+			general_framework.WithProgressReporter(util.NewProgressReporter(false))),
+	}, options...)
+
+	syncer, err := NewSyncer(db, &syncProduct{}, []string{"Code"}, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return syncer, db
+}
+
+func writeSyncFile(t *testing.T, rows ...[]string) string {
+	path := filepath.Join(t.TempDir(), "products.csv")
+	if err := util.WriteExcelContent(path, append([][]string{{"编码", "名称", "价格"}}, rows...)); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSyncer_PlanAndApply(t *testing.T) {
+	syncer, db := newTestSyncer(t, WithMaxDeletes(1))
+	path := writeSyncFile(t, []string{"P1", "红苹果", "1.5"}, []string{"P2", "香蕉", "2.5"}, []string{"P4", "桃", "4"})
+
+	plan, err := syncer.Plan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Inserts) != 1 || len(plan.Updates) != 2 || len(plan.Deletes) != 1 || plan.Unchanged != 0 {
+		t.Fatalf("unexpected plan:\n%s", plan.Preview())
+	}
+	if diffs := plan.Updates[1].Diffs; len(diffs) != 1 || diffs[0].Column != "price" || diffs[0].Before != 2.0 || diffs[0].After != 2.5 {
+		t.Fatalf("unexpected diffs %+v", diffs)
+	}
+
+	preview := plan.Preview()
+	for _, line := range []string{"+ [row 4] code=P4", "~ [row 2] code=P1: name 苹果 -> 红苹果", "~ [row 3] code=P2: price 2 -> 2.5", "- code=P3"} {
+		if !strings.Contains(preview, line) {
+			t.Fatalf("preview doesn't contain %q:\n%s", line, preview)
+		}
+	}
+
+	// the plan is exported as sql
+	sqlPath := filepath.Join(t.TempDir(), "sync.sql")
+	if err = syncer.ExportSQL(plan, sqlPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(sqlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqls := strings.Split(strings.TrimSpace(string(b)), "\n")
+	// the current records are identified by the primary key
+	if len(sqls) != 4 || !strings.HasPrefix(sqls[0], "INSERT INTO sync_products") ||
+		sqls[2] != "UPDATE sync_products SET price = 2.500000 WHERE id = 2;" || sqls[3] != "DELETE FROM sync_products WHERE id = 3;" {
+		t.Fatalf("unexpected sqls %v", sqls)
+	}
+
+	if err = syncer.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	// the table is the same as the file in the scope, and the others are kept
+	if plan, err = syncer.Plan(path); err != nil || !plan.CheckEmpty() || plan.Unchanged != 3 {
+		t.Fatalf("unexpected plan after applied, err %v:\n%s", err, plan.Preview())
+	}
+	cabbage := &syncProduct{}
+	if err = db.First(cabbage, "category = ?", "vegetable").Error; err != nil || cabbage.Name != "白菜" {
+		t.Fatalf("the product out of the scope is changed %+v, err %v", cabbage, err)
+	}
+}
+
+func TestSyncer_Refused(t *testing.T) {
+	syncer, db := newTestSyncer(t)
+
+	// the deletions are refused by default
+	plan, err := syncer.Plan(writeSyncFile(t, []string{"P1", "青苹果", "1.5"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = syncer.Apply(plan); !errors.Is(err, ErrTooManyDeletes) {
+		t.Fatalf("expected too many deletions, got %v", err)
+	}
+	product := &syncProduct{}
+	if err = db.First(product, "code = ? AND category = ?", "P1", "fruit").Error; err != nil || product.Name != "苹果" {
+		t.Fatalf("the refused plan changed the product %+v, err %v", product, err)
+	}
+
+	// the duplicated keys fail the plan
+	if _, err = syncer.Plan(writeSyncFile(t, []string{"P1", "苹果", "1"}, []string{"P1", "苹果", "2"})); err == nil {
+		t.Fatal("expected the check error of the duplicated keys")
+	}
+}
+
+// syncIDFirstProduct the untagged primary key is before the tagged fields, which would be filled by the first column
+type syncIDFirstProduct struct {
+	ID   int64  `gorm:"column:id;primaryKey"`
+	Code string `exi:"index:0,name:编码" gorm:"column:code"`
+}
+
+func TestSyncer_UntaggedFields(t *testing.T) {
+	syncer, _ := newTestSyncer(t, WithMaxDeletes(10))
+
+	if _, err := NewSyncer(syncer.db, &syncIDFirstProduct{}, []string{"Code"}); !errors.Is(err, errUntaggedField) {
+		t.Fatalf("expected the untagged field error, got %v", err)
+	}
+
+	// the extra column isn't filled into the untagged primary key
+	plan, err := syncer.Plan(writeSyncFile(t, []string{"P4", "桃", "4", "99"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Inserts) != 1 || plan.Inserts[0].Model.(*syncProduct).ID != 0 {
+		t.Fatalf("unexpected plan:\n%s", plan.Preview())
+	}
+}
@@ -45,6 +45,12 @@ type ExcelImportTagAttr struct {
 	// the id to identify or link
 	// tagName: id
 	ID string
+	// the header name of the column
+	// tagName: name
+	Name string
+	// the allowed values of the column, separated by "|"
+	// tagName: enum
+	Enum []string
 }
 
 func CheckChkKeyMatch(cm CheckMode, key string) bool {
@@ -108,3 +114,17 @@ func (s StructInfo) String() string {
 	sb.WriteString("}\n")
 	return sb.String()
 }
+
+// SinkRecord the model with its row metadata written into the sink
+type SinkRecord struct {
+	// the written model
+	Model any
+	// the original rows of the model, start from 0
+	Rows []int
+	// the level of the tree node, 0 if not a tree node
+	Level int
+	// the id of the parent tree node, 0 if not a tree node or the parent is the root
+	ParentID int64
+	// the id generated by the sink
+	ID int64
+}
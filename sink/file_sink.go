@@ -0,0 +1,181 @@
+package sink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"excel_import"
+	"excel_import/utils"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	csvMetaHeader = []string{"id", "parent_id", "level", "rows"}
+)
+
+const (
+	csvRowsSep = "|"
+)
+
+// JSONLSink writes the records into the jsonl file, one record per line.
+// the ID of the record is generated in the written order, start from 1.
+// thread safe.
+type JSONLSink struct {
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+	nextID  int64
+	mu      sync.Mutex
+}
+
+type jsonlRecord struct {
+	ID       int64 `json:"id"`
+	ParentID int64 `json:"parent_id,omitempty"`
+	Level    int   `json:"level,omitempty"`
+	Rows     []int `json:"rows"`
+	Model    any   `json:"model"`
+}
+
+func NewJSONLSink(path string) *JSONLSink {
+	return &JSONLSink{path: path}
+}
+
+func (j *JSONLSink) Write(record *excel_import.SinkRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// create the file when the first record is written
+	if j.file == nil {
+		file, err := os.Create(j.path)
+		if err != nil {
+			return err
+		}
+
+		j.file = file
+		j.encoder = json.NewEncoder(file)
+	}
+
+	j.nextID++
+	record.ID = j.nextID
+
+	return j.encoder.Encode(&jsonlRecord{
+		ID:       record.ID,
+		ParentID: record.ParentID,
+		Level:    record.Level,
+		Rows:     record.Rows,
+		Model:    record.Model,
+	})
+}
+
+func (j *JSONLSink) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Close()
+	j.file, j.encoder = nil, nil
+	return err
+}
+
+// CSVSink writes the records into the csv file.
+// the columns are the meta columns followed by the columns of the model in the exi tag layout,
+// so the model should be the same type in the sink.
+// the ID of the record is generated in the written order, start from 1.
+// thread safe.
+type CSVSink struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	nextID int64
+	mu     sync.Mutex
+}
+
+func NewCSVSink(path string) *CSVSink {
+	return &CSVSink{path: path}
+}
+
+func (c *CSVSink) Write(record *excel_import.SinkRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// create the file and write the header when the first record is written
+	if c.file == nil {
+		file, err := os.Create(c.path)
+		if err != nil {
+			return err
+		}
+
+		c.file = file
+		c.writer = csv.NewWriter(file)
+		if err = c.writer.Write(append(append([]string{}, csvMetaHeader...), util.ParseHeaderNames(record.Model)...)); err != nil {
+			return err
+		}
+	}
+
+	c.nextID++
+	record.ID = c.nextID
+
+	row, err := modelToRow(record.Model)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]string, len(record.Rows))
+	for i, r := range record.Rows {
+		rows[i] = strconv.Itoa(r)
+	}
+	meta := []string{
+		strconv.FormatInt(record.ID, 10),
+		strconv.FormatInt(record.ParentID, 10),
+		strconv.Itoa(record.Level),
+		strings.Join(rows, csvRowsSep),
+	}
+
+	return c.writer.Write(append(meta, row...))
+}
+
+func (c *CSVSink) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		c.file.Close()
+		return err
+	}
+
+	err := c.file.Close()
+	c.file, c.writer = nil, nil
+	return err
+}
+
+// modelToRow translate the model into the row in the tag column order
+func modelToRow(model any) ([]string, error) {
+	tags := util.ParseTag(model)
+
+	columnCount := 0
+	for _, tag := range tags {
+		columnCount = max(columnCount, tag.ColumnIndex+1)
+	}
+
+	row := make([]string, columnCount)
+	for i, tag := range tags {
+		s, err := util.GetFieldString(model, i)
+		if err != nil {
+			return nil, err
+		}
+
+		row[tag.ColumnIndex] = s
+	}
+
+	return row, nil
+}
@@ -0,0 +1,30 @@
+package sink
+
+import (
+	"excel_import"
+	"excel_import/utils"
+	"gorm.io/gorm"
+)
+
+// GormSink creates the models in the db.
+// the ID of the record is the primary key of the created model.
+type GormSink struct {
+	db *gorm.DB
+}
+
+func NewGormSink(db *gorm.DB) *GormSink {
+	return &GormSink{db: db}
+}
+
+func (g *GormSink) Write(record *excel_import.SinkRecord) error {
+	if err := g.db.Create(record.Model).Error; err != nil {
+		return err
+	}
+
+	record.ID = util.GetModelID(record.Model)
+	return nil
+}
+
+func (g *GormSink) Flush() error {
+	return nil
+}
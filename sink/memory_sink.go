@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"excel_import"
+	"sync"
+)
+
+// MemorySink collects the records in memory, usually used in tests.
+// the ID of the record is generated in the written order, start from 1.
+// thread safe.
+type MemorySink struct {
+	records []*excel_import.SinkRecord
+	flushed bool
+	mu      sync.Mutex
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (m *MemorySink) Write(record *excel_import.SinkRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.ID = int64(len(m.records) + 1)
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *MemorySink) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.flushed = true
+	return nil
+}
+
+// GetRecords get the records in the written order
+func (m *MemorySink) GetRecords() []*excel_import.SinkRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*excel_import.SinkRecord{}, m.records...)
+}
+
+// GetModels get the models in the written order
+func (m *MemorySink) GetModels() []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	models := make([]any, len(m.records))
+	for i, record := range m.records {
+		models[i] = record.Model
+	}
+
+	return models
+}
+
+// CheckFlushed check if the sink has been flushed
+func (m *MemorySink) CheckFlushed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.flushed
+}
@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"excel_import"
+	"excel_import/general_framework"
+	util "excel_import/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type sinkExcelModel struct {
+	Name  string `exi:"index:0,name:名称" json:"name"`
+	Price string `exi:"index:1,name:价格,fcf:float" json:"price"`
+}
+
+type sinkProductModel struct {
+	ID    int64
+	Name  string
+	Price string
+}
+
+func writeSinkTestFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "sink.csv")
+	if err := util.WriteExcelContent(path, [][]string{{"名称", "价格"}, {"苹果", "1.5"}, {"香蕉", "2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestJSONLSink_Import(t *testing.T) {
+	path := writeSinkTestFile(t)
+	outputPath := filepath.Join(t.TempDir(), "sink.jsonl")
+
+	framework := general_framework.NewSinkFramework(NewJSONLSink(outputPath), nil,
+		general_framework.WithSimpleModelFactory(&sinkExcelModel{}))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]any
+		if err = json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	expected := []map[string]any{
+		{"id": float64(1), "rows": []any{float64(1)}, "model": map[string]any{"name": "苹果", "price": "1.5"}},
+		{"id": float64(2), "rows": []any{float64(2)}, "model": map[string]any{"name": "香蕉", "price": "2"}},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("got %v, expected %v", lines, expected)
+	}
+}
+
+func TestCSVSink_Import(t *testing.T) {
+	path := writeSinkTestFile(t)
+	outputPath := filepath.Join(t.TempDir(), "sink_output.csv")
+
+	framework := general_framework.NewSinkFramework(NewCSVSink(outputPath), nil,
+		general_framework.WithSimpleModelFactory(&sinkExcelModel{}))
+	if err := framework.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadExcelContent(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"id", "parent_id", "level", "rows", "名称", "价格"},
+		{"1", "0", "0", "1", "苹果", "1.5"},
+		{"2", "0", "0", "2", "香蕉", "2"},
+	}
+	if !reflect.DeepEqual(content, expected) {
+		t.Fatalf("got %v, expected %v", content, expected)
+	}
+}
+
+func TestGormSink_Import(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sink.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&sinkProductModel{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// map the excel model into the db model
+	mapper := func(s *general_framework.RawContent) (any, error) {
+		model := s.GetModel().(*sinkExcelModel)
+		return &sinkProductModel{Name: model.Name, Price: model.Price}, nil
+	}
+
+	framework := general_framework.NewSinkFramework(NewGormSink(db), mapper,
+		general_framework.WithSimpleModelFactory(&sinkExcelModel{}))
+	if err = framework.Import(writeSinkTestFile(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var products []*sinkProductModel
+	if err = db.Order("id").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || products[0].Name != "苹果" || products[1].Name != "香蕉" {
+		t.Fatalf("unexpected products: %v", products)
+	}
+}
+
+func TestMemorySink_Write(t *testing.T) {
+	sink := NewMemorySink()
+	for _, name := range []string{"a", "b"} {
+		record := &excel_import.SinkRecord{Model: name}
+		if err := sink.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		if record.ID != int64(len(sink.GetRecords())) {
+			t.Fatalf("record id %d, expected %d", record.ID, len(sink.GetRecords()))
+		}
+	}
+
+	if err := sink.Flush(); err != nil || !sink.CheckFlushed() {
+		t.Fatalf("sink should be flushed, err %v", err)
+	}
+	if !reflect.DeepEqual(sink.GetModels(), []any{"a", "b"}) {
+		t.Fatalf("unexpected models: %v", sink.GetModels())
+	}
+}
@@ -31,12 +31,21 @@ type TreeInfo interface {
 	GetModels() []any
 }
 
+// TreeBuilder build the tree from the contents of the other layouts than one column per level,
+// e.g. the id/parent_id rows. the contents are attached to the nodes by the content index.
+type TreeBuilder interface {
+	// BuildTree build the tree under the root, which is created by NewRootNode
+	BuildTree(source *TreeSource) (*TreeNode, error)
+}
+
 type TreeMiddleware interface {
 	TreePreHandler
 	LevelImportPostHandler
 	excel_import.PostHandler
 }
 
+// GenerateNodeKey generate the key of the node, the nodes with the same key are the same node.
+// s is the values of the levels from the first level to the node, level starts from 1.
 type GenerateNodeKey func(s []string, level int) string
 type ColEndFunc func(next string) bool
 type OptionFunc func(*TreeImportFramework)
@@ -0,0 +1,153 @@
+package tree_framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+)
+
+const defaultBatchSize = 1000
+
+var (
+	errMixedModelType   = errors.New("the models of one level should be the same type")
+	errPrimaryKeyNotInt = errors.New("the primary key should be an integer")
+)
+
+// LevelBatchImporter import all the nodes of one level at once.
+// the level importer which implements it is called once per level instead of once per node.
+type LevelBatchImporter interface {
+	// ImportLevelNodes import the nodes of one level, the ids of the nodes should be set
+	ImportLevelNodes(tx *gorm.DB, nodes []*TreeNode) error
+}
+
+// statementCounter count the statements of the level batch, so the rate limiter judges the latency per statement
+type statementCounter interface {
+	countStatements(nodes int) int
+}
+
+// BatchNodeModelMapper map the tree node into the model inserted in batch.
+// the parent id is the id of the parent node, 0 for the top level. the node is skipped if the model is nil.
+type BatchNodeModelMapper func(node *TreeNode, parentID int64) (any, error)
+
+// BatchLevelImporter maps the nodes of one level into the models, inserts them by CreateInBatches,
+// and sets the generated primary keys back into the nodes, so the children are linked to them.
+// the models of one level should be the same type with an integer primary key, and the virtual root is skipped.
+type BatchLevelImporter struct {
+	mapper    BatchNodeModelMapper
+	batchSize int
+}
+
+func NewBatchLevelImporter(mapper BatchNodeModelMapper, batchSize int) *BatchLevelImporter {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &BatchLevelImporter{
+		mapper:    mapper,
+		batchSize: batchSize,
+	}
+}
+
+// ImportLevelNode import one node, the same as the level of one node
+func (bi *BatchLevelImporter) ImportLevelNode(tx *gorm.DB, node *TreeNode) error {
+	if node.CheckIsRoot() {
+		return nil
+	}
+
+	return bi.ImportLevelNodes(tx, []*TreeNode{node})
+}
+
+func (bi *BatchLevelImporter) ImportLevelNodes(tx *gorm.DB, nodes []*TreeNode) error {
+	// the models are mapped in every attempt, so the retried attempt won't reuse the assigned keys
+	mapped := make([]*TreeNode, 0, len(nodes))
+	var models reflect.Value
+	for _, node := range nodes {
+		if node.CheckIsRoot() {
+			continue
+		}
+
+		model, err := bi.mapper(node, node.GetParent().GetID())
+		if err != nil {
+			return err
+		}
+		if model == nil {
+			continue
+		}
+
+		mv := reflect.ValueOf(model)
+		if !models.IsValid() {
+			models = reflect.MakeSlice(reflect.SliceOf(mv.Type()), 0, len(nodes))
+		}
+		if mv.Type() != models.Type().Elem() {
+			return fmt.Errorf("%w: %s and %s", errMixedModelType, models.Type().Elem(), mv.Type())
+		}
+		models = reflect.Append(models, mv)
+		mapped = append(mapped, node)
+	}
+
+	if len(mapped) == 0 {
+		return nil
+	}
+
+	if err := tx.CreateInBatches(models.Interface(), bi.batchSize).Error; err != nil {
+		return err
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(models.Index(0).Interface()); err != nil {
+		return err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return fmt.Errorf("%w: %s has no primary key", errPrimaryKeyNotInt, stmt.Schema.Table)
+	}
+
+	for i, node := range mapped {
+		value, _ := pk.ValueOf(context.Background(), models.Index(i))
+		id, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		node.SetID(id)
+	}
+
+	return nil
+}
+
+// countStatements the insert statements of the nodes, which are inserted in batches
+func (bi *BatchLevelImporter) countStatements(nodes int) int {
+	return (nodes + bi.batchSize - 1) / bi.batchSize
+}
+
+func toInt64(value any) (int64, error) {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	default:
+		return 0, fmt.Errorf("%w: %v", errPrimaryKeyNotInt, value)
+	}
+}
+
+// NewBatchTreeImportFramework create the tree framework which inserts the nodes level by level in batch.
+// every level is mapped by the mapper and inserted in one round, the ids are set back before the next level.
+func NewBatchTreeImportFramework(db *gorm.DB, cfg *TreeImportCfg, mapper BatchNodeModelMapper, batchSize int, options ...OptionFunc) *TreeImportFramework {
+	if cfg == nil {
+		panic("cfg should not nil")
+	}
+	if mapper == nil {
+		panic("mapper should not nil")
+	}
+
+	importer := NewBatchLevelImporter(mapper, batchSize)
+	levelImporter := make([]LevelImporter, max(len(cfg.LevelOrder), len(cfg.Levels), 1))
+	for i := range levelImporter {
+		levelImporter[i] = importer
+	}
+
+	return NewTreeImportFramework(db, cfg, nil, levelImporter, options...)
+}
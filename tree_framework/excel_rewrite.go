@@ -4,25 +4,35 @@ import (
 	"excel_import"
 	util "excel_import/utils"
 	"gorm.io/gorm"
+	"sync"
 )
 
 type ExcelRewriterTreeMiddleware struct {
-	path     string
-	contents map[int][]string
+	path       string
+	outputPath string
+	// column index -> row -> content
+	contents map[int]map[int]string
 	attrs    []*excel_import.ExcelImportTagAttr
-	startRow int
+	mu       sync.Mutex
 }
 
 func NewExcelRewriterTreeMiddleware(path string) *ExcelRewriterTreeMiddleware {
 	return &ExcelRewriterTreeMiddleware{
-		path:     path,
-		contents: make(map[int][]string),
-		startRow: 1,
+		path:       path,
+		outputPath: path,
+		contents:   make(map[int]map[int]string),
 	}
 }
 
+// SetStartRow set the start row of the content.
+// Deprecated: the rewritten content is written by the rows of the tree node.
 func (e *ExcelRewriterTreeMiddleware) SetStartRow(startRow int) {
-	e.startRow = startRow
+}
+
+// SetOutputPath set the output path of the rewritten file.
+// the source file is overwritten if not set.
+func (e *ExcelRewriterTreeMiddleware) SetOutputPath(outputPath string) {
+	e.outputPath = outputPath
 }
 
 // PreImportHandle init excel import tag attr
@@ -44,14 +54,20 @@ func (e *ExcelRewriterTreeMiddleware) PreImportHandle(tx *gorm.DB, info TreeInfo
 	return nil
 }
 
+// PostLevelImportHandle record the rewrite content of the leaf keyed by the node rows.
+// thread safe.
 func (e *ExcelRewriterTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *TreeNode) error {
 	// check if node is leaf
 	if !node.CheckIsLeaf() {
 		return nil
 	}
 
-	// get models
+	// get models and its rows
 	models := node.GetItems()
+	rows := node.GetRows()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	// iterate models and write to content
 	for i, attr := range e.attrs {
@@ -59,13 +75,17 @@ func (e *ExcelRewriterTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *T
 			continue
 		}
 
-		for _, model := range models {
+		if _, ok := e.contents[attr.ColumnIndex]; !ok {
+			e.contents[attr.ColumnIndex] = make(map[int]string)
+		}
+
+		for j, model := range models {
 			s, err := util.GetFieldString(model, i)
 			if err != nil {
 				return err
 			}
 
-			e.contents[attr.ColumnIndex] = append(e.contents[attr.ColumnIndex], s)
+			e.contents[attr.ColumnIndex][rows[j]] = s
 		}
 	}
 
@@ -73,5 +93,5 @@ func (e *ExcelRewriterTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *T
 }
 
 func (e *ExcelRewriterTreeMiddleware) PostHandle(tx *gorm.DB) error {
-	return util.WriteExcelColumnContentByStartRow(e.path, e.contents, e.startRow)
+	return util.WriteExcelCellContentTo(e.path, e.outputPath, e.contents)
 }
@@ -0,0 +1,48 @@
+package tree_framework
+
+import (
+	util "excel_import/utils"
+	"gorm.io/gorm"
+)
+
+// ResultAnnotationTreeMiddleware annotates the import result of every row into the returned workbook.
+// the rows of the imported leaf are marked success with the leaf id, and the errors are taken from the recorder,
+// so the workbook is written when the recorder is flushed, even if the check failed.
+type ResultAnnotationTreeMiddleware struct {
+	*util.ResultAnnotator
+	successFormatter func(node *TreeNode) string
+}
+
+func NewResultAnnotationTreeMiddleware(path string) *ResultAnnotationTreeMiddleware {
+	return &ResultAnnotationTreeMiddleware{
+		ResultAnnotator:  util.NewResultAnnotator(path),
+		successFormatter: defaultSuccessFormatter,
+	}
+}
+
+// SetSuccessFormatter set the formatter of the success result.
+func (r *ResultAnnotationTreeMiddleware) SetSuccessFormatter(f func(node *TreeNode) string) {
+	r.successFormatter = f
+}
+
+func defaultSuccessFormatter(node *TreeNode) string {
+	return util.SuccessMessage(node.GetID())
+}
+
+func (r *ResultAnnotationTreeMiddleware) PreImportHandle(tx *gorm.DB, info TreeInfo) error {
+	return nil
+}
+
+func (r *ResultAnnotationTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *TreeNode) error {
+	// the row belongs to its leaf
+	if !node.CheckIsLeaf() {
+		return nil
+	}
+
+	r.MarkSuccess(node.GetRows(), r.successFormatter(node))
+	return nil
+}
+
+func (r *ResultAnnotationTreeMiddleware) PostHandle(tx *gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package tree_framework
+
+import (
+	"excel_import"
+	"gorm.io/gorm"
+)
+
+// LevelModelMapper map the tree node into the model written into the sink.
+// the node is skipped if the model is nil.
+type LevelModelMapper func(node *TreeNode) (any, error)
+
+// SinkNodeModel the default model of the tree node written into the sink
+type SinkNodeModel struct {
+	Value string `json:"value"`
+}
+
+// SinkLevelImporter writes the model of the tree node into the sink,
+// and the ID generated by the sink is set into the node, so the children are linked to it.
+// the SinkNodeModel is written if the mapper is nil, and the virtual root is skipped.
+type SinkLevelImporter struct {
+	sink   excel_import.Sink
+	mapper LevelModelMapper
+}
+
+func NewSinkLevelImporter(sink excel_import.Sink, mapper LevelModelMapper) *SinkLevelImporter {
+	return &SinkLevelImporter{
+		sink:   sink,
+		mapper: mapper,
+	}
+}
+
+func (si *SinkLevelImporter) ImportLevelNode(tx *gorm.DB, node *TreeNode) error {
+	if node.CheckIsRoot() {
+		return nil
+	}
+
+	var model any = &SinkNodeModel{Value: node.GetValue()}
+	if si.mapper != nil {
+		var err error
+		if model, err = si.mapper(node); err != nil {
+			return err
+		}
+	}
+
+	if model == nil {
+		return nil
+	}
+
+	record := &excel_import.SinkRecord{
+		Model:    model,
+		Rows:     node.GetRows(),
+		Level:    node.GetRank(),
+		ParentID: node.GetParent().GetID(),
+	}
+	if err := si.sink.Write(record); err != nil {
+		return err
+	}
+
+	node.SetID(record.ID)
+	return nil
+}
+
+// sinkFlushTreeMiddleware flushes the sink after the import
+type sinkFlushTreeMiddleware struct {
+	sink excel_import.Sink
+}
+
+func (m *sinkFlushTreeMiddleware) PreImportHandle(tx *gorm.DB, info TreeInfo) error {
+	return nil
+}
+
+func (m *sinkFlushTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *TreeNode) error {
+	return nil
+}
+
+func (m *sinkFlushTreeMiddleware) PostHandle(tx *gorm.DB) error {
+	return m.sink.Flush()
+}
+
+// NewSinkTreeFramework create the tree framework which writes the tree nodes into the sink instead of the db.
+// the parsing, checking, progress and recording are the same as the db import,
+// and the sink is flushed after the import.
+func NewSinkTreeFramework(sink excel_import.Sink, cfg *TreeImportCfg, mapper LevelModelMapper, options ...OptionFunc) *TreeImportFramework {
+	if cfg == nil {
+		panic("cfg should not nil")
+	}
+
+	importer := NewSinkLevelImporter(sink, mapper)
+	levelImporter := make([]LevelImporter, max(len(cfg.LevelOrder), len(cfg.Levels), 1))
+	for i := range levelImporter {
+		levelImporter[i] = importer
+	}
+
+	tif := NewTreeImportFramework(nil, cfg, importer, levelImporter, options...)
+	tif.middlewares = append(tif.middlewares, &sinkFlushTreeMiddleware{sink: sink})
+
+	return tif
+}
@@ -0,0 +1,289 @@
+package tree_framework
+
+import (
+	"errors"
+	util "excel_import/utils"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrDuplicateNodeID = errors.New("duplicate node id")
+	ErrParentNotFound  = errors.New("parent not found")
+	ErrTreeCycle       = errors.New("the parents form a cycle")
+	ErrOutlineCode     = errors.New("invalid outline code")
+
+	outlineCodeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)*)\.?(?:\s+|$)(.*)$`)
+)
+
+// TreeSource the parsed contents of the tree sheet, which the tree builder builds the tree from
+type TreeSource struct {
+	// the formatted contents
+	Contents [][]string
+	// the contents before formatted, so the indentation is kept
+	Raws [][]string
+	// the original row number of each content, start from 0
+	Rows []int
+}
+
+// cell the formatted cell of the content, empty if the row is short
+func (s *TreeSource) cell(index, col int) string {
+	if col < 0 || col >= len(s.Contents[index]) {
+		return ""
+	}
+
+	return s.Contents[index][col]
+}
+
+// rawCell the cell of the content before formatted
+func (s *TreeSource) rawCell(index, col int) string {
+	if index >= len(s.Raws) || col < 0 || col >= len(s.Raws[index]) {
+		return s.cell(index, col)
+	}
+
+	return s.Raws[index][col]
+}
+
+// AdjacencyTreeBuilder build the tree from the id/parent_id rows, one node per row.
+// the rows may be in any order, the rows whose parent id is empty or 0 are the first level,
+// and the rows without the id are skipped.
+type AdjacencyTreeBuilder struct {
+	idCol     int
+	parentCol int
+	valueCol  int
+}
+
+func NewAdjacencyTreeBuilder(idCol, parentCol, valueCol int) *AdjacencyTreeBuilder {
+	return &AdjacencyTreeBuilder{
+		idCol:     idCol,
+		parentCol: parentCol,
+		valueCol:  valueCol,
+	}
+}
+
+func (ab *AdjacencyTreeBuilder) BuildTree(source *TreeSource) (*TreeNode, error) {
+	linked := make([]*linkedRow, 0, len(source.Contents))
+	for i := range source.Contents {
+		id := source.cell(i, ab.idCol)
+		if len(id) == 0 {
+			continue
+		}
+
+		parentID := source.cell(i, ab.parentCol)
+		if parentID == "0" {
+			parentID = ""
+		}
+		linked = append(linked, &linkedRow{index: i, key: id, parentKey: parentID, value: source.cell(i, ab.valueCol)})
+	}
+
+	return linkRows(source, linked)
+}
+
+// OutlineTreeBuilder build the tree from the outline codes, e.g. 1, 1.2, 1.2.3, one node per row.
+// the parent of 1.2.3 is 1.2, and the rows may be in any order.
+// if the code column is the value column, the code is the prefix of the value, e.g. "1.2 苹果" or "1.2. 苹果".
+// the rows without the code are skipped.
+type OutlineTreeBuilder struct {
+	codeCol  int
+	valueCol int
+}
+
+func NewOutlineTreeBuilder(codeCol, valueCol int) *OutlineTreeBuilder {
+	return &OutlineTreeBuilder{
+		codeCol:  codeCol,
+		valueCol: valueCol,
+	}
+}
+
+func (ob *OutlineTreeBuilder) BuildTree(source *TreeSource) (*TreeNode, error) {
+	linked := make([]*linkedRow, 0, len(source.Contents))
+	for i := range source.Contents {
+		code := source.cell(i, ob.codeCol)
+		if len(code) == 0 {
+			continue
+		}
+
+		value := source.cell(i, ob.valueCol)
+		if ob.codeCol == ob.valueCol {
+			matches := outlineCodeRegexp.FindStringSubmatch(code)
+			if matches == nil {
+				return nil, util.CombineErrors(source.Rows[i], fmt.Errorf("%w: %s", ErrOutlineCode, code))
+			}
+			code, value = matches[1], strings.TrimSpace(matches[2])
+		}
+		code = strings.TrimSuffix(code, ".")
+		if !outlineCodeRegexp.MatchString(code) {
+			return nil, util.CombineErrors(source.Rows[i], fmt.Errorf("%w: %s", ErrOutlineCode, code))
+		}
+
+		var parentCode string
+		if sep := strings.LastIndex(code, "."); sep >= 0 {
+			parentCode = code[:sep]
+		}
+		linked = append(linked, &linkedRow{index: i, key: code, parentKey: parentCode, value: value})
+	}
+
+	return linkRows(source, linked)
+}
+
+// IndentTreeBuilder build the tree from the indentation of the value column, one node per row.
+// the depth is the leading width divided by the indent width, the space counts 1,
+// the full-width space counts 2 and the tab counts the indent width.
+// the row is the child of the nearest row above which is one level shallower, and the blank rows are skipped.
+// the indentation of the cell style isn't read, the indentation should be typed.
+type IndentTreeBuilder struct {
+	col         int
+	indentWidth int
+}
+
+func NewIndentTreeBuilder(col, indentWidth int) *IndentTreeBuilder {
+	if indentWidth <= 0 {
+		indentWidth = 1
+	}
+
+	return &IndentTreeBuilder{
+		col:         col,
+		indentWidth: indentWidth,
+	}
+}
+
+func (ib *IndentTreeBuilder) BuildTree(source *TreeSource) (*TreeNode, error) {
+	root := NewRootNode()
+	// the last node of every depth
+	stack := []*TreeNode{root}
+	for i := range source.Contents {
+		value := source.cell(i, ib.col)
+		if len(value) == 0 {
+			continue
+		}
+
+		depth := ib.indentDepth(source.rawCell(i, ib.col))
+		if depth >= len(stack) {
+			return nil, util.CombineErrors(source.Rows[i], fmt.Errorf("%w: %s is at level %d, but the row above is at level %d",
+				ErrSkippedLevel, value, depth+1, len(stack)-1))
+		}
+
+		node := stack[depth].AddChild(value)
+		node.AttachContent(i)
+		stack = append(stack[:depth+1], node)
+	}
+
+	return root, nil
+}
+
+func (ib *IndentTreeBuilder) indentDepth(raw string) int {
+	width := 0
+	for _, r := range raw {
+		switch r {
+		case ' ':
+			width++
+		case '　':
+			width += 2
+		case '\t':
+			width += ib.indentWidth
+		default:
+			return width / ib.indentWidth
+		}
+	}
+
+	return width / ib.indentWidth
+}
+
+// PathTreeBuilder build the tree from the path strings, e.g. A/B/C.
+// the nodes of the same path are the same node, and the row is attached to every node of its path,
+// the same as the column layout. the blank segments and the rows without the path are skipped.
+type PathTreeBuilder struct {
+	col int
+	sep string
+}
+
+func NewPathTreeBuilder(col int, sep string) *PathTreeBuilder {
+	if len(sep) == 0 {
+		sep = "/"
+	}
+
+	return &PathTreeBuilder{
+		col: col,
+		sep: sep,
+	}
+}
+
+func (pb *PathTreeBuilder) BuildTree(source *TreeSource) (*TreeNode, error) {
+	root := NewRootNode()
+	nodes := make(map[string]*TreeNode)
+	for i := range source.Contents {
+		path := make([]string, 0)
+		for _, segment := range strings.Split(source.cell(i, pb.col), pb.sep) {
+			if segment = strings.TrimSpace(segment); len(segment) > 0 {
+				path = append(path, segment)
+			}
+		}
+
+		parent := root
+		for level := range path {
+			key := PathNodeKey(path[:level+1], level+1)
+			node, ok := nodes[key]
+			if !ok {
+				node = parent.AddChild(path[level])
+				nodes[key] = node
+			}
+			node.AttachContent(i)
+			parent = node
+		}
+	}
+
+	return root, nil
+}
+
+// linkedRow the row which references its parent by the key
+type linkedRow struct {
+	index     int
+	key       string
+	parentKey string
+	value     string
+}
+
+// linkRows build the tree from the rows linked by the keys, the rows without the parent key are the first level.
+// the children are in the order of the rows.
+func linkRows(source *TreeSource, linked []*linkedRow) (*TreeNode, error) {
+	indexes := make(map[string]int, len(linked))
+	children := make(map[string][]*linkedRow)
+	for _, lr := range linked {
+		if index, ok := indexes[lr.key]; ok {
+			return nil, util.CombineRowsErrors([]int{source.Rows[index], source.Rows[lr.index]},
+				fmt.Errorf("%w: %s", ErrDuplicateNodeID, lr.key))
+		}
+		indexes[lr.key] = lr.index
+		children[lr.parentKey] = append(children[lr.parentKey], lr)
+	}
+
+	root := NewRootNode()
+	built := make(map[string]bool, len(linked))
+	parents, keys := []*TreeNode{root}, []string{""}
+	for len(parents) > 0 {
+		nextParents, nextKeys := make([]*TreeNode, 0), make([]string, 0)
+		for i, parent := range parents {
+			for _, lr := range children[keys[i]] {
+				node := parent.AddChild(lr.value)
+				node.AttachContent(lr.index)
+				built[lr.key] = true
+				nextParents, nextKeys = append(nextParents, node), append(nextKeys, lr.key)
+			}
+		}
+		parents, keys = nextParents, nextKeys
+	}
+
+	// the rows not reached from the first level
+	for _, lr := range linked {
+		if built[lr.key] {
+			continue
+		}
+		if _, ok := indexes[lr.parentKey]; !ok {
+			return nil, util.CombineErrors(source.Rows[lr.index], fmt.Errorf("%w: %s of %s", ErrParentNotFound, lr.parentKey, lr.key))
+		}
+		return nil, util.CombineErrors(source.Rows[lr.index], fmt.Errorf("%w: %s", ErrTreeCycle, lr.key))
+	}
+
+	return root, nil
+}
@@ -1,23 +1,34 @@
 package tree_framework
 
 import (
+	"context"
 	"errors"
 	"excel_import"
 	"excel_import/features"
 	"excel_import/utils"
 	"fmt"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
+	"strings"
+	"sync"
 )
 
 var (
 	errContentCheckFailed = errors.New("content check failed")
+	ErrAmbiguousNodeKey   = errors.New("ambiguous node key")
+	ErrDuplicateSibling   = errors.New("duplicate sibling")
 )
 
 type TreeImportFramework struct {
-	db            *gorm.DB
-	recorder      *util.UnexpectedRecorder
-	cfg           *TreeImportCfg
-	nodes         map[string]*TreeNode
+	db       *gorm.DB
+	recorder *util.UnexpectedRecorder
+	cfg      *TreeImportCfg
+	nodes    map[string]*TreeNode
+	// the conflicts of the node keys, reported in the check
+	conflicts     []*nodeConflict
+	conflictPaths map[string]bool
+	// the rows reported with the skipped level
+	skippedRows   map[int]bool
 	levelImporter []LevelImporter
 	// the root importer
 	rootImporter     LevelImporter
@@ -28,6 +39,10 @@ type TreeImportFramework struct {
 	middlewares      []TreeMiddleware
 	correctCheckers  []excel_import.CorrectnessChecker
 	featureMgr       *features.FeatureMgr
+	// serialize the middlewares under the parallel import
+	middlewareMu sync.Mutex
+	// the existing nodes missing from the file, under the merge
+	missingNodes []*ExistingNode
 }
 
 func NewTreeImportStrictOrderFramework(db *gorm.DB, treeBoundary, colCount int, modelFac excel_import.RowModelFactory, importer LevelImporter, options ...OptionFunc) *TreeImportFramework {
@@ -55,23 +70,19 @@ func NewTreeImportFramework(db *gorm.DB, cfg *TreeImportCfg, rootImporter LevelI
 	if cfg == nil {
 		panic("cfg should not nil")
 	}
-	if len(cfg.LevelOrder) == 0 {
-		panic("level order should not empty")
-	}
 	if len(levelImporter) == 0 {
 		panic("level importer should not empty")
 	}
-	if len(levelImporter) != len(cfg.LevelOrder) {
-		panic("level importer should be equal to level order")
-	}
 
+	// copy the default config, so the options won't change the default
+	ocfg := *defaultOptCfg
 	tif := &TreeImportFramework{
 		db:               db,
 		cfg:              cfg,
 		nodes:            make(map[string]*TreeNode),
 		levelImporter:    levelImporter,
 		rootImporter:     rootImporter,
-		ocfg:             defaultOptCfg,
+		ocfg:             &ocfg,
 		recorder:         util.NewDefaultUnexpectedRecorder(),
 		progressReporter: util.NewProgressReporter(true),
 		featureMgr:       features.NewFeatureMgr(),
@@ -84,6 +95,19 @@ func NewTreeImportFramework(db *gorm.DB, cfg *TreeImportCfg, rootImporter LevelI
 	if tif.cfg.ModelFac == nil {
 		panic("rawModel factory should not nil")
 	}
+	// the level order is only for the column layout
+	if tif.ocfg.treeBuilder == nil {
+		if len(cfg.Levels) > 0 {
+			tif.cfg = resolveLevels(cfg)
+			cfg = tif.cfg
+		}
+		if len(cfg.LevelOrder) == 0 {
+			panic("level order should not empty")
+		}
+		if len(levelImporter) != len(cfg.LevelOrder) {
+			panic("level importer should be equal to level order")
+		}
+	}
 
 	// enable format check feature
 	tif.featureMgr.EnableTagFormatChecker()
@@ -106,6 +130,7 @@ func WithStartRow(sr int) OptionFunc {
 func WithEndFunc(ef excel_import.EndFunc) OptionFunc {
 	return func(framework *TreeImportFramework) {
 		framework.ocfg.ef = ef
+		framework.ocfg.customEndFunc = true
 	}
 }
 
@@ -145,15 +170,98 @@ func WithEnableFormatCheck() OptionFunc {
 	}
 }
 
+func WithRecorder(recorder *util.UnexpectedRecorder) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.recorder = recorder
+	}
+}
+
+func WithProgressReporter(progressReporter *util.ProgressReporter) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.progressReporter = progressReporter
+	}
+}
+
+func WithRetryPolicy(policy *util.RetryPolicy) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.retryPolicy = policy
+	}
+}
+
+func WithRateLimiter(limiter *util.RateLimiter) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.rateLimiter = limiter
+	}
+}
+
+// WithDryRun only parse and check the content, the importers, the handlers and the middlewares aren't called
+func WithDryRun() OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.dryRun = true
+	}
+}
+
+// CheckDryRun check if the import only parses and checks the content
+func (t *TreeImportFramework) CheckDryRun() bool {
+	return t.ocfg.dryRun
+}
+
+// WithFillDown fill the blank tree cells with the value above within the same parent span,
+// for the sheets which write the parent value only on its first row.
+// the blank cells after the last valued tree cell of the row are the leaf ends, and kept blank.
+// the blank cells of the levels set by TreeImportCfg.Levels are filled along with their key cells.
+// since the first cell may be blank, the content ends at the blank row unless the end func is set by WithEndFunc.
+func WithFillDown() OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.fillDown = true
+		if !framework.ocfg.customEndFunc {
+			framework.ocfg.ef = util.BlankRowEndFunc
+		}
+	}
+}
+
+// WithMergedCells fill the cells of the merged ranges with the top-left value when reading the xlsx file
+func WithMergedCells() OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.mergedCells = true
+	}
+}
+
+// WithParallel import the nodes of one level concurrently with the max parallel.
+// the levels are still imported in sequence, so the parents have the ids before their children are imported,
+// and the middlewares are called one by one. the retry policy can't be used if the db is a transaction.
+func WithParallel(maxParallel int) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.maxParallel = maxParallel
+	}
+}
+
+// WithTreeBuilder build the tree by the builder instead of one column per level, e.g. NewAdjacencyTreeBuilder.
+// the level order, the tree boundary and the fill down are ignored, the rows are still parsed into the models by the model factory.
+// the last level importer is used for the levels deeper than the level importers.
+func WithTreeBuilder(builder TreeBuilder) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.treeBuilder = builder
+	}
+}
+
 func (t *TreeImportFramework) WithOption(option OptionFunc) *TreeImportFramework {
 	option(t)
 	return t
 }
 
 func (t *TreeImportFramework) Import(path string) error {
+	t.registerRecordObservers()
 	defer t.recorder.Flush()
 	defer t.progressReporter.Report()
 
+	if t.ocfg.maxParallel > 1 {
+		if err := t.ocfg.retryPolicy.CheckParallel(t.db); err != nil {
+			fmt.Printf("check retry policy failed: %v\n", err)
+			return err
+		}
+	}
+
 	// parse the content
 	whole, err := t.parseContent(path)
 	if err != nil {
@@ -167,6 +275,18 @@ func (t *TreeImportFramework) Import(path string) error {
 		return err
 	}
 
+	if t.ocfg.dryRun {
+		return nil
+	}
+
+	// merge into the existing tree
+	if t.ocfg.mergeSource != nil {
+		if err = t.mergeExisting(whole); err != nil {
+			fmt.Printf("merge existing tree failed: %v\n", err)
+			return err
+		}
+	}
+
 	// pre handle the content
 	if t.preHandler != nil {
 		err = t.preHandler.PreImportHandle(t.db, whole)
@@ -191,6 +311,13 @@ func (t *TreeImportFramework) Import(path string) error {
 		return err
 	}
 
+	if t.ocfg.mergeSource != nil {
+		if err = t.deleteMissing(); err != nil {
+			fmt.Printf("delete missing nodes failed: %v\n", err)
+			return err
+		}
+	}
+
 	// middleware post handle
 	for _, middleware := range t.middlewares {
 		if err = middleware.PostHandle(t.db); err != nil {
@@ -211,6 +338,15 @@ func (t *TreeImportFramework) Import(path string) error {
 	return nil
 }
 
+// registerRecordObservers register the middlewares which observe the recorded errors
+func (t *TreeImportFramework) registerRecordObservers() {
+	for _, middleware := range t.middlewares {
+		if observer, ok := middleware.(util.RecordObserver); ok {
+			t.recorder.AddObserver(observer)
+		}
+	}
+}
+
 // EnableCorrectnessCheck enable the correctness check.
 // must be called before Import.
 func (t *TreeImportFramework) EnableCorrectnessCheck(correctnessCheckers ...excel_import.CorrectnessChecker) error {
@@ -229,16 +365,20 @@ func (t *TreeImportFramework) EnableCorrectnessCheck(correctnessCheckers ...exce
 
 func (t *TreeImportFramework) parseContent(path string) (*rawCellWhole, error) {
 	// read the excel content
-	content, err := util.ReadExcelContent(path)
+	read := util.ReadExcelContent
+	if t.ocfg.mergedCells {
+		read = util.ReadExcelMergedContent
+	}
+	content, err := read(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// pre handle the raw content
-	content = t.preHandleRawContent(content)
+	content, raws, rows := t.preHandleRawContent(content)
 
 	// parse the raw content
-	return t.parseRawWhole(content)
+	return t.parseRawWhole(content, raws, rows)
 }
 
 func (t *TreeImportFramework) checkContent(whole *rawCellWhole) error {
@@ -252,15 +392,30 @@ func (t *TreeImportFramework) checkContent(whole *rawCellWhole) error {
 			}
 
 			if terr != nil {
-				// record the check error
-				// i+t.ocfg.startRow is the real row number, if no filter row
-				if err = t.recorder.RecordCheckError(util.CombineErrors(i+t.ocfg.startRow, terr)); err != nil {
+				// record the check error with the original row number
+				if err = t.recorder.RecordCheckError(util.CombineErrors(whole.rows[i], terr)); err != nil {
 					return err
 				}
 			}
 		}
 	}
 
+	// the conflicts of the node keys and the violations of the tree validation, detected when constructing the tree
+	for _, conflict := range t.conflicts {
+		checkFailed = true
+		rows := make([]int, len(conflict.indexes))
+		for i, index := range conflict.indexes {
+			rows[i] = whole.rows[index]
+		}
+		cerr := conflict.err
+		if len(rows) > 0 {
+			cerr = util.CombineRowsErrors(rows, conflict.err)
+		}
+		if err = t.recorder.RecordCheckError(cerr); err != nil {
+			return err
+		}
+	}
+
 	if checkFailed {
 		return errContentCheckFailed
 	}
@@ -268,17 +423,26 @@ func (t *TreeImportFramework) checkContent(whole *rawCellWhole) error {
 	return nil
 }
 
-func (t *TreeImportFramework) preHandleRawContent(contents [][]string) [][]string {
+// preHandleRawContent returns the handled contents, the contents before formatted for the tree builder,
+// and the original row number of each content
+func (t *TreeImportFramework) preHandleRawContent(contents [][]string) ([][]string, [][]string, []int) {
 	// skip the header default
 	if t.ocfg.startRow > 0 {
 		contents = contents[t.ocfg.startRow:]
 	}
 
+	// keep the original row number
+	rows := make([]int, len(contents))
+	for i := range contents {
+		rows[i] = i + t.ocfg.startRow
+	}
+
 	// end row with func
 	if t.ocfg.ef != nil {
 		for i, row := range contents {
 			if t.ocfg.ef(row) {
 				contents = contents[:i]
+				rows = rows[:i]
 				break
 			}
 		}
@@ -286,7 +450,25 @@ func (t *TreeImportFramework) preHandleRawContent(contents [][]string) [][]strin
 
 	// filter the row
 	if t.ocfg.rowFilterFunc != nil {
-		contents = util.FilterRows(contents, t.ocfg.rowFilterFunc)
+		filtered := make([][]string, 0, len(contents))
+		filteredRows := make([]int, 0, len(rows))
+		for i, row := range contents {
+			if t.ocfg.rowFilterFunc(row) {
+				continue
+			}
+			filtered = append(filtered, row)
+			filteredRows = append(filteredRows, rows[i])
+		}
+		contents, rows = filtered, filteredRows
+	}
+
+	// keep the raw cells for the tree builder, since the cells are formatted in place
+	var raws [][]string
+	if t.ocfg.treeBuilder != nil {
+		raws = make([][]string, len(contents))
+		for i, row := range contents {
+			raws[i] = append([]string(nil), row...)
+		}
 	}
 
 	// format the content
@@ -308,15 +490,66 @@ func (t *TreeImportFramework) preHandleRawContent(contents [][]string) [][]strin
 		contents[i] = row
 	}
 
-	return contents
+	if t.ocfg.fillDown && t.ocfg.treeBuilder == nil {
+		t.fillDown(contents)
+	}
+
+	return contents, raws, rows
+}
+
+// fillDown fill the blank tree cells with the value above, if the parent is the same as the row above.
+// only the blank cells before the last valued tree cell are filled, the rest are the leaf ends.
+func (t *TreeImportFramework) fillDown(contents [][]string) {
+	for i := 1; i < len(contents); i++ {
+		row, above := contents[i], contents[i-1]
+
+		last := -1
+		for level, col := range t.cfg.LevelOrder {
+			if col < len(row) && !t.ocfg.treeColEndFunc(row[col]) {
+				last = level
+			}
+		}
+
+		for level := 0; level < last; level++ {
+			col := t.cfg.LevelOrder[level]
+			if !t.ocfg.treeColEndFunc(row[col]) {
+				continue
+			}
+
+			// the span of the parent ends at the row above
+			if level > 0 {
+				parentCol := t.cfg.LevelOrder[level-1]
+				if row[parentCol] != above[parentCol] {
+					break
+				}
+			}
+			row[col] = above[col]
+
+			// the other cells of the level are written on the first row as well
+			if level < len(t.cfg.Levels) {
+				for _, levelCol := range t.cfg.Levels[level].Columns {
+					if levelCol < len(row) && levelCol < len(above) && t.ocfg.treeColEndFunc(row[levelCol]) {
+						row[levelCol] = above[levelCol]
+					}
+				}
+			}
+		}
+	}
 }
 
-func (t *TreeImportFramework) parseRawWhole(content [][]string) (*rawCellWhole, error) {
+func (t *TreeImportFramework) parseRawWhole(content, raws [][]string, rows []int) (*rawCellWhole, error) {
 	// construct the tree
-	root, err := t.constructTree(content)
+	var root *TreeNode
+	var err error
+	if t.ocfg.treeBuilder != nil {
+		root, err = t.ocfg.treeBuilder.BuildTree(&TreeSource{Contents: content, Raws: raws, Rows: rows})
+	} else {
+		root, err = t.constructTree(content)
+	}
 	if err != nil {
 		return nil, err
 	}
+	t.validateTree(root)
 
 	// parse model tags
 	tags := util.ParseTag(t.cfg.ModelFac.GetModel())
@@ -350,6 +583,7 @@ func (t *TreeImportFramework) parseRawWhole(content [][]string) (*rawCellWhole,
 
 	whole := &rawCellWhole{
 		contents:       content,
+		rows:           rows,
 		cellContents:   cellContents,
 		root:           root,
 		totalNodeCount: totalNodeCount,
@@ -360,6 +594,13 @@ func (t *TreeImportFramework) parseRawWhole(content [][]string) (*rawCellWhole,
 	// fill the rawModel into the leaf tree node
 	t.fillModelIntoNodes(root, models, whole)
 
+	// fill the models of the multi-column levels
+	if t.ocfg.treeBuilder == nil && len(t.cfg.Levels) > 0 {
+		if err = t.fillLevelModels(root, content); err != nil {
+			return nil, err
+		}
+	}
+
 	return whole, nil
 }
 
@@ -376,6 +617,18 @@ func (t *TreeImportFramework) calculateTotalNodeCount(root *TreeNode) int {
 	return count
 }
 
+func countExisting(node *TreeNode) int {
+	count := 0
+	if node.existing {
+		count++
+	}
+	for _, child := range node.children {
+		count += countExisting(child)
+	}
+
+	return count
+}
+
 func (t *TreeImportFramework) fillModelIntoNodes(node *TreeNode, models []any, whole *rawCellWhole) {
 	if node == nil {
 		return
@@ -384,14 +637,15 @@ func (t *TreeImportFramework) fillModelIntoNodes(node *TreeNode, models []any, w
 	// fill the model into the node
 	node.whole = whole
 
-	// fill the model into the leaf node
+	// fill the model and the original row into the leaf node
 	for _, nodeItem := range node.extra.items {
-		if nodeItem.row < t.ocfg.startRow || nodeItem.row >= t.ocfg.startRow+len(models) {
-			fmt.Printf("row %d out of range\n", nodeItem.row)
+		if nodeItem.index < 0 || nodeItem.index >= len(models) {
+			fmt.Printf("content index %d out of range\n", nodeItem.index)
 			continue
 		}
 
-		nodeItem.item = models[nodeItem.row-t.ocfg.startRow]
+		nodeItem.item = models[nodeItem.index]
+		nodeItem.row = whole.rows[nodeItem.index]
 	}
 
 	for _, child := range node.children {
@@ -412,7 +666,8 @@ func (t *TreeImportFramework) checkIsLeaf(i int, row []string) bool {
 }
 
 func (t *TreeImportFramework) importTree(whole *rawCellWhole) error {
-	t.progressReporter.StartProgress(whole.GetNodeCount())
+	// the existing nodes aren't imported
+	t.progressReporter.StartProgress(whole.GetNodeCount() - countExisting(whole.root))
 
 	root := whole.root
 
@@ -421,38 +676,91 @@ func (t *TreeImportFramework) importTree(whole *rawCellWhole) error {
 		return err
 	}
 
-	// import the tree
+	// import the tree, the last importer is for the deeper levels
 	nodes := root.GetChildren()
-	for _, importer := range t.levelImporter {
+	for level := 0; len(nodes) > 0; level++ {
+		importer := t.levelImporter[min(level, len(t.levelImporter)-1)]
+		if err := t.importLevel(importer, nodes); err != nil {
+			return err
+		}
+
 		nextNodes := make([]*TreeNode, 0)
+		for _, node := range nodes {
+			nextNodes = append(nextNodes, node.children...)
+		}
+		nodes = nextNodes
+	}
+
+	return nil
+}
+
+// importLevel import the new nodes of one level
+func (t *TreeImportFramework) importLevel(importer LevelImporter, nodes []*TreeNode) error {
+	if t.ocfg.mergeSource != nil {
+		newNodes := make([]*TreeNode, 0, len(nodes))
+		for _, node := range nodes {
+			if !node.existing {
+				newNodes = append(newNodes, node)
+			}
+		}
+		nodes = newNodes
+	}
+
+	if batchImporter, ok := importer.(LevelBatchImporter); ok {
+		return t.importLevelBatch(batchImporter, nodes)
+	}
+
+	if t.ocfg.maxParallel <= 1 {
 		for _, node := range nodes {
 			if err := t.importLevelNode(importer, node); err != nil {
 				return err
 			}
-			nextNodes = append(nextNodes, node.children...)
 		}
 
-		nodes = nextNodes
+		return nil
 	}
 
-	return nil
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(t.ocfg.maxParallel)
+	for _, node := range nodes {
+		gnode := node
+		eg.Go(func() error {
+			// the rest nodes aren't imported after the failure, the same as the serial import
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return t.importLevelNode(importer, gnode)
+		})
+	}
+
+	return eg.Wait()
 }
 
 func (t *TreeImportFramework) importLevelNode(importer LevelImporter, node *TreeNode) error {
 	status := util.ProgressStatusSuccess
-	defer t.progressReporter.CommitProgress(1, status)
+	defer func() {
+		t.progressReporter.CommitProgress(1, status)
+	}()
 
 	if node == nil || importer == nil {
 		return nil
 	}
 
-	if err := importer.ImportLevelNode(t.db, node); err != nil {
+	_, err := t.ocfg.retryPolicy.Run(t.db, func(tx *gorm.DB) error {
+		return t.ocfg.rateLimiter.Run(1, 1, func() error {
+			return importer.ImportLevelNode(tx, node)
+		})
+	}, t.onRetry)
+	if err != nil {
 		fmt.Printf("import value %s section failed: %v\n", node.GetValue(), err)
 		t.recorder.RecordImportError(util.CombineRowsErrors(node.GetRows(), err))
 		status = util.ProgressStatusFailed
 		return err
 	}
 
+	t.middlewareMu.Lock()
+	defer t.middlewareMu.Unlock()
 	for _, middleware := range t.middlewares {
 		if err := middleware.PostLevelImportHandle(t.db, node); err != nil {
 			fmt.Printf("middleware post level import failed: %v\n", err)
@@ -463,6 +771,56 @@ func (t *TreeImportFramework) importLevelNode(importer LevelImporter, node *Tree
 	return nil
 }
 
+// importLevelBatch import the nodes of one level at once, the level counts as one statement unless the importer counts them
+func (t *TreeImportFramework) importLevelBatch(importer LevelBatchImporter, nodes []*TreeNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	status := util.ProgressStatusSuccess
+	defer func() {
+		t.progressReporter.CommitProgress(len(nodes), status)
+	}()
+
+	statements := 1
+	if counter, ok := importer.(statementCounter); ok {
+		statements = counter.countStatements(len(nodes))
+	}
+
+	_, err := t.ocfg.retryPolicy.Run(t.db, func(tx *gorm.DB) error {
+		return t.ocfg.rateLimiter.Run(len(nodes), statements, func() error {
+			return importer.ImportLevelNodes(tx, nodes)
+		})
+	}, t.onRetry)
+	if err != nil {
+		rows := make([]int, 0, len(nodes))
+		for _, node := range nodes {
+			rows = append(rows, node.GetRows()...)
+		}
+		fmt.Printf("import level %d failed: %v\n", nodes[0].GetRank(), err)
+		t.recorder.RecordImportError(util.CombineRowsErrors(rows, err))
+		status = util.ProgressStatusFailed
+		return err
+	}
+
+	for _, node := range nodes {
+		for _, middleware := range t.middlewares {
+			if err = middleware.PostLevelImportHandle(t.db, node); err != nil {
+				fmt.Printf("middleware post level import failed: %v\n", err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// onRetry count the retried attempt into the progress
+func (t *TreeImportFramework) onRetry(attempt int, err error) {
+	fmt.Printf("attempt %d failed, retrying: %v\n", attempt, err)
+	t.progressReporter.CommitRetry(1)
+}
+
 func (t *TreeImportFramework) constructTree(rcContents [][]string) (*TreeNode, error) {
 	// reverse the matrix
 	contents := util.ReverseMatrix(rcContents)
@@ -480,31 +838,48 @@ func (t *TreeImportFramework) constructTree(rcContents [][]string) (*TreeNode, e
 			}
 
 			// if current node has been constructed, skip it
-			curKey := t.ocfg.genKeyFunc(rcContents[j][:i+1], level+1)
+			path := t.levelPath(rcContents[j], level)
+			curKey := t.ocfg.genKeyFunc(path, level+1)
 			node, ok := t.nodes[curKey]
-			if !ok {
+			if ok {
+				t.checkAmbiguousKey(node, path, j)
+			} else {
+				if t.checkSkippedLevel(path, j) {
+					continue
+				}
+
 				// find the parent node
 				if level > 0 {
-					porder := t.cfg.LevelOrder[level-1]
-					parent = t.findParent(rcContents[j][:porder+1], level)
+					parent = t.findParent(path[:level], level)
 				}
 				if parent == nil {
 					return nil, fmt.Errorf("parent not found for %s", s)
 				}
 
 				// construct the node
+				t.checkDuplicateSibling(parent, s, j)
 				node = constructLevelNode(s, parent, level+1)
 				t.nodes[curKey] = node
 			}
 
 			// add the item into the node
-			node.extra.items = append(node.extra.items, &TreeNodeItem{row: j + t.ocfg.startRow})
+			node.extra.items = append(node.extra.items, &TreeNodeItem{index: j, row: j + t.ocfg.startRow})
 		}
 	}
 
 	return root, nil
 }
 
+// levelPath the values of the levels from the first level to the level of the row
+func (t *TreeImportFramework) levelPath(row []string, level int) []string {
+	path := make([]string, level+1)
+	for l := 0; l <= level; l++ {
+		path[l] = row[t.cfg.LevelOrder[l]]
+	}
+
+	return path
+}
+
 func (t *TreeImportFramework) findParent(s []string, level int) *TreeNode {
 	key := t.ocfg.genKeyFunc(s, level)
 	if node, ok := t.nodes[key]; ok {
@@ -513,6 +888,51 @@ func (t *TreeImportFramework) findParent(s []string, level int) *TreeNode {
 	return nil
 }
 
+// checkAmbiguousKey check if the node of the key has another path, which means the key func merges different nodes
+func (t *TreeImportFramework) checkAmbiguousKey(node *TreeNode, path []string, index int) {
+	nPath := nodePath(node)
+	pathKey := PathNodeKey(path, len(path))
+	if PathNodeKey(nPath, node.rank) == pathKey || t.conflictPaths[pathKey] {
+		return
+	}
+	// report once for every path
+	if t.conflictPaths == nil {
+		t.conflictPaths = make(map[string]bool)
+	}
+	t.conflictPaths[pathKey] = true
+
+	t.conflicts = append(t.conflicts, &nodeConflict{
+		indexes: []int{node.extra.items[0].index, index},
+		err: fmt.Errorf("%w: %s and %s have the same key", ErrAmbiguousNodeKey,
+			strings.Join(nPath, "/"), strings.Join(path, "/")),
+	})
+}
+
+// checkDuplicateSibling check if the parent has the child of the same value, ignoring the case and the spaces.
+// the siblings are probably the same node which is typed differently, or split by the key func.
+func (t *TreeImportFramework) checkDuplicateSibling(parent *TreeNode, value string, index int) {
+	if t.ocfg.validation == nil || !t.ocfg.validation.DuplicateSiblings {
+		return
+	}
+
+	for _, child := range parent.children {
+		if normalizeValue(child.value) != normalizeValue(value) {
+			continue
+		}
+
+		t.conflicts = append(t.conflicts, &nodeConflict{
+			indexes: []int{child.extra.items[0].index, index},
+			err: fmt.Errorf("%w: %q and %q under %s", ErrDuplicateSibling, child.value, value,
+				strings.Join(append([]string{"root"}, nodePath(parent)...), "/")),
+		})
+		return
+	}
+}
+
+func normalizeValue(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), ""))
+}
+
 func (t *TreeImportFramework) CheckCorrect() error {
 	for _, checker := range t.correctCheckers {
 		if err := checker.CheckCorrect(t.db); err != nil {
@@ -1,10 +1,19 @@
 package tree_framework
 
 import (
+	"errors"
+	"excel_import/sink"
 	util "excel_import/utils"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestConstructTree(t *testing.T) {
@@ -15,9 +24,13 @@ func TestConstructTree(t *testing.T) {
 		{"a1", "b2", "c2", "d1", "e7"},
 	}
 	cfg := &TreeImportCfg{
-		LevelOrder: []int{0, 1, 2, 3, 4},
+		LevelOrder:   []int{0, 1, 2, 3, 4},
+		TreeBoundary: 4,
+		ColumnCount:  5,
+		ModelFac:     util.NewSimpleModelFactory(&rawModel{}),
 	}
-	tif := NewTreeImportFramework(nil, cfg, nil, nil)
+	si := &simpleTestDataImporter{}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{si, si, si, si, si})
 
 	root, err := tif.constructTree(contents)
 	if err != nil {
@@ -204,8 +217,9 @@ func TestTreeImportFramework_ImportWithExcelRewriteMiddleware(t *testing.T) {
 	}
 
 	// read leaf id from excel and check it
+	// the leaf id is assigned in level order, and written back to the rows of the leaf
 	leafIDColumnIndex := 6
-	expectedLeafIDs := []int{0, 1, 2, 3, 4, 4}
+	expectedLeafIDs := []int{2, 3, 1, 0, 4, 4}
 	contents, err := util.ReadExcelContent(path)
 	if err != nil {
 		t.Fatal(err)
@@ -259,3 +273,803 @@ func (si *simpleTestDataRewriteImporter) ImportLevelNode(tx *gorm.DB, node *Tree
 
 	return nil
 }
+
+func TestTreeImportFramework_ImportWithExcelRewriteFilteredRows(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree_rewrite_filtered.xlsx")
+	outputPath := filepath.Join(dir, "tree_rewrite_filtered_output.xlsx")
+	contents := [][]string{
+		{"ID", "L1", "", "L2", "L3", "Key", "LeafID"},
+		{"1", "a", "", "b", "c", "k1", ""},
+		{"skip", "a", "", "x", "y", "k2", ""},
+		{"3", "a", "", "b", "d", "k3", ""},
+		{"4", "e", "", "f", "g", "k4", ""},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	si := &simpleTestDataRewriteImporter{}
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{1, 3, 4},
+		TreeBoundary: 4,
+		ModelFac:     &modelRewriteFac{},
+		ColumnCount:  7,
+	}
+	excelRewriteMiddleware := NewExcelRewriterTreeMiddleware(path)
+	excelRewriteMiddleware.SetOutputPath(outputPath)
+	filter := func(s []string) bool {
+		return s[0] == "skip"
+	}
+
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{si, si, si}, WithMiddlewares(excelRewriteMiddleware), WithRowFilter(filter))
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the leaf id is written to the original row of the leaf, the filtered row keeps empty
+	leafIDColumnIndex := 6
+	expectedLeafIDs := []string{"LeafID", "0", "", "1", "2"}
+	result, err := util.ReadExcelContent(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, d := range expectedLeafIDs {
+		if result[i][leafIDColumnIndex] != d {
+			t.Fatalf("row %d leaf id is %s, expect %s", i, result[i][leafIDColumnIndex], d)
+		}
+	}
+}
+
+type sinkTreeModel struct {
+	L1 string `exi:"index:0"`
+	L2 string `exi:"index:1"`
+	L3 string `exi:"index:2"`
+}
+
+func TestTreeImportFramework_ImportIntoSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree_sink.csv")
+	contents := [][]string{
+		{"L1", "L2", "L3"},
+		{"a", "b", "c"},
+		{"a", "b", "d"},
+		{"e", "f", "g"},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	memorySink := sink.NewMemorySink()
+	tif := NewSinkTreeFramework(memorySink, cfg, nil)
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the children are linked to the id generated by the sink
+	expected := []struct {
+		value    string
+		level    int
+		parentID int64
+		rows     []int
+	}{
+		{"a", 1, 0, []int{1, 2}},
+		{"e", 1, 0, []int{3}},
+		{"b", 2, 1, []int{1, 2}},
+		{"f", 2, 2, []int{3}},
+		{"c", 3, 3, []int{1}},
+		{"d", 3, 3, []int{2}},
+		{"g", 3, 4, []int{3}},
+	}
+	records := memorySink.GetRecords()
+	if len(records) != len(expected) || !memorySink.CheckFlushed() {
+		t.Fatalf("got %d records, expected %d, flushed %v", len(records), len(expected), memorySink.CheckFlushed())
+	}
+	for i, e := range expected {
+		record := records[i]
+		if record.Model.(*SinkNodeModel).Value != e.value || record.Level != e.level || record.ParentID != e.parentID ||
+			!reflect.DeepEqual(record.Rows, e.rows) {
+			t.Fatalf("record %d is %+v, expected %+v", i, record, e)
+		}
+	}
+}
+
+// parallelTestImporter assign the ids and check the parents have been imported
+type parallelTestImporter struct {
+	nextID     int64
+	running    int64
+	maxRunning int64
+	orphans    int64
+}
+
+func (pi *parallelTestImporter) ImportLevelNode(tx *gorm.DB, node *TreeNode) error {
+	running := atomic.AddInt64(&pi.running, 1)
+	defer atomic.AddInt64(&pi.running, -1)
+	for {
+		maxRunning := atomic.LoadInt64(&pi.maxRunning)
+		if running <= maxRunning || atomic.CompareAndSwapInt64(&pi.maxRunning, maxRunning, running) {
+			break
+		}
+	}
+
+	if node.GetRank() > 1 && node.GetParent().GetID() == 0 {
+		atomic.AddInt64(&pi.orphans, 1)
+	}
+	time.Sleep(time.Millisecond)
+	node.SetID(atomic.AddInt64(&pi.nextID, 1))
+
+	return nil
+}
+
+// countTreeMiddleware count the imported nodes without the lock
+type countTreeMiddleware struct {
+	count int
+}
+
+func (c *countTreeMiddleware) PreImportHandle(tx *gorm.DB, info TreeInfo) error {
+	return nil
+}
+
+func (c *countTreeMiddleware) PostLevelImportHandle(tx *gorm.DB, node *TreeNode) error {
+	c.count++
+	return nil
+}
+
+func (c *countTreeMiddleware) PostHandle(tx *gorm.DB) error {
+	return nil
+}
+
+func TestTreeImportFramework_ImportParallel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree_parallel.csv")
+	contents := [][]string{{"L1", "L2", "L3"}}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			contents = append(contents, []string{"a" + strconv.Itoa(i), "b" + strconv.Itoa(i*4+j), "c" + strconv.Itoa(i*4+j)})
+		}
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	pi := &parallelTestImporter{}
+	middleware := &countTreeMiddleware{}
+	reporter := util.NewProgressReporter(false)
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi, pi},
+		WithParallel(4), WithMiddlewares(middleware), WithProgressReporter(reporter))
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 + 16 + 16 nodes
+	if pi.nextID != 36 || middleware.count != 36 || pi.orphans != 0 {
+		t.Fatalf("imported %d nodes, handled %d nodes, %d orphans", pi.nextID, middleware.count, pi.orphans)
+	}
+	if pi.maxRunning < 2 || pi.maxRunning > 4 {
+		t.Fatalf("max running is %d, expected in [2, 4]", pi.maxRunning)
+	}
+	if reporter.GetSuccess() != reporter.GetTotal() || reporter.GetFailed() != 0 {
+		t.Fatalf("progress success %d, failed %d, total %d", reporter.GetSuccess(), reporter.GetFailed(), reporter.GetTotal())
+	}
+}
+
+type batchTreeCategory struct {
+	ID       int64 `gorm:"primaryKey"`
+	Name     string
+	ParentID int64
+	Level    int
+}
+
+func TestTreeImportFramework_ImportBatch(t *testing.T) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "tree_batch.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&batchTreeCategory{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// count the insert statements
+	statements := 0
+	if err = db.Callback().Create().After("gorm:create").Register("count_statements", func(db *gorm.DB) {
+		statements++
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "tree_batch.csv")
+	contents := [][]string{
+		{"L1", "L2", "L3"},
+		{"a", "b", "c"},
+		{"a", "b", "d"},
+		{"e", "f", "g"},
+	}
+	if err = util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	mapper := func(node *TreeNode, parentID int64) (any, error) {
+		return &batchTreeCategory{Name: node.GetValue(), ParentID: parentID, Level: node.GetRank()}, nil
+	}
+	reporter := util.NewProgressReporter(false)
+	tif := NewBatchTreeImportFramework(db, cfg, mapper, 0, WithProgressReporter(reporter))
+	if err = tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// one statement per level
+	if statements != 3 {
+		t.Fatalf("got %d insert statements, expected 3", statements)
+	}
+	if reporter.GetSuccess() != reporter.GetTotal() {
+		t.Fatalf("progress success %d, total %d", reporter.GetSuccess(), reporter.GetTotal())
+	}
+
+	var categories []*batchTreeCategory
+	if err = db.Order("id").Find(&categories).Error; err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int64)
+	for _, category := range categories {
+		ids[category.Name] = category.ID
+	}
+	parents := map[string]string{"b": "a", "f": "e", "c": "b", "d": "b", "g": "f"}
+	if len(categories) != 7 {
+		t.Fatalf("unexpected categories %+v", categories)
+	}
+	for _, category := range categories {
+		if parent, ok := parents[category.Name]; ok && category.ParentID != ids[parent] || !ok && category.ParentID != 0 {
+			t.Fatalf("category %+v isn't linked to %s", category, parent)
+		}
+	}
+}
+
+// pathTestImporter collect the paths of the imported nodes
+type pathTestImporter struct {
+	paths []string
+	rows  map[string][]int
+}
+
+func (pi *pathTestImporter) ImportLevelNode(tx *gorm.DB, node *TreeNode) error {
+	path := node.GetValue()
+	for parent := node.GetParent(); !parent.CheckIsRoot(); parent = parent.GetParent() {
+		path = parent.GetValue() + "/" + path
+	}
+	pi.paths = append(pi.paths, path)
+	pi.rows[path] = node.GetRows()
+
+	return nil
+}
+
+func TestTreeImportFramework_ImportFillDown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree_fill_down.xlsx")
+	f := excelize.NewFile()
+	rows := [][]string{
+		{"L1", "L2", "L3"},
+		{"食品", "水果", "苹果"},
+		{"", "", "香蕉"},
+		{"", "蔬菜", "白菜"},
+		{"日用", "", ""},
+		{"", "纸巾", ""},
+	}
+	for i, row := range rows {
+		for j, value := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			if err := f.SetCellStr("Sheet1", cell, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// the first level is merged, the second level is blank
+	if err := f.MergeCell("Sheet1", "A2", "A4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	pi := &pathTestImporter{rows: make(map[string][]int)}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi, pi}, WithFillDown(), WithMergedCells())
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"食品", "日用", "食品/水果", "食品/蔬菜", "日用/纸巾", "食品/水果/苹果", "食品/水果/香蕉", "食品/蔬菜/白菜"}
+	if !reflect.DeepEqual(pi.paths, expected) {
+		t.Fatalf("paths are %v, expected %v", pi.paths, expected)
+	}
+	// the leaf ends are kept, so the rows are attached to the leaves
+	if !reflect.DeepEqual(pi.rows["日用"], []int{4, 5}) || !reflect.DeepEqual(pi.rows["日用/纸巾"], []int{5}) {
+		t.Fatalf("unexpected rows %v", pi.rows)
+	}
+}
+
+type twoLevelTreeModel struct {
+	L1 string `exi:"index:0"`
+	L2 string `exi:"index:1"`
+}
+
+func TestTreeImportFramework_ImportKeyConflicts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree_conflicts.csv")
+	contents := [][]string{
+		{"L1", "L2"},
+		{"水果", "其他"},
+		{"蔬菜", "其他"},
+		{"蔬菜", "白菜"},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1},
+		TreeBoundary: 1,
+		ModelFac:     util.NewSimpleModelFactory(&twoLevelTreeModel{}),
+		ColumnCount:  2,
+	}
+	newRecorder := func() *util.UnexpectedRecorder {
+		return util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+			filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	}
+
+	// the children of the same value under different parents are different nodes
+	pi := &pathTestImporter{rows: make(map[string][]int)}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi}, WithRecorder(newRecorder()))
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"水果", "蔬菜", "水果/其他", "蔬菜/其他", "蔬菜/白菜"}; !reflect.DeepEqual(pi.paths, expected) {
+		t.Fatalf("paths are %v, expected %v", pi.paths, expected)
+	}
+
+	// the key by the value merges them, which is reported as the ambiguous key
+	recorder := newRecorder()
+	tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi}, WithRecorder(recorder), WithGenKeyFunc(ValueNodeKey))
+	if err := tif.Import(path); err == nil {
+		t.Fatal("expected the check error of the ambiguous key")
+	}
+	failures, err := util.ReadExcelContent(recorder.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0][0], ErrAmbiguousNodeKey.Error()) || !strings.Contains(failures[0][0], "水果/其他") {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+
+	// the siblings differ only in the case and the spaces
+	contents = append(contents, []string{"蔬菜", "Bok Choy"}, []string{"蔬菜", "bokchoy"})
+	if err = util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+	tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi}, WithRecorder(newRecorder()))
+	if err = tif.Import(path); err != nil {
+		t.Fatalf("the duplicate siblings should be imported if not validated, got %v", err)
+	}
+	recorder = newRecorder()
+	tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi}, WithRecorder(recorder),
+		WithTreeValidation(&TreeValidation{DuplicateSiblings: true}))
+	if err = tif.Import(path); err == nil {
+		t.Fatal("expected the check error of the duplicate siblings")
+	}
+	if failures, err = util.ReadExcelContent(recorder.GetCheckFailedPath()); err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0][0], ErrDuplicateSibling.Error()) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+}
+
+func TestTreeImportFramework_ImportTreeBuilders(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &TreeImportCfg{ModelFac: util.NewSimpleModelFactory(&sinkTreeModel{}), ColumnCount: 3}
+	expected := []string{"食品", "日用", "食品/水果", "食品/蔬菜", "食品/水果/苹果"}
+
+	cases := []struct {
+		name     string
+		contents [][]string
+		builder  TreeBuilder
+	}{
+		{
+			name:     "adjacency",
+			contents: [][]string{{"ID", "上级ID", "名称"}, {"3", "2", "苹果"}, {"1", "0", "食品"}, {"2", "1", "水果"}, {"4", "1", "蔬菜"}, {"5", "", "日用"}},
+			builder:  NewAdjacencyTreeBuilder(0, 1, 2),
+		},
+		{
+			name:     "outline",
+			contents: [][]string{{"名称"}, {"1 食品"}, {"1.1. 水果"}, {"1.1.1 苹果"}, {"1.2 蔬菜"}, {"2 日用"}},
+			builder:  NewOutlineTreeBuilder(0, 0),
+		},
+		{
+			name:     "indent",
+			contents: [][]string{{"名称"}, {"食品"}, {"  水果"}, {"\t\t苹果"}, {"　蔬菜"}, {"日用"}},
+			builder:  NewIndentTreeBuilder(0, 2),
+		},
+		{
+			name:     "path",
+			contents: [][]string{{"路径"}, {"食品/水果/苹果"}, {"食品 / 蔬菜"}, {"日用"}},
+			builder:  NewPathTreeBuilder(0, ""),
+		},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name+".csv")
+		if err := util.WriteExcelContent(path, c.contents); err != nil {
+			t.Fatal(err)
+		}
+
+		// the only level importer is used for all the levels
+		pi := &pathTestImporter{rows: make(map[string][]int)}
+		tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi}, WithTreeBuilder(c.builder))
+		if err := tif.Import(path); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(pi.paths, expected) {
+			t.Fatalf("%s: paths are %v, expected %v", c.name, pi.paths, expected)
+		}
+	}
+
+	// the rows are attached to the nodes of the rows
+	path := filepath.Join(dir, "adjacency.csv")
+	pi := &pathTestImporter{rows: make(map[string][]int)}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi}, WithTreeBuilder(NewAdjacencyTreeBuilder(0, 1, 2)))
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pi.rows["食品/水果/苹果"], []int{1}) || !reflect.DeepEqual(pi.rows["日用"], []int{5}) {
+		t.Fatalf("unexpected rows %v", pi.rows)
+	}
+
+	// the broken structures fail the parse
+	broken := []struct {
+		contents [][]string
+		builder  TreeBuilder
+		err      error
+	}{
+		{[][]string{{"ID", "上级ID", "名称"}, {"1", "", "食品"}, {"2", "9", "水果"}}, NewAdjacencyTreeBuilder(0, 1, 2), ErrParentNotFound},
+		{[][]string{{"ID", "上级ID", "名称"}, {"1", "2", "食品"}, {"2", "1", "水果"}}, NewAdjacencyTreeBuilder(0, 1, 2), ErrTreeCycle},
+		{[][]string{{"ID", "上级ID", "名称"}, {"1", "", "食品"}, {"1", "", "水果"}}, NewAdjacencyTreeBuilder(0, 1, 2), ErrDuplicateNodeID},
+		{[][]string{{"名称"}, {"1 食品"}, {"一 水果"}}, NewOutlineTreeBuilder(0, 0), ErrOutlineCode},
+		{[][]string{{"名称"}, {"食品"}, {"    苹果"}}, NewIndentTreeBuilder(0, 2), ErrSkippedLevel},
+	}
+	for i, b := range broken {
+		if err := util.WriteExcelContent(path, b.contents); err != nil {
+			t.Fatal(err)
+		}
+		tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi}, WithTreeBuilder(b.builder))
+		if err := tif.Import(path); !errors.Is(err, b.err) {
+			t.Fatalf("case %d: got %v, expected %v", i, err, b.err)
+		}
+	}
+}
+
+func TestTreeImportFramework_ImportMerge(t *testing.T) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "tree_merge.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&batchTreeCategory{}); err != nil {
+		t.Fatal(err)
+	}
+	existing := []*batchTreeCategory{
+		{ID: 1, Name: "食品", Level: 1}, {ID: 2, Name: "水果", ParentID: 1, Level: 2},
+		{ID: 3, Name: "苹果", ParentID: 2, Level: 3}, {ID: 4, Name: "日用", Level: 1},
+	}
+	if err = db.Create(existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "tree_merge.csv")
+	contents := [][]string{
+		{"L1", "L2", "L3"},
+		{"食品", "水果", "苹果"},
+		{"食品", "水果", "香蕉"},
+		{"食品", "蔬菜", "白菜"},
+	}
+	if err = util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	imported := make([]string, 0)
+	mapper := func(node *TreeNode, parentID int64) (any, error) {
+		if node.IsExisting() {
+			t.Fatalf("the existing node %s is imported", node.GetValue())
+		}
+		imported = append(imported, node.GetValue())
+		return &batchTreeCategory{Name: node.GetValue(), ParentID: parentID, Level: node.GetRank()}, nil
+	}
+	source := NewTableTreeSource("batch_tree_categories", "id", "parent_id", "name")
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	reporter := util.NewProgressReporter(false)
+	tif := NewBatchTreeImportFramework(db, cfg, mapper, 0,
+		WithMerge(source, MissingNodeReport), WithRecorder(recorder), WithProgressReporter(reporter))
+	if err = tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the new nodes are imported, and linked to the existing parents
+	if expected := []string{"蔬菜", "香蕉", "白菜"}; !reflect.DeepEqual(imported, expected) {
+		t.Fatalf("imported %v, expected %v", imported, expected)
+	}
+	if reporter.GetTotal() != 4 {
+		t.Fatalf("progress total is %d, expected the root and the new nodes", reporter.GetTotal())
+	}
+	var banana batchTreeCategory
+	if err = db.First(&banana, "name = ?", "香蕉").Error; err != nil || banana.ParentID != 2 {
+		t.Fatalf("unexpected banana %+v, err %v", banana, err)
+	}
+	missing := tif.GetMissingNodes()
+	if len(missing) != 1 || missing[0].ID != 4 || !reflect.DeepEqual(missing[0].GetPath(), []string{"日用"}) {
+		t.Fatalf("unexpected missing nodes %+v", missing)
+	}
+	failures, err := util.ReadExcelContent(recorder.GetImportFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0][0], ErrMissingNode.Error()) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+
+	// the second merge imports nothing and deletes the missing node
+	imported = imported[:0]
+	tif = NewBatchTreeImportFramework(db, cfg, mapper, 0,
+		WithMerge(source, MissingNodeDelete), WithProgressReporter(util.NewProgressReporter(false)))
+	if err = tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 0 {
+		t.Fatalf("imported %v again", imported)
+	}
+	var count int64
+	if err = db.Model(&batchTreeCategory{}).Count(&count).Error; err != nil || count != 6 {
+		t.Fatalf("got %d categories, err %v", count, err)
+	}
+	if err = db.First(&batchTreeCategory{}, 4).Error; err == nil {
+		t.Fatal("the missing node isn't deleted")
+	}
+}
+
+func TestTreeImportFramework_ImportValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree_validation.csv")
+	contents := [][]string{
+		{"L1", "L2", "L3"},
+		{"食品", "水果", "苹果"},
+		{"食品", "水果", "香蕉"},
+		{"食品", "水果", "梨"},
+		{"食品", "水果", "葡萄"},
+		{"食品", "", "白菜"},
+		{"日用", "纸巾", ""},
+		{"饮料", "水果", "橙汁"},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &TreeImportCfg{
+		LevelOrder:   []int{0, 1, 2},
+		TreeBoundary: 2,
+		ModelFac:     util.NewSimpleModelFactory(&sinkTreeModel{}),
+		ColumnCount:  3,
+	}
+	newRecorder := func() *util.UnexpectedRecorder {
+		return util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+			filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	}
+
+	recorder := newRecorder()
+	pi := &pathTestImporter{rows: make(map[string][]int)}
+	validation := &TreeValidation{MaxDepth: 3, MinLeafDepth: 3, MaxChildren: 3, SkippedLevels: true, ParentConflicts: true}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi, pi, pi}, WithRecorder(recorder), WithTreeValidation(validation))
+	if err := tif.Import(path); err == nil {
+		t.Fatal("expected the check error of the validation")
+	}
+	if len(pi.paths) != 0 {
+		t.Fatalf("imported %v after the check failed", pi.paths)
+	}
+
+	failures, err := util.ReadExcelContent(recorder.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[error]string{
+		ErrSkippedLevel:   "第6行",
+		ErrMaxChildren:    "第5行",
+		ErrMinLeafDepth:   "第7行",
+		ErrParentConflict: "第2, 8行",
+	}
+	if len(failures) != len(expected) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	for verr, row := range expected {
+		found := false
+		for _, failure := range failures {
+			if strings.Contains(failure[0], verr.Error()) && strings.HasPrefix(failure[0], row) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%v of %s isn't reported in %v", verr, row, failures)
+		}
+	}
+
+	// the trees built by the tree builder are validated as well
+	contents = [][]string{{"路径"}, {"a/b/c"}, {"a/B"}, {"a/ b"}}
+	if err = util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+	recorder = newRecorder()
+	validation = &TreeValidation{MaxDepth: 2, DuplicateSiblings: true}
+	tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{pi},
+		WithTreeBuilder(NewPathTreeBuilder(0, "/")), WithRecorder(recorder), WithTreeValidation(validation))
+	if err = tif.Import(path); err == nil {
+		t.Fatal("expected the check error of the validation")
+	}
+	if failures, err = util.ReadExcelContent(recorder.GetCheckFailedPath()); err != nil {
+		t.Fatal(err)
+	}
+	// the siblings are checked before the children
+	if len(failures) != 2 || !strings.Contains(failures[0][0], ErrDuplicateSibling.Error()) ||
+		!strings.HasPrefix(failures[0][0], "第2, 3行") || !strings.Contains(failures[1][0], ErrMaxDepth.Error()) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+}
+
+// levelTestModel the code and the name of one level
+type levelTestModel struct {
+	Code string `exi:"index:0"`
+	Name string `exi:"index:1"`
+}
+
+type levelLeafTestModel struct {
+	L1Code string  `exi:"index:0"`
+	L1Name string  `exi:"index:1"`
+	L2Code string  `exi:"index:2"`
+	L2Name string  `exi:"index:3"`
+	Price  float64 `exi:"index:4,fcf:float"`
+}
+
+// levelTestImporter collect the level models by the path of the keys
+type levelTestImporter struct {
+	models map[string]*levelTestModel
+}
+
+func (li *levelTestImporter) ImportLevelNode(tx *gorm.DB, node *TreeNode) error {
+	li.models[strings.Join(node.PathValues(), "/")] = node.GetLevelModel().(*levelTestModel)
+	return nil
+}
+
+func TestTreeImportFramework_ImportLevels(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree_levels.csv")
+	contents := [][]string{
+		{"一级编码", "一级名称", "二级编码", "二级名称", "价格"},
+		{"F", "食品", "F1", "水果", "1"},
+		{"F", "食品", "F2", "蔬菜", "2"},
+		{"D", "日用", "F1", "纸巾", "3"},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	levelFac := util.NewSimpleModelFactory(&levelTestModel{})
+	cfg := &TreeImportCfg{
+		Levels: []*TreeLevel{
+			{Columns: []int{0, 1}, KeyColumn: 0, ModelFac: levelFac},
+			{Columns: []int{2, 3}, KeyColumn: 2, ModelFac: levelFac},
+		},
+		ColumnCount: 5,
+		ModelFac:    util.NewSimpleModelFactory(&levelLeafTestModel{}),
+	}
+	newRecorder := func() *util.UnexpectedRecorder {
+		return util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+			filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+	}
+
+	li := &levelTestImporter{models: make(map[string]*levelTestModel)}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{li, li}, WithRecorder(newRecorder()))
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the nodes are keyed by the codes, so the same code under different parents are different nodes
+	expected := map[string]*levelTestModel{
+		"F": {Code: "F", Name: "食品"}, "D": {Code: "D", Name: "日用"},
+		"F/F1": {Code: "F1", Name: "水果"}, "F/F2": {Code: "F2", Name: "蔬菜"}, "D/F1": {Code: "F1", Name: "纸巾"},
+	}
+	if !reflect.DeepEqual(li.models, expected) {
+		t.Fatalf("level models are %v, expected %v", li.models, expected)
+	}
+	if len(cfg.LevelOrder) != 0 {
+		t.Fatal("the cfg is changed by the levels")
+	}
+
+	// the rows of the same node with the different names
+	contents = append(contents, []string{"F", "食物", "F3", "肉类", "4"})
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+	recorder := newRecorder()
+	tif = NewTreeImportFramework(nil, cfg, nil, []LevelImporter{li, li}, WithRecorder(recorder))
+	if err := tif.Import(path); err == nil {
+		t.Fatal("expected the check error of the level conflict")
+	}
+	failures, err := util.ReadExcelContent(recorder.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || !strings.HasPrefix(failures[0][0], "第2, 5行") || !strings.Contains(failures[0][0], ErrLevelModelConflict.Error()) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+}
+
+func TestTreeImportFramework_FillDownLevels(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree_fill_down_levels.csv")
+	contents := [][]string{
+		{"一级编码", "一级名称", "二级编码", "二级名称", "价格"},
+		{"F", "食品", "F1", "水果", "1"},
+		{"", "", "F2", "蔬菜", "2"},
+		{"D", "日用", "F1", "纸巾", "3"},
+	}
+	if err := util.WriteExcelContent(path, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	levelFac := util.NewSimpleModelFactory(&levelTestModel{})
+	cfg := &TreeImportCfg{
+		Levels: []*TreeLevel{
+			{Columns: []int{0, 1}, KeyColumn: 0, ModelFac: levelFac},
+			{Columns: []int{2, 3}, KeyColumn: 2, ModelFac: levelFac},
+		},
+		ColumnCount: 5,
+		ModelFac:    util.NewSimpleModelFactory(&levelLeafTestModel{}),
+	}
+	recorder := util.NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+
+	// the name of the first level is filled along with the code, so the rows of F don't conflict
+	li := &levelTestImporter{models: make(map[string]*levelTestModel)}
+	tif := NewTreeImportFramework(nil, cfg, nil, []LevelImporter{li, li}, WithRecorder(recorder), WithFillDown())
+	if err := tif.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]*levelTestModel{
+		"F": {Code: "F", Name: "食品"}, "D": {Code: "D", Name: "日用"},
+		"F/F1": {Code: "F1", Name: "水果"}, "F/F2": {Code: "F2", Name: "蔬菜"}, "D/F1": {Code: "F1", Name: "纸巾"},
+	}
+	if !reflect.DeepEqual(li.models, expected) {
+		t.Fatalf("level models are %v, expected %v", li.models, expected)
+	}
+}
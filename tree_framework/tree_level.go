@@ -0,0 +1,99 @@
+package tree_framework
+
+import (
+	"errors"
+	"excel_import"
+	util "excel_import/utils"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrLevelModelConflict = errors.New("the level cells of the node differ")
+
+// TreeLevel the level of the tree which spans several columns, e.g. the code and the name of the level.
+// the node value and the key come from the key column, the other columns are filled into the level model.
+type TreeLevel struct {
+	// the columns of the level in the sheet
+	Columns []int
+	// the column of the node value, should be one of the columns
+	KeyColumn int
+	// the factory of the level model, optional. the exi index of the model is the index in the columns,
+	// so the levels of the same layout can share the model.
+	ModelFac excel_import.RowModelFactory
+}
+
+// resolveLevels derive the level order and the tree boundary from the levels
+func resolveLevels(cfg *TreeImportCfg) *TreeImportCfg {
+	resolved := *cfg
+	resolved.LevelOrder = make([]int, len(cfg.Levels))
+	resolved.TreeBoundary = 0
+	for i, level := range cfg.Levels {
+		if !slices.Contains(level.Columns, level.KeyColumn) {
+			panic(fmt.Sprintf("the key column %d of level %d should be one of the columns", level.KeyColumn, i+1))
+		}
+
+		resolved.LevelOrder[i] = level.KeyColumn
+		for _, col := range level.Columns {
+			resolved.TreeBoundary = max(resolved.TreeBoundary, col)
+		}
+	}
+
+	return &resolved
+}
+
+// fillLevelModels fill the level models of the nodes by the cells of their first rows.
+// the rows of one node with the different level cells are reported as the conflicts.
+func (t *TreeImportFramework) fillLevelModels(root *TreeNode, contents [][]string) error {
+	tags := make([][]*excel_import.ExcelImportTagAttr, len(t.cfg.Levels))
+	for i, level := range t.cfg.Levels {
+		if level.ModelFac != nil {
+			tags[i] = util.ParseTag(level.ModelFac.GetModel())
+		}
+	}
+
+	var err error
+	root.Walk(WalkDFS, func(node *TreeNode) bool {
+		if node.CheckIsRoot() || node.rank > len(t.cfg.Levels) || len(node.extra.items) == 0 {
+			return true
+		}
+
+		level := t.cfg.Levels[node.rank-1]
+		first := node.extra.items[0].index
+		cells := levelCells(contents[first], level.Columns)
+		for _, item := range node.extra.items[1:] {
+			if other := levelCells(contents[item.index], level.Columns); !slices.Equal(cells, other) {
+				t.conflicts = append(t.conflicts, &nodeConflict{
+					indexes: []int{first, item.index},
+					err: fmt.Errorf("%w: %s and %s of %s", ErrLevelModelConflict,
+						strings.Join(cells, ","), strings.Join(other, ","), strings.Join(nodePath(node), "/")),
+				})
+				break
+			}
+		}
+
+		if level.ModelFac == nil {
+			return true
+		}
+		model := level.ModelFac.GetModel()
+		if err = util.FillModelByTags(tags[node.rank-1], model, cells); err != nil {
+			err = util.CombineErrors(node.extra.items[0].row, err)
+			return false
+		}
+		node.levelModel = model
+		return true
+	}, nil)
+
+	return err
+}
+
+func levelCells(row []string, columns []int) []string {
+	cells := make([]string, len(columns))
+	for i, col := range columns {
+		if col < len(row) {
+			cells[i] = row[col]
+		}
+	}
+
+	return cells
+}
@@ -0,0 +1,206 @@
+package tree_framework
+
+import (
+	"errors"
+	util "excel_import/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var ErrMissingNode = errors.New("the existing node is missing from the file")
+
+type MissingNodePolicy int
+
+const (
+	// MissingNodeIgnore keep the existing nodes missing from the file
+	MissingNodeIgnore MissingNodePolicy = iota
+	// MissingNodeReport record the existing nodes missing from the file as the import errors
+	MissingNodeReport
+	// MissingNodeDelete delete the existing nodes missing from the file after the import
+	MissingNodeDelete
+)
+
+// ExistingNode the node of the existing tree, the parent id of the first level is 0
+type ExistingNode struct {
+	ID       int64  `gorm:"column:id"`
+	ParentID int64  `gorm:"column:parent_id"`
+	Value    string `gorm:"column:value"`
+	// the values of the levels from the first level, set when merged
+	path []string
+}
+
+// GetPath return the values of the levels from the first level to the node
+func (e *ExistingNode) GetPath() []string {
+	return e.path
+}
+
+// ExistingTreeSource the existing tree which the file is merged into
+type ExistingTreeSource interface {
+	// LoadNodes load all the nodes of the existing tree
+	LoadNodes(tx *gorm.DB) ([]*ExistingNode, error)
+	// DeleteNodes delete the nodes missing from the file
+	DeleteNodes(tx *gorm.DB, nodes []*ExistingNode) error
+}
+
+// TableTreeSource the existing tree stored in one table by the id, the parent id and the value columns.
+// the scopes limit the nodes of the tree, e.g. the tree of one tenant.
+type TableTreeSource struct {
+	table     string
+	idCol     string
+	parentCol string
+	valueCol  string
+	scopes    []func(*gorm.DB) *gorm.DB
+}
+
+func NewTableTreeSource(table, idCol, parentCol, valueCol string, scopes ...func(*gorm.DB) *gorm.DB) *TableTreeSource {
+	return &TableTreeSource{
+		table:     table,
+		idCol:     idCol,
+		parentCol: parentCol,
+		valueCol:  valueCol,
+		scopes:    scopes,
+	}
+}
+
+func (ts *TableTreeSource) LoadNodes(tx *gorm.DB) ([]*ExistingNode, error) {
+	nodes := make([]*ExistingNode, 0)
+	err := tx.Table(ts.table).Scopes(ts.scopes...).
+		Select(fmt.Sprintf("%s AS id, COALESCE(%s, 0) AS parent_id, %s AS value", ts.idCol, ts.parentCol, ts.valueCol)).
+		Order(ts.idCol).Find(&nodes).Error
+
+	return nodes, err
+}
+
+func (ts *TableTreeSource) DeleteNodes(tx *gorm.DB, nodes []*ExistingNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+
+	return tx.Table(ts.table).Scopes(ts.scopes...).Where(map[string]any{ts.idCol: ids}).Delete(nil).Error
+}
+
+// WithMerge merge the file into the existing tree instead of creating a new tree.
+// the nodes are matched by the path, the matched nodes are set with the existing ids and marked as existing,
+// and only the new nodes are passed to the level importers and the middlewares.
+// the existing nodes missing from the file are handled by the policy, deleted after the import succeeds.
+func WithMerge(source ExistingTreeSource, policy MissingNodePolicy) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.mergeSource = source
+		framework.ocfg.missingPolicy = policy
+	}
+}
+
+// GetMissingNodes return the existing nodes missing from the file, available after the merge
+func (t *TreeImportFramework) GetMissingNodes() []*ExistingNode {
+	return t.missingNodes
+}
+
+// mergeExisting match the nodes with the existing tree, and report the missing nodes by the policy
+func (t *TreeImportFramework) mergeExisting(whole *rawCellWhole) error {
+	existings, err := t.ocfg.mergeSource.LoadNodes(t.db)
+	if err != nil {
+		return err
+	}
+
+	// key the existing nodes by the path, the former node wins for the same path
+	resolved := existingPaths(existings)
+	byPath := make(map[string]*ExistingNode, len(resolved))
+	for _, existing := range resolved {
+		key := PathNodeKey(existing.path, len(existing.path))
+		if _, ok := byPath[key]; !ok {
+			byPath[key] = existing
+		}
+	}
+
+	matched := make(map[*ExistingNode]bool, len(byPath))
+	t.matchExisting(whole.root.children, byPath, matched)
+
+	t.missingNodes = make([]*ExistingNode, 0)
+	for _, existing := range resolved {
+		if !matched[existing] {
+			t.missingNodes = append(t.missingNodes, existing)
+		}
+	}
+
+	if t.ocfg.missingPolicy != MissingNodeReport {
+		return nil
+	}
+	for _, missing := range t.missingNodes {
+		path := strings.Join(missing.path, "/")
+		if err = t.recorder.RecordImportErrorWithContent(fmt.Errorf("%w: %s (id %d)", ErrMissingNode, path, missing.ID), path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TreeImportFramework) matchExisting(nodes []*TreeNode, byPath map[string]*ExistingNode, matched map[*ExistingNode]bool) {
+	for _, node := range nodes {
+		existing, ok := byPath[PathNodeKey(nodePath(node), node.rank)]
+		if !ok {
+			// the children of the new node are new
+			continue
+		}
+
+		node.SetID(existing.ID)
+		node.existing = true
+		matched[existing] = true
+		t.matchExisting(node.children, byPath, matched)
+	}
+}
+
+// deleteMissing delete the missing nodes if the policy is delete
+func (t *TreeImportFramework) deleteMissing() error {
+	if t.ocfg.missingPolicy != MissingNodeDelete || len(t.missingNodes) == 0 {
+		return nil
+	}
+
+	return t.ocfg.mergeSource.DeleteNodes(t.db, t.missingNodes)
+}
+
+// existingPaths set the paths of the existing nodes and return the nodes with the path.
+// the nodes whose ancestors are missing or cyclic aren't in the tree, they are neither matched nor missing.
+func existingPaths(existings []*ExistingNode) []*ExistingNode {
+	byID := make(map[int64]*ExistingNode, len(existings))
+	for _, existing := range existings {
+		byID[existing.ID] = existing
+	}
+
+	resolved := make([]*ExistingNode, 0, len(existings))
+	for _, existing := range existings {
+		path := make([]string, 0)
+		visited := make(map[int64]bool)
+		ok := true
+		for n := existing; n != nil; n = byID[n.ParentID] {
+			if visited[n.ID] {
+				ok = false
+				break
+			}
+			visited[n.ID] = true
+			path = append([]string{util.FormatCell(n.Value)}, path...)
+
+			if n.ParentID == 0 {
+				break
+			}
+			if _, found := byID[n.ParentID]; !found {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		existing.path = path
+		resolved = append(resolved, existing)
+	}
+
+	return resolved
+}
@@ -4,10 +4,13 @@ import (
 	"excel_import"
 	util "excel_import/utils"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 var (
-	defaultKeyGen = genNodeKey
+	defaultKeyGen = PathNodeKey
 	defaultOptCfg = &treeImportOptionalCfg{
 		genKeyFunc:     defaultKeyGen,
 		startRow:       1,
@@ -22,7 +25,9 @@ type rawCellContent struct {
 }
 
 type rawCellWhole struct {
-	contents       [][]string
+	contents [][]string
+	// the original row number of each content
+	rows           []int
 	cellContents   [][]rawCellContent
 	root           *TreeNode
 	totalNodeCount int
@@ -59,6 +64,10 @@ type TreeNode struct {
 	children []*TreeNode
 	extra    *TreeNodeExtra
 	whole    *rawCellWhole
+	// the node exists in the tree merged into
+	existing bool
+	// the model of the level columns
+	levelModel any
 }
 
 type TreeNodeExtra struct {
@@ -67,7 +76,10 @@ type TreeNodeExtra struct {
 
 type TreeNodeItem struct {
 	item any
-	row  int
+	// the original row number
+	row int
+	// the index of the parsed contents
+	index int
 }
 
 func (t *TreeNode) GetKey() string {
@@ -131,19 +143,47 @@ func (t *TreeNode) CheckIsRoot() bool {
 }
 
 func (t *TreeNode) GetID() int64 {
-	return t.id
+	return atomic.LoadInt64(&t.id)
 }
 
 // SetID set the id of the tree node.
-// should be called after import the tree node
+// should be called after import the tree node, safe under the parallel import
 func (t *TreeNode) SetID(id int64) {
-	t.id = id
+	atomic.StoreInt64(&t.id, id)
+}
+
+// GetLevelModel get the level model of the node, which is filled by the columns of the level.
+// nil if the level has no model factory
+func (t *TreeNode) GetLevelModel() any {
+	return t.levelModel
+}
+
+// IsExisting check if the node exists in the tree merged into, the existing node has the existing id.
+// the existing nodes aren't passed to the level importers, but their new children are.
+func (t *TreeNode) IsExisting() bool {
+	return t.existing
 }
 
 func (t *TreeNode) GetRank() int {
 	return t.rank
 }
 
+// NewRootNode create the virtual root of the tree, for the tree builder
+func NewRootNode() *TreeNode {
+	return constructLevelNode("", nil, 0)
+}
+
+// AddChild add the child node of the value, the rank of the child is next to the node
+func (t *TreeNode) AddChild(value string) *TreeNode {
+	return constructLevelNode(value, t, t.rank+1)
+}
+
+// AttachContent attach the content of the index to the node, the model and the row of the content are filled later.
+// the index is the index of the parsed contents, not the row of the sheet
+func (t *TreeNode) AttachContent(index int) {
+	t.extra.items = append(t.extra.items, &TreeNodeItem{index: index})
+}
+
 func constructLevelNode(s string, parent *TreeNode, level int) *TreeNode {
 	node := &TreeNode{
 		value:  s,
@@ -161,6 +201,12 @@ func constructLevelNode(s string, parent *TreeNode, level int) *TreeNode {
 	return node
 }
 
+// nodeConflict the conflict of the node keys, the indexes are the indexes of the parsed contents
+type nodeConflict struct {
+	indexes []int
+	err     error
+}
+
 type TreeImportCfg struct {
 	// the tree level order of the tree node
 	LevelOrder []int
@@ -170,6 +216,8 @@ type TreeImportCfg struct {
 	ColumnCount int
 	// the rawModel factory
 	ModelFac excel_import.RowModelFactory
+	// the levels which span several columns, the level order and the tree boundary are derived from them if set
+	Levels []*TreeLevel
 }
 
 type treeImportOptionalCfg struct {
@@ -186,19 +234,55 @@ type treeImportOptionalCfg struct {
 	rowFilterFunc excel_import.RowFilter
 	// enable format checker
 	enableFormatChecker bool
+	// the retry policy of the transient db errors in the level import
+	retryPolicy *util.RetryPolicy
+	// the write rate limiter of the level import, the node counts as one row and one statement
+	rateLimiter *util.RateLimiter
+	// only parse and check the content
+	dryRun bool
+	// the end func is set by WithEndFunc
+	customEndFunc bool
+	// fill the blank ancestor cells with the value above
+	fillDown bool
+	// read the merged ranges with their top-left values
+	mergedCells bool
+	// the max parallel of the nodes in one level, the levels are imported in sequence. serial if not greater than 1
+	maxParallel int
+	// build the tree from the other layout instead of the level columns
+	treeBuilder TreeBuilder
+	// the existing tree which the file is merged into
+	mergeSource ExistingTreeSource
+	// how to handle the existing nodes missing from the file
+	missingPolicy MissingNodePolicy
+	// the validations of the tree structure
+	validation *TreeValidation
+}
+
+// PathNodeKey key the node by the full path from the first level, the default key.
+// every value is quoted, so the key is unambiguous whatever the values contain.
+func PathNodeKey(s []string, level int) string {
+	quoted := make([]string, len(s))
+	for i, x := range s {
+		quoted[i] = strconv.Quote(x)
+	}
+
+	return strings.Join(quoted, "/")
 }
 
-func genNodeKey(s []string, level int) string {
+// ValueNodeKey key the node by the value and the level, the nodes with the same value in one level are the same node.
+// only for the trees whose values are unique in every level.
+func ValueNodeKey(s []string, level int) string {
 	return fmt.Sprintf("%s_%d", s[len(s)-1], level)
 }
 
-func genPrefixNodeKey(s []string, level int) string {
-	var key string
-	for _, x := range s {
-		key += x + "_"
+// nodePath the values of the node from the first level
+func nodePath(node *TreeNode) []string {
+	path := make([]string, node.rank)
+	for n := node; n != nil && !n.CheckIsRoot(); n = n.parent {
+		path[n.rank-1] = n.value
 	}
-	key += fmt.Sprintf("%d", level)
-	return key
+
+	return path
 }
 
 func defaultTreeColEndFunc(next string) bool {
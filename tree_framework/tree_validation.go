@@ -0,0 +1,144 @@
+package tree_framework
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrMaxDepth       = errors.New("the tree is too deep")
+	ErrMinLeafDepth   = errors.New("the leaf is too shallow")
+	ErrMaxChildren    = errors.New("too many children")
+	ErrSkippedLevel   = errors.New("the level is skipped")
+	ErrParentConflict = errors.New("the value is under different parents")
+)
+
+// TreeValidation the validations of the tree structure in the check phase.
+// the violations are recorded as the check errors with the original rows, the zero values are disabled.
+type TreeValidation struct {
+	// the max depth of the nodes, the first level is 1
+	MaxDepth int
+	// the min depth of the leaves
+	MinLeafDepth int
+	// the max children of one node, the first level counts as the children of the root
+	MaxChildren int
+	// the siblings of the same value, ignoring the case and the spaces, e.g. "Apple" and "apple"
+	DuplicateSiblings bool
+	// the row of the column layout whose level cell is blank but the deeper cells are not,
+	// which fails the parse if not checked
+	SkippedLevels bool
+	// the same value under the different parents in one level, for the trees whose values are unique in every level
+	ParentConflicts bool
+}
+
+// WithTreeValidation validate the tree structure in the check phase
+func WithTreeValidation(validation *TreeValidation) OptionFunc {
+	return func(framework *TreeImportFramework) {
+		framework.ocfg.validation = validation
+	}
+}
+
+// checkSkippedLevel check if the level of the row is skipped, the row is reported once
+func (t *TreeImportFramework) checkSkippedLevel(path []string, index int) bool {
+	if t.ocfg.validation == nil || !t.ocfg.validation.SkippedLevels {
+		return false
+	}
+
+	for level, value := range path[:len(path)-1] {
+		if !t.ocfg.treeColEndFunc(value) {
+			continue
+		}
+
+		if t.skippedRows == nil {
+			t.skippedRows = make(map[int]bool)
+		}
+		if !t.skippedRows[index] {
+			t.skippedRows[index] = true
+			t.conflicts = append(t.conflicts, &nodeConflict{
+				indexes: []int{index},
+				err:     fmt.Errorf("%w: level %d is blank before %s", ErrSkippedLevel, level+1, path[len(path)-1]),
+			})
+		}
+		return true
+	}
+
+	return false
+}
+
+// validateTree validate the structure of the tree, the violations are added into the conflicts
+func (t *TreeImportFramework) validateTree(root *TreeNode) {
+	v := t.ocfg.validation
+	if v == nil {
+		return
+	}
+
+	// the first node of the value in every level
+	levelValues := make(map[int]map[string]*TreeNode)
+	var walk func(node *TreeNode)
+	walk = func(node *TreeNode) {
+		if v.MaxDepth > 0 && node.rank > v.MaxDepth {
+			t.addNodeConflict(fmt.Errorf("%w: %s is at level %d, the max is %d", ErrMaxDepth,
+				strings.Join(nodePath(node), "/"), node.rank, v.MaxDepth), node)
+			return
+		}
+		if v.MinLeafDepth > 0 && !node.CheckIsRoot() && node.CheckIsLeaf() && node.rank < v.MinLeafDepth {
+			t.addNodeConflict(fmt.Errorf("%w: %s is at level %d, the min is %d", ErrMinLeafDepth,
+				strings.Join(nodePath(node), "/"), node.rank, v.MinLeafDepth), node)
+		}
+		if v.MaxChildren > 0 && len(node.children) > v.MaxChildren {
+			t.addNodeConflict(fmt.Errorf("%w: %s has %d children, the max is %d", ErrMaxChildren,
+				strings.Join(append([]string{"root"}, nodePath(node)...), "/"), len(node.children), v.MaxChildren),
+				node.children[v.MaxChildren:]...)
+		}
+		if v.DuplicateSiblings && t.ocfg.treeBuilder != nil {
+			t.checkBuiltSiblings(node)
+		}
+		if v.ParentConflicts && !node.CheckIsRoot() {
+			values := levelValues[node.rank]
+			if values == nil {
+				values = make(map[string]*TreeNode)
+				levelValues[node.rank] = values
+			}
+			if first, ok := values[node.value]; !ok {
+				values[node.value] = node
+			} else if first.parent != node.parent {
+				t.addNodeConflict(fmt.Errorf("%w: %s and %s", ErrParentConflict,
+					strings.Join(nodePath(first), "/"), strings.Join(nodePath(node), "/")), first, node)
+			}
+		}
+
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(root)
+}
+
+// checkBuiltSiblings check the duplicate siblings of the tree built by the tree builder
+func (t *TreeImportFramework) checkBuiltSiblings(node *TreeNode) {
+	seen := make(map[string]*TreeNode, len(node.children))
+	for _, child := range node.children {
+		normalized := normalizeValue(child.value)
+		sibling, ok := seen[normalized]
+		if !ok {
+			seen[normalized] = child
+			continue
+		}
+
+		t.addNodeConflict(fmt.Errorf("%w: %q and %q under %s", ErrDuplicateSibling, sibling.value, child.value,
+			strings.Join(append([]string{"root"}, nodePath(node)...), "/")), sibling, child)
+	}
+}
+
+// addNodeConflict add the conflict of the nodes, reported with the first row of every node
+func (t *TreeImportFramework) addNodeConflict(err error, nodes ...*TreeNode) {
+	indexes := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		if len(node.extra.items) > 0 {
+			indexes = append(indexes, node.extra.items[0].index)
+		}
+	}
+
+	t.conflicts = append(t.conflicts, &nodeConflict{indexes: indexes, err: err})
+}
@@ -0,0 +1,186 @@
+package tree_framework
+
+import (
+	"encoding/json"
+)
+
+type WalkMode int
+
+const (
+	// WalkDFS walk the tree in the depth first order
+	WalkDFS WalkMode = iota
+	// WalkBFS walk the tree level by level
+	WalkBFS
+)
+
+// WalkFunc visit the node, return false to stop the walk
+type WalkFunc func(node *TreeNode) bool
+
+// Walk walk the subtree of the node, including the node itself. pre and post are optional.
+// under the DFS, pre is called before the children and post is called after the children.
+// under the BFS, pre is called level by level, and post is called in the reverse order after all the pre,
+// so the children are always posted before their parents.
+// return false if the walk is stopped by the func.
+func (t *TreeNode) Walk(mode WalkMode, pre, post WalkFunc) bool {
+	if mode == WalkBFS {
+		return t.walkBFS(pre, post)
+	}
+
+	return t.walkDFS(pre, post)
+}
+
+func (t *TreeNode) walkDFS(pre, post WalkFunc) bool {
+	if pre != nil && !pre(t) {
+		return false
+	}
+	for _, child := range t.children {
+		if !child.walkDFS(pre, post) {
+			return false
+		}
+	}
+	if post != nil && !post(t) {
+		return false
+	}
+
+	return true
+}
+
+func (t *TreeNode) walkBFS(pre, post WalkFunc) bool {
+	visited := make([]*TreeNode, 0)
+	for queue := []*TreeNode{t}; len(queue) > 0; queue = queue[1:] {
+		node := queue[0]
+		if pre != nil && !pre(node) {
+			return false
+		}
+		visited = append(visited, node)
+		queue = append(queue, node.children...)
+	}
+
+	if post == nil {
+		return true
+	}
+	for i := len(visited) - 1; i >= 0; i-- {
+		if !post(visited[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Path return the nodes from the first level to the node, empty for the root
+func (t *TreeNode) Path() []*TreeNode {
+	path := make([]*TreeNode, t.rank)
+	for n := t; n != nil && !n.CheckIsRoot(); n = n.parent {
+		path[n.rank-1] = n
+	}
+
+	return path
+}
+
+// PathValues return the values from the first level to the node, empty for the root
+func (t *TreeNode) PathValues() []string {
+	return nodePath(t)
+}
+
+// FindByPath find the descendant by the values of the levels under the node, nil if not found.
+// e.g. root.FindByPath("食品", "水果") for the node 水果 under 食品
+func (t *TreeNode) FindByPath(values ...string) *TreeNode {
+	node := t
+	for _, value := range values {
+		var next *TreeNode
+		for _, child := range node.children {
+			if child.value == value {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+
+	return node
+}
+
+// Depth return the levels under the node, 0 for the leaf. the depth of the root is the depth of the tree
+func (t *TreeNode) Depth() int {
+	depth := 0
+	for _, child := range t.children {
+		depth = max(depth, child.Depth()+1)
+	}
+
+	return depth
+}
+
+// Leaves return the leaves of the subtree in the depth first order, the leaf returns itself
+func (t *TreeNode) Leaves() []*TreeNode {
+	leaves := make([]*TreeNode, 0)
+	t.Walk(WalkDFS, func(node *TreeNode) bool {
+		if node.CheckIsLeaf() {
+			leaves = append(leaves, node)
+		}
+		return true
+	}, nil)
+
+	return leaves
+}
+
+// CountNodes return the node count of the subtree, including the node itself
+func (t *TreeNode) CountNodes() int {
+	count := 0
+	t.Walk(WalkDFS, func(node *TreeNode) bool {
+		count++
+		return true
+	}, nil)
+
+	return count
+}
+
+// CountLeaves return the leaf count of the subtree
+func (t *TreeNode) CountLeaves() int {
+	return len(t.Leaves())
+}
+
+// treeNodeView the serialized tree node, the parent is omitted
+type treeNodeView struct {
+	ID       int64  `json:"id,omitempty" yaml:"id,omitempty"`
+	Value    string `json:"value" yaml:"value"`
+	Rank     int    `json:"rank" yaml:"rank"`
+	Existing bool   `json:"existing,omitempty" yaml:"existing,omitempty"`
+	// the rows of the sheet, start from 0 as the rows of the recorded errors
+	Rows       []int       `json:"rows,omitempty" yaml:"rows,omitempty"`
+	Items      []any       `json:"items,omitempty" yaml:"items,omitempty"`
+	LevelModel any         `json:"level_model,omitempty" yaml:"level_model,omitempty"`
+	Children   []*TreeNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+func (t *TreeNode) view() *treeNodeView {
+	view := &treeNodeView{
+		ID:         t.GetID(),
+		Value:      t.value,
+		Rank:       t.rank,
+		Existing:   t.existing,
+		LevelModel: t.levelModel,
+		Children:   t.children,
+	}
+	for _, item := range t.extra.items {
+		view.Rows = append(view.Rows, item.row)
+		if item.item != nil {
+			view.Items = append(view.Items, item.item)
+		}
+	}
+
+	return view
+}
+
+// MarshalJSON marshal the subtree with the items and the 0-based rows, for the debugging and the api responses
+func (t *TreeNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.view())
+}
+
+// MarshalYAML marshal the subtree the same as MarshalJSON
+func (t *TreeNode) MarshalYAML() (any, error) {
+	return t.view(), nil
+}
@@ -0,0 +1,114 @@
+package tree_framework
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newWalkTestTree 食品/水果/苹果, 食品/水果/香蕉, 食品/蔬菜, 日用
+func newWalkTestTree() *TreeNode {
+	root := NewRootNode()
+	food := root.AddChild("食品")
+	fruit := food.AddChild("水果")
+	fruit.AddChild("苹果").AttachContent(0)
+	fruit.AddChild("香蕉").AttachContent(1)
+	food.AddChild("蔬菜").AttachContent(2)
+	root.AddChild("日用").AttachContent(3)
+
+	return root
+}
+
+func walkValues(root *TreeNode, mode WalkMode, stopAt string) (pres, posts []string) {
+	root.Walk(mode, func(node *TreeNode) bool {
+		pres = append(pres, node.GetValue())
+		return node.GetValue() != stopAt
+	}, func(node *TreeNode) bool {
+		posts = append(posts, node.GetValue())
+		return true
+	})
+
+	return pres, posts
+}
+
+func TestTreeNode_Walk(t *testing.T) {
+	root := newWalkTestTree()
+
+	pres, posts := walkValues(root, WalkDFS, "-")
+	if expected := []string{"", "食品", "水果", "苹果", "香蕉", "蔬菜", "日用"}; !reflect.DeepEqual(pres, expected) {
+		t.Fatalf("dfs pre order is %v, expected %v", pres, expected)
+	}
+	if expected := []string{"苹果", "香蕉", "水果", "蔬菜", "食品", "日用", ""}; !reflect.DeepEqual(posts, expected) {
+		t.Fatalf("dfs post order is %v, expected %v", posts, expected)
+	}
+
+	pres, posts = walkValues(root, WalkBFS, "-")
+	if expected := []string{"", "食品", "日用", "水果", "蔬菜", "苹果", "香蕉"}; !reflect.DeepEqual(pres, expected) {
+		t.Fatalf("bfs pre order is %v, expected %v", pres, expected)
+	}
+	if expected := []string{"香蕉", "苹果", "蔬菜", "水果", "日用", "食品", ""}; !reflect.DeepEqual(posts, expected) {
+		t.Fatalf("bfs post order is %v, expected %v", posts, expected)
+	}
+
+	// the walk stops at the node
+	pres, posts = walkValues(root, WalkDFS, "水果")
+	if expected := []string{"", "食品", "水果"}; !reflect.DeepEqual(pres, expected) || len(posts) != 0 {
+		t.Fatalf("stopped walk visits %v and %v", pres, posts)
+	}
+}
+
+func TestTreeNode_Query(t *testing.T) {
+	root := newWalkTestTree()
+
+	banana := root.FindByPath("食品", "水果", "香蕉")
+	if banana == nil || !reflect.DeepEqual(banana.PathValues(), []string{"食品", "水果", "香蕉"}) {
+		t.Fatalf("unexpected banana %v", banana)
+	}
+	if path := banana.Path(); len(path) != 3 || path[0] != root.FindByPath("食品") || path[2] != banana {
+		t.Fatalf("unexpected path %v", path)
+	}
+	if root.FindByPath("食品", "肉类") != nil || len(root.Path()) != 0 {
+		t.Fatal("unexpected node of the missing path")
+	}
+
+	if root.Depth() != 3 || root.FindByPath("食品").Depth() != 2 || banana.Depth() != 0 {
+		t.Fatalf("unexpected depths %d, %d, %d", root.Depth(), root.FindByPath("食品").Depth(), banana.Depth())
+	}
+
+	leaves := make([]string, 0)
+	for _, leaf := range root.Leaves() {
+		leaves = append(leaves, leaf.GetValue())
+	}
+	if expected := []string{"苹果", "香蕉", "蔬菜", "日用"}; !reflect.DeepEqual(leaves, expected) {
+		t.Fatalf("leaves are %v, expected %v", leaves, expected)
+	}
+	if root.CountNodes() != 7 || root.CountLeaves() != 4 || root.FindByPath("食品").CountNodes() != 5 {
+		t.Fatalf("unexpected counts %d, %d", root.CountNodes(), root.CountLeaves())
+	}
+}
+
+func TestTreeNode_Marshal(t *testing.T) {
+	root := newWalkTestTree()
+	root.FindByPath("日用").SetID(4)
+	root.FindByPath("日用").extra.items[0].item = &twoLevelTreeModel{L1: "日用"}
+	root.FindByPath("日用").extra.items[0].row = 3
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `{"id":4,"value":"日用","rank":1,"rows":[3],"items":[{"L1":"日用","L2":""}]}`) {
+		t.Fatalf("unexpected json %s", data)
+	}
+
+	data, err = yaml.Marshal(root.FindByPath("食品", "水果"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "value: 水果\nrank: 2\nchildren:\n    - value: 苹果\n      rank: 3\n      rows:\n        - 0\n"
+	if !strings.HasPrefix(string(data), expected) {
+		t.Fatalf("unexpected yaml:\n%s", data)
+	}
+}
@@ -246,6 +246,37 @@ func TestCheckIsHash(t *testing.T) {
 	}
 }
 
+func TestCheckIsEnum(t *testing.T) {
+	values := []string{"A", "B", "C"}
+	tests := []struct {
+		str      string
+		expected bool
+	}{
+		{
+			str:      "A",
+			expected: true,
+		},
+		{
+			str:      "C",
+			expected: true,
+		},
+		{
+			str:      "a",
+			expected: false,
+		},
+		{
+			str:      "D",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		if !checkAsExpected(CheckIsEnum(test.str, values), test.expected) {
+			t.Fatalf("str %s expected %v, got %v", test.str, test.expected, CheckIsEnum(test.str, values))
+		}
+	}
+}
+
 func checkAsExpected(err error, expected bool) bool {
 	return (err == nil) == expected
 }
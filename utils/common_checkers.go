@@ -24,6 +24,7 @@ var (
 	ErrInvalidFloat    = errors.New("invalid float")
 	ErrInvalidChinese  = errors.New("invalid Chinese")
 	ErrInvalidEnglish  = errors.New("invalid English")
+	ErrInvalidEnum     = errors.New("invalid enum value")
 )
 
 // CheckIsUrl checks if the given string is a URL.
@@ -114,3 +115,14 @@ func CheckIsFloat(str string) error {
 	_, err := strconv.ParseFloat(str, 64)
 	return err
 }
+
+// CheckIsEnum checks if the given string is one of the allowed values.
+func CheckIsEnum(str string, values []string) error {
+	for _, v := range values {
+		if str == v {
+			return nil
+		}
+	}
+
+	return ErrInvalidEnum
+}
@@ -37,6 +37,16 @@ func (eb *ErrBuilder) AddWithContent(s string, err error) {
 	eb.errs = append(eb.errs, errors.New(fmt.Sprintf("\t内容 %s 错误: %s", s, err.Error())))
 }
 
+// AddCellWithContent add the error of the cell at column col.
+// the column is kept, so that the error can be located to the cell.
+func (eb *ErrBuilder) AddCellWithContent(col int, s string, err error) {
+	if err == nil {
+		return
+	}
+
+	eb.errs = append(eb.errs, &CellError{Col: col, Content: s, Err: err})
+}
+
 func (eb *ErrBuilder) AddHeader(header string) {
 	eb.header = header
 }
@@ -58,18 +68,106 @@ func (eb *ErrBuilder) Build() error {
 	// remove last "; \n"
 	errStr = errStr[:len(errStr)-len(errLineSep)]
 
-	return errors.New(errStr)
+	return &joinedError{msg: errStr, errs: eb.errs}
+}
+
+// joinedError the error built by ErrBuilder, keep the added errors
+type joinedError struct {
+	msg  string
+	errs []error
+}
+
+func (e *joinedError) Error() string {
+	return e.msg
+}
+
+func (e *joinedError) Unwrap() []error {
+	return e.errs
+}
+
+// CellError the error of the cell at column Col
+type CellError struct {
+	// the column index of the cell
+	Col int
+	// the content of the cell
+	Content string
+	// the error of the cell
+	Err error
+}
+
+func (c *CellError) Error() string {
+	return fmt.Sprintf("\t内容 %s 错误: %s", c.Content, c.Err.Error())
+}
+
+func (c *CellError) Unwrap() error {
+	return c.Err
+}
+
+// RowError the error of the rows, keep the original row numbers and the errors of the rows
+type RowError struct {
+	// the original row numbers, start from 0
+	Rows []int
+	msg  string
+	errs []error
+}
+
+func (r *RowError) Error() string {
+	return r.msg
+}
+
+func (r *RowError) Unwrap() []error {
+	return r.errs
+}
+
+// Errors return the errors of the rows
+func (r *RowError) Errors() []error {
+	return r.errs
+}
+
+// CellErrors return the cell errors of the rows
+func (r *RowError) CellErrors() []*CellError {
+	var cellErrs []*CellError
+	for _, err := range r.errs {
+		cellErrs = append(cellErrs, collectCellErrors(err)...)
+	}
+
+	return cellErrs
+}
+
+// collectCellErrors collect the cell errors in the error tree
+func collectCellErrors(err error) []*CellError {
+	if err == nil {
+		return nil
+	}
+
+	if cellErr, ok := err.(*CellError); ok {
+		return []*CellError{cellErr}
+	}
+
+	var cellErrs []*CellError
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, e := range x.Unwrap() {
+			cellErrs = append(cellErrs, collectCellErrors(e)...)
+		}
+	case interface{ Unwrap() error }:
+		cellErrs = append(cellErrs, collectCellErrors(x.Unwrap())...)
+	}
+
+	return cellErrs
 }
 
 func CombineErrors(row int, errs ...error) error {
 	errStr := fmt.Sprintf("第%d行数据错误 \n", row+1)
+	rowErrs := make([]error, 0, len(errs))
 	for _, err := range errs {
 		if err == nil {
 			continue
 		}
 		errStr += err.Error() + "\n"
+		rowErrs = append(rowErrs, err)
 	}
-	return errors.New(errStr)
+	return &RowError{Rows: []int{row}, msg: errStr, errs: rowErrs}
 }
 
 func CombineRowsErrors(rows []int, errs ...error) error {
@@ -84,12 +182,14 @@ func CombineRowsErrors(rows []int, errs ...error) error {
 	}
 	errStr = errStr[:len(errStr)-len(rowErrSep)] + "行数据错误 \n"
 
+	rowErrs := make([]error, 0, len(errs))
 	for _, err := range errs {
 		if err == nil {
 			continue
 		}
 		errStr += err.Error() + "\n"
+		rowErrs = append(rowErrs, err)
 	}
 
-	return errors.New(errStr)
+	return &RowError{Rows: rows, msg: errStr, errs: rowErrs}
 }
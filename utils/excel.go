@@ -36,12 +36,72 @@ func WriteExcelColumnContentByStartRow(path string, content map[int][]string, st
 	}
 }
 
+// WriteExcelCellContent 按行号写入Excel单元格内容，支持CSV和XLSX格式
+// content 的键为列索引，值为行号(从0开始，包含表头)到单元格内容的映射
+func WriteExcelCellContent(path string, content map[int]map[int]string) error {
+	return WriteExcelCellContentTo(path, path, content)
+}
+
+// WriteExcelCellContentTo 读取src文件，按行号写入单元格内容后保存到dst，支持CSV和XLSX格式
+// src与dst的文件类型必须一致，dst与src相同时覆盖源文件
+func WriteExcelCellContentTo(src, dst string, content map[int]map[int]string) error {
+	ext := strings.ToLower(filepath.Ext(src))
+	if dstExt := strings.ToLower(filepath.Ext(dst)); dstExt != ext {
+		return fmt.Errorf("output file type %s not match source file type %s", dstExt, ext)
+	}
+
+	switch ext {
+	case ".csv":
+		return writeCSVCells(src, dst, content)
+	case ".xlsx":
+		return writeXLSXCells(src, dst, content)
+	default:
+		return fmt.Errorf("unsupported file type: %s", ext)
+	}
+}
+
+// columnContentToCells translate the column content which start from startRow into the row keyed cells
+func columnContentToCells(content map[int][]string, startRow int) map[int]map[int]string {
+	cells := make(map[int]map[int]string, len(content))
+	for i, col := range content {
+		cells[i] = make(map[int]string, len(col))
+		for j, cell := range col {
+			cells[i][j+startRow] = cell
+		}
+	}
+
+	return cells
+}
+
 func writeCSV(path string, content map[int][]string) error {
-	return nil
+	return writeCSVByStartRow(path, content, defaultStartRow)
 }
 
 func writeCSVByStartRow(path string, content map[int][]string, startRow int) error {
-	return nil
+	return writeCSVCells(path, path, columnContentToCells(content, startRow))
+}
+
+func writeCSVCells(src, dst string, content map[int]map[int]string) error {
+	records, err := readCSV(src)
+	if err != nil {
+		return err
+	}
+
+	for i, col := range content {
+		for row, cell := range col {
+			// complete the missing rows and columns
+			for len(records) <= row {
+				records = append(records, []string{})
+			}
+			if len(records[row]) <= i {
+				records[row] = append(records[row], make([]string, i+1-len(records[row]))...)
+			}
+
+			records[row][i] = cell
+		}
+	}
+
+	return writeCSVContent(dst, records)
 }
 
 func writeXLSX(path string, content map[int][]string) error {
@@ -73,6 +133,30 @@ func writeXLSXByStartRow(path string, content map[int][]string, startRow int) er
 	return f.Save(path)
 }
 
+func writeXLSXCells(src, dst string, content map[int]map[int]string) error {
+	f, err := xlsx.OpenFile(src)
+	if err != nil {
+		return err
+	}
+
+	if len(f.Sheets) == 0 {
+		_, err = f.AddSheet("Sheet1")
+		if err != nil {
+			return err
+		}
+	}
+
+	sheet := f.Sheets[0]
+
+	for i, col := range content {
+		for row, cell := range col {
+			sheet.Cell(row, i).SetString(cell)
+		}
+	}
+
+	return f.Save(dst)
+}
+
 // ReadExcelValidContentInCommonCase read excel content in common case, support CSV and XLSX format
 // the common case is that the end row is the row that all cells are empty or the first cell is empty, and skip the header
 func ReadExcelValidContentInCommonCase(path string) ([][]string, error) {
@@ -140,18 +224,112 @@ func readXLSX(path string) ([][]string, error) {
 
 	var records [][]string
 	for _, sheet := range xlFile.Sheets {
-		for _, row := range sheet.Rows {
-			var record []string
-			for _, cell := range row.Cells {
-				text := cell.String()
-				record = append(record, text)
+		records = append(records, readXLSXSheet(sheet)...)
+	}
+	return records, nil
+}
+
+// ReadExcelMergedContent read excel content from file, the cells of the merged range are filled with its top-left value.
+// the CSV format has no merged range, and is read the same as ReadExcelContent.
+func ReadExcelMergedContent(path string) ([][]string, error) {
+	if strings.ToLower(filepath.Ext(path)) != ".xlsx" {
+		return ReadExcelContent(path)
+	}
+
+	xlFile, err := xlsx.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records [][]string
+	for _, sheet := range xlFile.Sheets {
+		sheetRecords := readXLSXSheet(sheet)
+		mergeCells, err := f.GetMergeCells(sheet.Name)
+		if err != nil {
+			return nil, err
+		}
+		if sheetRecords, err = fillMergedCells(sheetRecords, mergeCells); err != nil {
+			return nil, err
+		}
+		records = append(records, sheetRecords...)
+	}
+
+	return records, nil
+}
+
+// fillMergedCells fill the cells of the merged ranges with the top-left value, the rows are extended if necessary
+func fillMergedCells(records [][]string, mergeCells []excelize.MergeCell) ([][]string, error) {
+	for _, mergeCell := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+
+		// the coordinates start from 1
+		var value string
+		if startRow <= len(records) && startCol <= len(records[startRow-1]) {
+			value = records[startRow-1][startCol-1]
+		}
+		for len(records) < endRow {
+			records = append(records, nil)
+		}
+		for i := startRow - 1; i < endRow; i++ {
+			if len(records[i]) < endCol {
+				records[i] = append(records[i], make([]string, endCol-len(records[i]))...)
+			}
+			for j := startCol - 1; j < endCol; j++ {
+				records[i][j] = value
 			}
-			records = append(records, record)
 		}
 	}
+
 	return records, nil
 }
 
+// ReadExcelSheetContent read the content of the sheet, the whole content is read if the sheet is empty.
+// the sheet is ignored for CSV format.
+func ReadExcelSheetContent(path, sheet string) ([][]string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if len(sheet) == 0 || ext != ".xlsx" {
+		return ReadExcelContent(path)
+	}
+
+	xlFile, err := xlsx.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	xlSheet, ok := xlFile.Sheet[sheet]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s not found", sheet)
+	}
+
+	return readXLSXSheet(xlSheet), nil
+}
+
+func readXLSXSheet(sheet *xlsx.Sheet) [][]string {
+	records := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var record []string
+		for _, cell := range row.Cells {
+			text := cell.String()
+			record = append(record, text)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
 // DivideSheetsIntoTables 将Excel文件中的每个Sheet拆分为单独的文件，并返回拆分后的文件绝对路径
 func DivideSheetsIntoTables(path string) ([]string, error) {
 	return DivideSheetsIntoTablesBySuffixKey(path, "")
@@ -298,6 +476,7 @@ func writeCSVContent(path string, content [][]string) error {
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
 	writer := csv.NewWriter(file)
 	for _, record := range content {
@@ -307,7 +486,7 @@ func writeCSVContent(path string, content [][]string) error {
 	}
 
 	writer.Flush()
-	return nil
+	return writer.Error()
 }
 
 func writeXLSXContent(path string, content [][]string) error {
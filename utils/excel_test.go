@@ -2,6 +2,8 @@ package util
 
 import (
 	"fmt"
+	"github.com/xuri/excelize/v2"
+	"path/filepath"
 	"testing"
 )
 
@@ -102,3 +104,78 @@ func TestDivideSheetsIntoTablesBySuffixKey(t *testing.T) {
 		fmt.Println(p)
 	}
 }
+
+func TestWriteExcelCellContentToCSV(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cell_content.csv")
+	dst := filepath.Join(dir, "cell_content_output.csv")
+	if err := WriteExcelContent(src, [][]string{{"a", "b"}, {"1", "2"}, {"3", "4"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	content := map[int]map[int]string{
+		2: {1: "x", 2: "y"},
+		0: {3: "z"},
+	}
+	if err := WriteExcelCellContentTo(src, dst, content); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadExcelContent(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"a", "b", ""}, {"1", "2", "x"}, {"3", "4", "y"}, {"z", "", ""}}
+	for i, row := range expected {
+		for j, cell := range row {
+			if j >= len(records[i]) {
+				if cell != "" {
+					t.Fatalf("cell (%d, %d) is missing, expected %s", i, j, cell)
+				}
+				continue
+			}
+			if records[i][j] != cell {
+				t.Fatalf("cell (%d, %d) is %s, expected %s", i, j, records[i][j], cell)
+			}
+		}
+	}
+}
+
+func TestReadExcelMergedContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merged.xlsx")
+	f := excelize.NewFile()
+	cells := map[string]string{"A1": "L1", "B1": "L2", "A2": "a", "B2": "b", "B3": "c", "A4": "d", "B4": "e"}
+	for cell, value := range cells {
+		if err := f.SetCellStr("Sheet1", cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the merged range beyond the written cells extends the rows
+	for _, r := range [][2]string{{"A2", "A3"}, {"A4", "A5"}} {
+		if err := f.MergeCell("Sheet1", r[0], r[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	records, err := ReadExcelMergedContent(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"L1", "L2"}, {"a", "b"}, {"a", "c"}, {"d", "e"}, {"d"}}
+	if len(records) != len(expected) {
+		t.Fatalf("records is %v, expected %v", records, expected)
+	}
+	for i, row := range expected {
+		for j, cell := range row {
+			if records[i][j] != cell {
+				t.Fatalf("records is %v, expected %v", records, expected)
+			}
+		}
+	}
+}
@@ -1,6 +1,24 @@
 package util
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayout the layout of the time cell
+const DefaultTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	// the layouts tried in order when parse the time cell
+	timeLayouts = []string{
+		DefaultTimeLayout,
+		"2006-01-02",
+		time.RFC3339,
+		"2006/01/02 15:04:05",
+		"2006/01/02",
+	}
+)
 
 func FormatCell(cell string) string {
 	return strings.TrimSpace(removeLargeUnicodeChars(cell))
@@ -45,3 +63,27 @@ func getMinColCount(contents [][]string) int {
 
 	return result
 }
+
+// FormatTime format the time cell, the zero time is formatted as empty string
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(DefaultTimeLayout)
+}
+
+// ParseTime parse the time cell by the supported layouts, the empty string is parsed as zero time
+func ParseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
+var timeType = reflect.TypeOf(time.Time{})
+
 func CheckModelOrder(model any, values []string) error {
 	fieldOrders := make([]int, len(values))
 	for i := 0; i < len(values); i++ {
@@ -156,6 +159,34 @@ func setField(v reflect.Value, i int, value string) error {
 		return errors.New("field is unexported")
 	}
 
+	return setFieldValue(field, value)
+}
+
+func setFieldValue(field reflect.Value, value string) error {
+	// the pointer field keeps nil if the value is empty
+	if field.Kind() == reflect.Ptr {
+		if len(value) == 0 {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+
+		elem := reflect.New(field.Type().Elem())
+		if err := setFieldValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.Type() == timeType {
+		t, err := ParseTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
 	switch field.Kind() {
 	case reflect.String:
 		field.SetString(value)
@@ -177,6 +208,16 @@ func setField(v reflect.Value, i int, value string) error {
 			return err
 		}
 		field.SetFloat(fieldValue)
+	case reflect.Bool:
+		if len(value) == 0 {
+			field.SetBool(false)
+			return nil
+		}
+		fieldValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(fieldValue)
 	default:
 		return errors.New("unsupported field type")
 	}
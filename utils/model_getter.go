@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -27,7 +29,22 @@ func GetFieldString(m any, i int) (string, error) {
 		return "", errors.New("field index out of range")
 	}
 
-	field := v.Field(i)
+	return getFieldValueString(v.Field(i))
+}
+
+// getFieldValueString get the string of the field value, the format can be parsed by setField
+func getFieldValueString(field reflect.Value) (string, error) {
+	// the nil pointer is regarded as empty
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+
+	if field.Type() == timeType {
+		return FormatTime(field.Interface().(time.Time)), nil
+	}
 
 	switch field.Kind() {
 	case reflect.String:
@@ -37,7 +54,9 @@ func GetFieldString(m any, i int) (string, error) {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return strconv.FormatUint(field.Uint(), 10), nil
 	case reflect.Float32, reflect.Float64:
-		return strconv.FormatFloat(field.Float(), 'g', -1, 64), nil
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
 	default:
 		return "", errors.New("unsupported field type")
 	}
@@ -113,3 +132,34 @@ func ParseStructInfo(m any) *excel_import.StructInfo {
 
 	return info
 }
+
+// GetModelID get the id of the model.
+// the id field is the field named ID or tagged as gorm primary key, 0 is returned if not found.
+func GetModelID(m any) int64 {
+	if m == nil {
+		return 0
+	}
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	v = v.Elem()
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.ToLower(t.Field(i).Tag.Get(gormTag))
+		if t.Field(i).Name != "ID" && !strings.Contains(tag, "primarykey") && !strings.Contains(tag, "primary_key") {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return field.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(field.Uint())
+		}
+	}
+
+	return 0
+}
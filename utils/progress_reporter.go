@@ -30,6 +30,7 @@ type ProgressReporter struct {
 type progressDetail struct {
 	success int
 	failed  int
+	retry   int
 }
 
 func NewProgressReporter(enable bool) *ProgressReporter {
@@ -76,6 +77,54 @@ func (p *ProgressReporter) CommitProgress(delta int, status ProgressStatus) {
 	}
 }
 
+// CommitRetry commit the retried attempts, the progress isn't changed
+func (p *ProgressReporter) CommitRetry(delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.detail.retry += delta
+}
+
+// GetRetry get the retried attempts
+func (p *ProgressReporter) GetRetry() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.detail.retry
+}
+
+// GetTotal get the total of the progress
+func (p *ProgressReporter) GetTotal() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.total
+}
+
+// GetProgress get the committed progress
+func (p *ProgressReporter) GetProgress() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.progress
+}
+
+// GetSuccess get the success count of the committed progress
+func (p *ProgressReporter) GetSuccess() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.detail.success
+}
+
+// GetFailed get the failed count of the committed progress
+func (p *ProgressReporter) GetFailed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.detail.failed
+}
+
 // IncreaseTotal increase total in dynamic mode
 func (p *ProgressReporter) IncreaseTotal(delta int) {
 	if p.mode != ProgressModeDynamic {
@@ -119,5 +168,5 @@ func (p *ProgressReporter) Report() {
 
 	totalCost := time.Since(p.startTime).Milliseconds()
 	averageCost := totalCost / int64(p.total)
-	fmt.Printf("Complete Progress: %d/%d, Cost: %v ms, Average Cost: %v ms, Success: %d, Failed: %d \n", p.progress, p.total, totalCost, averageCost, p.detail.success, p.detail.failed)
+	fmt.Printf("Complete Progress: %d/%d, Cost: %v ms, Average Cost: %v ms, Success: %d, Failed: %d, Retry: %d \n", p.progress, p.total, totalCost, averageCost, p.detail.success, p.detail.failed, p.detail.retry)
 }
@@ -0,0 +1,148 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+type RateUnit int
+
+const (
+	// RateUnitRows limit the imported rows per second
+	RateUnitRows RateUnit = iota
+	// RateUnitStatements limit the executed statements per second
+	RateUnitStatements
+)
+
+const (
+	defaultMinRateRatio   = 0.1
+	adaptiveDecreaseRatio = 0.5
+	adaptiveIncreaseRatio = 0.1
+)
+
+// RateLimiter limits the write rate of the import by the token bucket.
+// the bucket holds the tokens of one second at most, so the burst is the rate.
+// if adaptive throttling is enabled, the rate is halved when the latency is above the threshold,
+// and recovered slowly when the latency is below the threshold.
+// thread safe, the limiter can be shared by the frameworks to limit the total rate.
+type RateLimiter struct {
+	rate float64
+	unit RateUnit
+	// the adaptive throttling
+	latencyThreshold time.Duration
+	minRate          float64
+
+	curRate float64
+	tokens  float64
+	last    time.Time
+	mu      sync.Mutex
+}
+
+// NewRateLimiter create the limiter of the rate per second
+func NewRateLimiter(rate float64, unit RateUnit) *RateLimiter {
+	if rate <= 0 {
+		panic("rate should be greater than 0")
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		unit:    unit,
+		minRate: rate * defaultMinRateRatio,
+		curRate: rate,
+		tokens:  rate,
+		last:    time.Now(),
+	}
+}
+
+// EnableAdaptive enable the adaptive throttling.
+// the rate backs off when the latency is above the threshold, but never below the rate * minRatio.
+func (l *RateLimiter) EnableAdaptive(latencyThreshold time.Duration, minRatio float64) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if minRatio <= 0 || minRatio > 1 {
+		minRatio = defaultMinRateRatio
+	}
+
+	l.latencyThreshold = latencyThreshold
+	l.minRate = l.rate * minRatio
+	return l
+}
+
+// GetUnit get the unit of the rate
+func (l *RateLimiter) GetUnit() RateUnit {
+	return l.unit
+}
+
+// GetCurrentRate get the current rate which is throttled by the latency
+func (l *RateLimiter) GetCurrentRate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.curRate
+}
+
+// Wait block until n tokens are available.
+// the n larger than the burst is allowed, and the later waits pay for it.
+func (l *RateLimiter) Wait(n int) {
+	if n <= 0 {
+		return
+	}
+
+	if d := l.reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (l *RateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.curRate, l.tokens+now.Sub(l.last).Seconds()*l.curRate)
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.curRate * float64(time.Second))
+}
+
+// Observe observe the latency of the statement to adjust the rate
+func (l *RateLimiter) Observe(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.latencyThreshold <= 0 {
+		return
+	}
+
+	if latency > l.latencyThreshold {
+		l.curRate = max(l.curRate*adaptiveDecreaseRatio, l.minRate)
+	} else {
+		l.curRate = min(l.curRate+l.rate*adaptiveIncreaseRatio, l.rate)
+	}
+}
+
+// Run wait the tokens of the rows or the statements by the unit, then run fn and observe its latency.
+// the latency of fn is divided by the statements, so the batch of several statements is judged per statement.
+// the nil limiter runs fn directly.
+func (l *RateLimiter) Run(rows, statements int, fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+
+	n := rows
+	if l.unit == RateUnitStatements {
+		n = statements
+	}
+	l.Wait(n)
+
+	start := time.Now()
+	err := fn()
+	l.Observe(time.Since(start) / time.Duration(max(statements, 1)))
+
+	return err
+}
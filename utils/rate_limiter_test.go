@@ -0,0 +1,81 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(100, RateUnitRows)
+
+	// the burst is consumed at once, and the rest waits for the refill
+	start := time.Now()
+	for i := 0; i < 120; i++ {
+		limiter.Wait(1)
+	}
+	if cost := time.Since(start); cost < 150*time.Millisecond || cost > time.Second {
+		t.Fatalf("120 rows at 100 rows/sec cost %v", cost)
+	}
+}
+
+func TestRateLimiter_Run(t *testing.T) {
+	limiter := NewRateLimiter(100, RateUnitStatements)
+
+	// the statements are counted by the statements unit
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Run(1000, 50, func() error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cost := time.Since(start); cost < 400*time.Millisecond || cost > time.Second {
+		t.Fatalf("150 statements at 100 statements/sec cost %v", cost)
+	}
+
+	// the nil limiter runs directly
+	var nilLimiter *RateLimiter
+	var ran bool
+	if err := nilLimiter.Run(1, 1, func() error {
+		ran = true
+		return nil
+	}); err != nil || !ran {
+		t.Fatalf("nil limiter should run fn, err %v", err)
+	}
+}
+
+func TestRateLimiter_Adaptive(t *testing.T) {
+	limiter := NewRateLimiter(100, RateUnitRows).EnableAdaptive(10*time.Millisecond, 0.2)
+
+	// back off when the latency is high, but not below the min rate
+	limiter.Observe(20 * time.Millisecond)
+	if rate := limiter.GetCurrentRate(); rate != 50 {
+		t.Fatalf("rate %v, expected 50", rate)
+	}
+	limiter.Observe(20 * time.Millisecond)
+	limiter.Observe(20 * time.Millisecond)
+	if rate := limiter.GetCurrentRate(); rate != 20 {
+		t.Fatalf("rate %v, expected 20", rate)
+	}
+
+	// the batch of statements is judged by the latency per statement
+	batchLimiter := NewRateLimiter(1000, RateUnitStatements).EnableAdaptive(10*time.Millisecond, 0.2)
+	for i := 0; i < 3; i++ {
+		if err := batchLimiter.Run(10, 10, func() error {
+			time.Sleep(30 * time.Millisecond)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rate := batchLimiter.GetCurrentRate(); rate != 1000 {
+		t.Fatalf("the healthy batch backs off the rate to %v", rate)
+	}
+
+	// recover when the latency is low, but not above the rate
+	for i := 0; i < 20; i++ {
+		limiter.Observe(time.Millisecond)
+	}
+	if rate := limiter.GetCurrentRate(); rate != 100 {
+		t.Fatalf("rate %v, expected 100", rate)
+	}
+}
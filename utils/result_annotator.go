@@ -0,0 +1,284 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultResultHeader  = "导入结果"
+	defaultResultSuffix  = "_result.xlsx"
+	defaultCommentAuthor = "excel_import"
+	defaultFailedColor   = "FFC7CE"
+	defaultFailedFontClr = "9C0006"
+	resultSuccessText    = "成功"
+	resultSuccessWithID  = "成功, ID: %d"
+	resultCellErrSep     = "\n"
+)
+
+// ResultAnnotator annotates the import result of every row into the returned workbook.
+// the result is written into the "导入结果" column after the last column,
+// and the failed cells are highlighted with a comment which explains the error.
+// it observes the errors recorded by UnexpectedRecorder, so the errors are the same as the failure files.
+type ResultAnnotator struct {
+	path       string
+	outputPath string
+	headerRow  int
+	header     string
+	results    map[int]*rowResult
+	mu         sync.Mutex
+}
+
+type rowResult struct {
+	success bool
+	msg     string
+	errs    []string
+	cells   map[int][]string
+}
+
+// NewResultAnnotator create the annotator of the source file.
+// the annotated workbook is written to the path with "_result.xlsx" suffix by default.
+func NewResultAnnotator(path string) *ResultAnnotator {
+	return &ResultAnnotator{
+		path:       path,
+		outputPath: strings.TrimSuffix(path, filepath.Ext(path)) + defaultResultSuffix,
+		header:     defaultResultHeader,
+		results:    make(map[int]*rowResult),
+	}
+}
+
+// SetOutputPath set the path of the annotated workbook, must be a xlsx file.
+func (a *ResultAnnotator) SetOutputPath(outputPath string) {
+	a.outputPath = outputPath
+}
+
+// GetOutputPath get the path of the annotated workbook
+func (a *ResultAnnotator) GetOutputPath() string {
+	return a.outputPath
+}
+
+// SetHeaderRow set the row of the header, start from 0.
+func (a *ResultAnnotator) SetHeaderRow(row int) {
+	a.headerRow = row
+}
+
+// SetHeader set the header of the result column.
+func (a *ResultAnnotator) SetHeader(header string) {
+	a.header = header
+}
+
+// MarkSuccess mark the rows imported successfully.
+// the row keeps failed if any error has been recorded.
+func (a *ResultAnnotator) MarkSuccess(rows []int, msg string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, row := range rows {
+		result := a.getResult(row)
+		if len(result.errs) > 0 || len(result.cells) > 0 {
+			continue
+		}
+
+		result.success = true
+		result.msg = msg
+	}
+}
+
+// SuccessMessage return the success message with the new id.
+func SuccessMessage(id int64) string {
+	if id == 0 {
+		return resultSuccessText
+	}
+
+	return fmt.Sprintf(resultSuccessWithID, id)
+}
+
+func (a *ResultAnnotator) ObserveCheckError(err error) {
+	a.markError(err)
+}
+
+func (a *ResultAnnotator) ObserveImportError(err error) {
+	a.markError(err)
+}
+
+func (a *ResultAnnotator) FlushObserved() error {
+	return a.Write()
+}
+
+// markError mark the rows of the error as failed.
+// the error which is not a RowError can't be located, so it's ignored.
+func (a *ResultAnnotator) markError(err error) {
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cellErrs := rowErr.CellErrors()
+	for _, row := range rowErr.Rows {
+		result := a.getResult(row)
+		result.success = false
+
+		// the cell errors are shown in the comment of the cell
+		for _, cellErr := range cellErrs {
+			result.cells[cellErr.Col] = append(result.cells[cellErr.Col], cellErr.Err.Error())
+		}
+
+		// the other errors are shown in the result column
+		for _, e := range rowErr.Errors() {
+			if len(collectCellErrors(e)) > 0 {
+				continue
+			}
+			result.errs = append(result.errs, strings.TrimSpace(e.Error()))
+		}
+	}
+}
+
+func (a *ResultAnnotator) getResult(row int) *rowResult {
+	result, ok := a.results[row]
+	if !ok {
+		result = &rowResult{cells: make(map[int][]string)}
+		a.results[row] = result
+	}
+
+	return result
+}
+
+// Write write the annotated workbook into the output path.
+func (a *ResultAnnotator) Write() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := a.openWorkbook()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return err
+	}
+
+	// the result column is after the last column
+	resultCol := 0
+	for _, row := range rows {
+		resultCol = max(resultCol, len(row))
+	}
+
+	failedStyle, err := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{defaultFailedColor}, Pattern: 1},
+		Font: &excelize.Font{Color: defaultFailedFontClr},
+	})
+	if err != nil {
+		return err
+	}
+
+	// write the header
+	cell, err := excelize.CoordinatesToCellName(resultCol+1, a.headerRow+1)
+	if err != nil {
+		return err
+	}
+	if err = f.SetCellValue(sheet, cell, a.header); err != nil {
+		return err
+	}
+
+	// write the result in the row order
+	resultRows := make([]int, 0, len(a.results))
+	for row := range a.results {
+		resultRows = append(resultRows, row)
+	}
+	sort.Ints(resultRows)
+
+	for _, row := range resultRows {
+		if err = a.writeRowResult(f, sheet, row, resultCol, failedStyle); err != nil {
+			return err
+		}
+	}
+
+	return f.SaveAs(a.outputPath)
+}
+
+func (a *ResultAnnotator) writeRowResult(f *excelize.File, sheet string, row, resultCol, failedStyle int) error {
+	result := a.results[row]
+	resultCell, err := excelize.CoordinatesToCellName(resultCol+1, row+1)
+	if err != nil {
+		return err
+	}
+
+	if result.success {
+		return f.SetCellValue(sheet, resultCell, result.msg)
+	}
+
+	// highlight the failed cells and comment the errors
+	cols := make([]int, 0, len(result.cells))
+	for col := range result.cells {
+		cols = append(cols, col)
+	}
+	sort.Ints(cols)
+
+	msgs := append([]string{}, result.errs...)
+	for _, col := range cols {
+		cell, err := excelize.CoordinatesToCellName(col+1, row+1)
+		if err != nil {
+			return err
+		}
+
+		text := strings.Join(result.cells[col], resultCellErrSep)
+		if err = f.SetCellStyle(sheet, cell, cell, failedStyle); err != nil {
+			return err
+		}
+		if err = f.AddComment(sheet, excelize.Comment{
+			Author: defaultCommentAuthor,
+			Cell:   cell,
+			Text:   text,
+		}); err != nil {
+			return err
+		}
+
+		msgs = append(msgs, fmt.Sprintf("%s: %s", cell, text))
+	}
+
+	if err = f.SetCellValue(sheet, resultCell, strings.Join(msgs, resultCellErrSep)); err != nil {
+		return err
+	}
+
+	return f.SetCellStyle(sheet, resultCell, resultCell, failedStyle)
+}
+
+// openWorkbook open the source workbook.
+// the csv file is converted into a new workbook.
+func (a *ResultAnnotator) openWorkbook() (*excelize.File, error) {
+	if strings.ToLower(filepath.Ext(a.path)) == ".xlsx" {
+		return excelize.OpenFile(a.path)
+	}
+
+	contents, err := ReadExcelContent(a.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for i, row := range contents {
+		for j, c := range row {
+			cell, err := excelize.CoordinatesToCellName(j+1, i+1)
+			if err != nil {
+				return nil, err
+			}
+			if err = f.SetCellStr(sheet, cell, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f, nil
+}
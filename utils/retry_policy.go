@@ -0,0 +1,187 @@
+package util
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	mysqlErrLockDeadlock    = 1213
+	mysqlErrLockWaitTimeout = 1205
+
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultRetryJitter         = 0.2
+
+	retrySavePointName = "exi_retry_%d"
+)
+
+var (
+	ErrRetryInParallelTx = errors.New("the retry can't run in the transaction shared by the parallel import")
+
+	retrySavePointSeq atomic.Int64
+)
+
+// RetryClassifier reports whether the error is retryable
+type RetryClassifier func(err error) bool
+
+// OnRetryFunc called before every retry, attempt is the failed attempt, start from 1
+type OnRetryFunc func(attempt int, err error)
+
+// RetryPolicy retries the transient db errors with the exponential backoff.
+// the nil policy runs only once.
+type RetryPolicy struct {
+	// the max attempts including the first one, no retry if less than 2
+	MaxAttempts int
+	// the backoff before the first retry, doubled for every retry
+	InitialBackoff time.Duration
+	// the max backoff
+	MaxBackoff time.Duration
+	// the random ratio of the backoff, in [0, 1]
+	Jitter float64
+	// classify the retryable error, IsTransientDBError by default
+	Classifier RetryClassifier
+}
+
+// NewDefaultRetryPolicy retry the transient db errors 3 times at most
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Jitter:         defaultRetryJitter,
+		Classifier:     IsTransientDBError,
+	}
+}
+
+// IsTransientDBError check if the error is the mysql deadlock, the lock wait timeout or the bad connection
+func IsTransientDBError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrLockDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn)
+}
+
+// IsTxAbortedError check if the error aborts the whole transaction,
+// so the attempt can't be retried inside the transaction.
+func IsTxAbortedError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrLockDeadlock
+	}
+
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn)
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) retryable(err error, inTx bool) bool {
+	if inTx && IsTxAbortedError(err) {
+		return false
+	}
+
+	if p.Classifier == nil {
+		return IsTransientDBError(err)
+	}
+
+	return p.Classifier(err)
+}
+
+// Backoff the backoff before the retry of the failed attempt, start from 1
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 && backoff > 0 {
+		delta := float64(backoff) * p.Jitter
+		backoff += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+
+	return max(backoff, 0)
+}
+
+// Run run fn on the db with the retry policy, return the attempts and the last error.
+// if the db is in a transaction, every attempt runs in a savepoint which is rolled back on failure and released on success,
+// and the error which aborts the transaction, e.g. the deadlock, isn't retried.
+func (p *RetryPolicy) Run(db *gorm.DB, fn func(tx *gorm.DB) error, onRetry OnRetryFunc) (int, error) {
+	if !p.enabled() {
+		return 1, fn(db)
+	}
+
+	inTx := isInTransaction(db)
+	for attempt := 1; ; attempt++ {
+		err := runAttempt(db, inTx, fn)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if attempt >= p.MaxAttempts || !p.retryable(err, inTx) {
+			if attempt > 1 {
+				err = fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return attempt, err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+		time.Sleep(p.Backoff(attempt))
+	}
+}
+
+// CheckParallel check if the policy can be used by the parallel import on the db.
+// the failed attempt in the transaction rolls back to its savepoint, which also discards the statements
+// run by the other goroutines after it, so the retry can't be used when the parallel import shares the transaction.
+func (p *RetryPolicy) CheckParallel(db *gorm.DB) error {
+	if p.enabled() && isInTransaction(db) {
+		return ErrRetryInParallelTx
+	}
+
+	return nil
+}
+
+// runAttempt run the attempt in the savepoint if in transaction, the savepoint is released on success
+func runAttempt(db *gorm.DB, inTx bool, fn func(tx *gorm.DB) error) error {
+	if !inTx {
+		return fn(db)
+	}
+
+	name := fmt.Sprintf(retrySavePointName, retrySavePointSeq.Add(1))
+	if err := db.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	if err := fn(db); err != nil {
+		if rerr := db.RollbackTo(name).Error; rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		return err
+	}
+
+	return db.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+func isInTransaction(db *gorm.DB) bool {
+	if db == nil || db.Statement == nil {
+		return false
+	}
+
+	committer, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type retryTestModel struct {
+	ID   int64
+	Name string
+}
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+		aborted   bool
+	}{
+		{err: &mysql.MySQLError{Number: mysqlErrLockDeadlock}, transient: true, aborted: true},
+		{err: &mysql.MySQLError{Number: mysqlErrLockWaitTimeout}, transient: true, aborted: false},
+		{err: fmt.Errorf("exec failed: %w", driver.ErrBadConn), transient: true, aborted: true},
+		{err: &mysql.MySQLError{Number: 1062}, transient: false, aborted: false},
+		{err: errors.New("unknown"), transient: false, aborted: false},
+	}
+
+	for _, test := range tests {
+		if IsTransientDBError(test.err) != test.transient {
+			t.Fatalf("err %v transient expected %v", test.err, test.transient)
+		}
+		if IsTxAbortedError(test.err) != test.aborted {
+			t.Fatalf("err %v aborted expected %v", test.err, test.aborted)
+		}
+	}
+}
+
+func TestRetryPolicy_Run(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	lockTimeout := &mysql.MySQLError{Number: mysqlErrLockWaitTimeout}
+
+	// retry until success
+	var retries int
+	attempts, err := policy.Run(nil, func(tx *gorm.DB) error {
+		if retries < 2 {
+			return lockTimeout
+		}
+		return nil
+	}, func(attempt int, err error) {
+		retries++
+	})
+	if err != nil || attempts != 3 || retries != 2 {
+		t.Fatalf("attempts %d, retries %d, err %v", attempts, retries, err)
+	}
+
+	// stop after max attempts
+	attempts, err = policy.Run(nil, func(tx *gorm.DB) error {
+		return lockTimeout
+	}, nil)
+	if !errors.Is(err, lockTimeout) || attempts != 3 {
+		t.Fatalf("attempts %d, err %v", attempts, err)
+	}
+
+	// the non retryable error isn't retried
+	attempts, err = policy.Run(nil, func(tx *gorm.DB) error {
+		return errors.New("duplicate")
+	}, nil)
+	if err == nil || attempts != 1 {
+		t.Fatalf("attempts %d, err %v", attempts, err)
+	}
+
+	// the nil policy runs once
+	var nilPolicy *RetryPolicy
+	attempts, err = nilPolicy.Run(nil, func(tx *gorm.DB) error {
+		return lockTimeout
+	}, nil)
+	if err != lockTimeout || attempts != 1 {
+		t.Fatalf("attempts %d, err %v", attempts, err)
+	}
+}
+
+func TestRetryPolicy_RunInTransaction(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "retry.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&retryTestModel{}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	lockTimeout := &mysql.MySQLError{Number: mysqlErrLockWaitTimeout}
+	deadlock := &mysql.MySQLError{Number: mysqlErrLockDeadlock}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// the failed attempt is rolled back to the savepoint
+		var attempt int
+		if _, err := policy.Run(tx, func(tx *gorm.DB) error {
+			attempt++
+			if err := tx.Create(&retryTestModel{Name: fmt.Sprintf("attempt%d", attempt)}).Error; err != nil {
+				return err
+			}
+			if attempt == 1 {
+				return lockTimeout
+			}
+			return nil
+		}, nil); err != nil {
+			return err
+		}
+		// the savepoint of the succeeded attempt is released
+		if err := tx.Exec("RELEASE SAVEPOINT " + fmt.Sprintf(retrySavePointName, retrySavePointSeq.Load())).Error; err == nil {
+			t.Fatal("the savepoint isn't released")
+		}
+
+		// the retry in the transaction can't be shared by the parallel import
+		if err := policy.CheckParallel(tx); !errors.Is(err, ErrRetryInParallelTx) {
+			t.Fatalf("got %v, expected the parallel error", err)
+		}
+
+		// the deadlock aborts the transaction, so it isn't retried
+		attempts, err := policy.Run(tx, func(tx *gorm.DB) error {
+			return deadlock
+		}, nil)
+		if attempts != 1 || !errors.Is(err, deadlock) {
+			t.Fatalf("attempts %d, err %v", attempts, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	if err = db.Model(&retryTestModel{}).Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "attempt2" {
+		t.Fatalf("unexpected rows: %v", names)
+	}
+	if err = policy.CheckParallel(db); err != nil {
+		t.Fatalf("the retry out of the transaction is refused: %v", err)
+	}
+}
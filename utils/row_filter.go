@@ -1,11 +1,26 @@
 package util
 
-import "excel_import"
+import (
+	"excel_import"
+	"strings"
+)
 
 func DefaultRowEndFunc(s []string) bool {
 	return len(s) == 0 || len(s[0]) == 0
 }
 
+// BlankRowEndFunc the row is the end if all the cells are blank,
+// used when the first cell may be blank, e.g. the filled down tree sheets
+func BlankRowEndFunc(s []string) bool {
+	for _, cell := range s {
+		if len(strings.TrimSpace(cell)) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
 func DefaultRowFilter(s []string) bool {
 	return false
 }
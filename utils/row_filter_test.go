@@ -19,3 +19,12 @@ func TestFilterRows(t *testing.T) {
 		t.Fatalf("expect 1 and 7, but got %s and %s", res[0][0], res[1][0])
 	}
 }
+
+func TestBlankRowEndFunc(t *testing.T) {
+	if !BlankRowEndFunc(nil) || !BlankRowEndFunc([]string{"", " "}) {
+		t.Fatal("expect the blank row is the end")
+	}
+	if BlankRowEndFunc([]string{"", "a"}) {
+		t.Fatal("expect the row with the blank first cell isn't the end")
+	}
+}
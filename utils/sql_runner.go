@@ -17,10 +17,11 @@ const (
 )
 
 type SqlSentencesRunner struct {
-	sqlPath   string
-	sqlFile   *os.File
-	db        *gorm.DB
-	tableName string
+	sqlPath     string
+	sqlFile     *os.File
+	db          *gorm.DB
+	tableName   string
+	rateLimiter *RateLimiter
 }
 
 func NewSqlSentencesRunner(sqlPath string, db *gorm.DB, tableName string) *SqlSentencesRunner {
@@ -31,6 +32,11 @@ func NewSqlSentencesRunner(sqlPath string, db *gorm.DB, tableName string) *SqlSe
 	}
 }
 
+// SetRateLimiter set the rate limiter of the batch execution
+func (r *SqlSentencesRunner) SetRateLimiter(limiter *RateLimiter) {
+	r.rateLimiter = limiter
+}
+
 func (r *SqlSentencesRunner) GenerateSqlInsertSentences(model any) error {
 	if r.sqlFile == nil {
 		err := r.initSqlFile()
@@ -51,6 +57,17 @@ func (r *SqlSentencesRunner) GenerateSqlInsertSentences(model any) error {
 	return nil
 }
 
+// OpenSqlFile open the existing sql file, so that the sentences written before can be run
+func (r *SqlSentencesRunner) OpenSqlFile() error {
+	file, err := os.Open(r.sqlPath)
+	if err != nil {
+		return err
+	}
+
+	r.sqlFile = file
+	return nil
+}
+
 func (r *SqlSentencesRunner) TableName() string {
 	return r.tableName
 }
@@ -104,7 +121,9 @@ func (r *SqlSentencesRunner) RunSqlSentencesWithBatch(batchSize int) error {
 
 			// run sql sentences
 			sql := strings.Join(sqlSentences, "\n")
-			if err = r.db.Exec(sql).Error; err != nil {
+			if err = r.rateLimiter.Run(len(sqlSentences), len(sqlSentences), func() error {
+				return r.db.Exec(sql).Error
+			}); err != nil {
 				return err
 			}
 			sqlSentences = nil
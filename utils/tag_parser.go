@@ -11,6 +11,7 @@ const (
 	excelImportTag = "exi"
 	invalidIndex   = -1
 	gormTag        = "gorm"
+	enumSep        = "|"
 )
 
 func ParseTag(st any) []*excel_import.ExcelImportTagAttr {
@@ -55,6 +56,34 @@ func ParseTag(st any) []*excel_import.ExcelImportTagAttr {
 	return tagAttrs
 }
 
+// ParseHeaderNames parse the header names of the model, indexed by the column index.
+// the name tag is used as the header name, and the field name is used if not set.
+func ParseHeaderNames(st any) []string {
+	tags := ParseTag(st)
+
+	// get struct type if st is a pointer
+	t := reflect.TypeOf(st)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	columnCount := 0
+	for _, tag := range tags {
+		columnCount = max(columnCount, tag.ColumnIndex+1)
+	}
+
+	headers := make([]string, columnCount)
+	for i, tag := range tags {
+		name := tag.Name
+		if len(name) == 0 {
+			name = t.Field(i).Name
+		}
+		headers[tag.ColumnIndex] = name
+	}
+
+	return headers
+}
+
 func parseTag(tag string) *excel_import.ExcelImportTagAttr {
 	// create a new tag attribute
 	tagAttr := &excel_import.ExcelImportTagAttr{
@@ -98,6 +127,10 @@ func parseTag(tag string) *excel_import.ExcelImportTagAttr {
 			tagAttr.FCF = excel_import.FormatCheckFunc(value)
 		case "id":
 			tagAttr.ID = value
+		case "name":
+			tagAttr.Name = value
+		case "enum":
+			tagAttr.Enum = strings.Split(value, enumSep)
 		}
 	}
 
@@ -0,0 +1,279 @@
+package util
+
+import (
+	"errors"
+	"excel_import"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultTemplateSheetName = "Sheet1"
+	defaultTemplateMaxRow    = 1048576
+	minTemplateColWidth      = 12
+	maxTemplateColWidth      = 60
+	templateDateNumFmt       = "yyyy-mm-dd"
+	templateCommentAuthor    = "excel_import"
+	// the bounds of the numeric validation
+	minTemplateWhole   = -2147483648
+	maxTemplateWhole   = 2147483647
+	minTemplateDecimal = -1e15
+	maxTemplateDecimal = 1e15
+	// the serial numbers of 1900-01-01 and 9999-12-31
+	minTemplateDate = 1
+	maxTemplateDate = 2958465
+)
+
+var (
+	fcfDescriptions = map[excel_import.FormatCheckFunc]string{
+		excel_import.FormatCheckFuncInt:      "整数",
+		excel_import.FormatCheckFuncFloat:    "数字",
+		excel_import.FormatCheckFuncUrl:      "URL链接",
+		excel_import.FormatCheckFuncImageUrl: "图片URL链接(jpg/jpeg/png/gif/bmp)",
+		excel_import.FormatCheckFuncChinese:  "包含中文",
+		excel_import.FormatCheckFuncEnglish:  "包含英文",
+		excel_import.FormatCheckFuncPinyin:   "拼音",
+		excel_import.FormatCheckFuncHash:     "哈希值(MD5/SHA1/SHA256)",
+	}
+)
+
+type TemplateOptionFunc func(*templateCfg)
+
+type templateCfg struct {
+	sheetName string
+	// the dropdown values of the column index
+	dropdowns map[int][]string
+	// the last row of the data validation, start from 1
+	maxRow int
+}
+
+// WithTemplateSheetName set the sheet name of the template
+func WithTemplateSheetName(sheetName string) TemplateOptionFunc {
+	return func(cfg *templateCfg) {
+		cfg.sheetName = sheetName
+	}
+}
+
+// WithTemplateDropdown set the dropdown values of the column, e.g. the values of the converter field.
+// it overrides the enum tag of the column.
+func WithTemplateDropdown(col int, values []string) TemplateOptionFunc {
+	return func(cfg *templateCfg) {
+		cfg.dropdowns[col] = values
+	}
+}
+
+// WithTemplateMaxRow set the last row of the data validation, start from 1
+func WithTemplateMaxRow(maxRow int) TemplateOptionFunc {
+	return func(cfg *templateCfg) {
+		cfg.maxRow = maxRow
+	}
+}
+
+// templateColumn the column info of the template
+type templateColumn struct {
+	index     int
+	header    string
+	tag       *excel_import.ExcelImportTagAttr
+	fieldType reflect.Type
+}
+
+// GenerateTemplate generate the xlsx import template of the exi tagged model.
+// the header row is frozen and commented with the expected format,
+// and the data validation is generated by the enum tag, the fcf tag and the field type.
+func GenerateTemplate(model any, path string, options ...TemplateOptionFunc) error {
+	t := reflect.TypeOf(model)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.New("input is not a struct or a pointer to a struct")
+	}
+
+	cfg := &templateCfg{
+		sheetName: defaultTemplateSheetName,
+		dropdowns: make(map[int][]string),
+		maxRow:    defaultTemplateMaxRow,
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if cfg.sheetName != f.GetSheetName(0) {
+		if err := f.SetSheetName(f.GetSheetName(0), cfg.sheetName); err != nil {
+			return err
+		}
+	}
+
+	headers := ParseHeaderNames(model)
+	for i, tag := range ParseTag(model) {
+		column := &templateColumn{
+			index:     tag.ColumnIndex,
+			header:    headers[tag.ColumnIndex],
+			tag:       tag,
+			fieldType: t.Field(i).Type,
+		}
+		if err := writeTemplateColumn(f, cfg, column); err != nil {
+			return err
+		}
+	}
+
+	// freeze the header row
+	if err := f.SetPanes(cfg.sheetName, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	return f.SaveAs(path)
+}
+
+func writeTemplateColumn(f *excelize.File, cfg *templateCfg, column *templateColumn) error {
+	colName, err := excelize.ColumnNumberToName(column.index + 1)
+	if err != nil {
+		return err
+	}
+	headerCell := colName + "1"
+
+	// write the header
+	if err = f.SetCellStr(cfg.sheetName, headerCell, column.header); err != nil {
+		return err
+	}
+	if err = f.SetColWidth(cfg.sheetName, colName, colName, templateColWidth(column.header)); err != nil {
+		return err
+	}
+
+	// comment the expected format
+	if desc := describeTemplateColumn(cfg, column); len(desc) > 0 {
+		if err = f.AddComment(cfg.sheetName, excelize.Comment{
+			Author: templateCommentAuthor,
+			Cell:   headerCell,
+			Text:   desc,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// add the data validation
+	dv, err := newTemplateDataValidation(cfg, column)
+	if err != nil || dv == nil {
+		return err
+	}
+	dv.Sqref = fmt.Sprintf("%s2:%s%d", colName, colName, cfg.maxRow)
+	if err = f.AddDataValidation(cfg.sheetName, dv); err != nil {
+		return err
+	}
+
+	// show the date cell in the layout which can be parsed
+	if dv.Type == "date" {
+		style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &[]string{templateDateNumFmt}[0]})
+		if err != nil {
+			return err
+		}
+		return f.SetColStyle(cfg.sheetName, colName, style)
+	}
+
+	return nil
+}
+
+// newTemplateDataValidation create the data validation of the column, nil if no validation
+func newTemplateDataValidation(cfg *templateCfg, column *templateColumn) (*excelize.DataValidation, error) {
+	dv := excelize.NewDataValidation(true)
+	dv.SetError(excelize.DataValidationErrorStyleStop, column.header, describeTemplateColumn(cfg, column))
+
+	if values := templateDropdownValues(cfg, column); len(values) > 0 {
+		return dv, dv.SetDropList(values)
+	}
+
+	switch {
+	case isTemplateDateType(column.fieldType):
+		return dv, dv.SetRange(minTemplateDate, maxTemplateDate, excelize.DataValidationTypeDate, excelize.DataValidationOperatorBetween)
+	case column.tag.FCF == excel_import.FormatCheckFuncInt || isTemplateKind(column.fieldType, reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64):
+		return dv, dv.SetRange(minTemplateWhole, maxTemplateWhole, excelize.DataValidationTypeWhole, excelize.DataValidationOperatorBetween)
+	case column.tag.FCF == excel_import.FormatCheckFuncFloat || isTemplateKind(column.fieldType, reflect.Float32, reflect.Float64):
+		return dv, dv.SetRange(minTemplateDecimal, maxTemplateDecimal, excelize.DataValidationTypeDecimal, excelize.DataValidationOperatorBetween)
+	case isTemplateKind(column.fieldType, reflect.Bool):
+		return dv, dv.SetDropList([]string{"true", "false"})
+	}
+
+	return nil, nil
+}
+
+// describeTemplateColumn describe the expected format of the column
+func describeTemplateColumn(cfg *templateCfg, column *templateColumn) string {
+	var descs []string
+	if values := templateDropdownValues(cfg, column); len(values) > 0 {
+		descs = append(descs, "可选值: "+strings.Join(values, ", "))
+	}
+
+	switch {
+	case isTemplateDateType(column.fieldType):
+		descs = append(descs, "日期, 格式: 2006-01-02 或 2006-01-02 15:04:05")
+	case isTemplateKind(column.fieldType, reflect.Bool):
+		descs = append(descs, "布尔值: true 或 false")
+	case isTemplateKind(column.fieldType, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64):
+		descs = append(descs, "整数")
+	case isTemplateKind(column.fieldType, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64):
+		descs = append(descs, "非负整数")
+	case isTemplateKind(column.fieldType, reflect.Float32, reflect.Float64):
+		descs = append(descs, "数字")
+	}
+
+	if desc, ok := fcfDescriptions[column.tag.FCF]; ok {
+		descs = append(descs, desc)
+	}
+
+	return strings.Join(descs, "; ")
+}
+
+func templateDropdownValues(cfg *templateCfg, column *templateColumn) []string {
+	if values, ok := cfg.dropdowns[column.index]; ok {
+		return values
+	}
+
+	return column.tag.Enum
+}
+
+func isTemplateDateType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t == timeType
+}
+
+func isTemplateKind(t reflect.Type, kinds ...reflect.Kind) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for _, kind := range kinds {
+		if t.Kind() == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// templateColWidth the width of the column, the wide character is counted as two
+func templateColWidth(header string) float64 {
+	width := 0
+	for _, r := range header {
+		if utf8.RuneLen(r) > 1 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+
+	return float64(min(max(width+2, minTemplateColWidth), maxTemplateColWidth))
+}
@@ -3,6 +3,9 @@ package util
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"sync"
 )
@@ -13,16 +16,29 @@ const (
 	unexpectedJsonPath = "unexpected.jsonl"
 )
 
+// RecordObserver observes the errors recorded by UnexpectedRecorder.
+type RecordObserver interface {
+	// ObserveCheckError called when a check error is recorded
+	ObserveCheckError(err error)
+	// ObserveImportError called when an import error is recorded
+	ObserveImportError(err error)
+	// FlushObserved called when the recorder is flushed
+	FlushObserved() error
+}
+
 type UnexpectedRecorder struct {
 	checkFailedPath       string
 	checkFailedCsvWriter  *csv.Writer
+	checkFailedFile       *os.File
 	importFailedPath      string
 	importFailedCsvWriter *csv.Writer
+	importFailedFile      *os.File
 	mu                    sync.Mutex
 	importFailedJsonPath  string
 	importFailedJsonFile  *os.File
 	jsonDecoder           *json.Decoder
 	jsonEncoder           *json.Encoder
+	observers             []RecordObserver
 }
 
 func NewDefaultUnexpectedRecorder() *UnexpectedRecorder {
@@ -33,11 +49,54 @@ func NewDefaultUnexpectedRecorder() *UnexpectedRecorder {
 	}
 }
 
+// NewUnexpectedRecorder create the recorder with the paths of the recorded files.
+func NewUnexpectedRecorder(checkFailedPath, importFailedPath, unexpectedJsonPath string) *UnexpectedRecorder {
+	return &UnexpectedRecorder{
+		checkFailedPath:      checkFailedPath,
+		importFailedPath:     importFailedPath,
+		importFailedJsonPath: unexpectedJsonPath,
+	}
+}
+
+func (u *UnexpectedRecorder) GetCheckFailedPath() string {
+	return u.checkFailedPath
+}
+
+func (u *UnexpectedRecorder) GetImportFailedPath() string {
+	return u.importFailedPath
+}
+
+func (u *UnexpectedRecorder) GetUnexpectedJsonPath() string {
+	return u.importFailedJsonPath
+}
+
+// AddObserver add the observer of the recorded errors.
+// the observer which has been added is ignored.
+func (u *UnexpectedRecorder) AddObserver(o RecordObserver) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, observer := range u.observers {
+		if observer == o {
+			return
+		}
+	}
+
+	u.observers = append(u.observers, o)
+}
+
 func (u *UnexpectedRecorder) RecordCheckError(err error) error {
 	if err == nil {
 		return nil
 	}
 
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, observer := range u.observers {
+		observer.ObserveCheckError(err)
+	}
+
 	// Initialize the csv writer.
 	if u.checkFailedCsvWriter == nil {
 		uerr := u.initCheckFailedCsvWriter()
@@ -56,6 +115,7 @@ func (u *UnexpectedRecorder) initCheckFailedCsvWriter() error {
 		return err
 	}
 
+	u.checkFailedFile = file
 	u.checkFailedCsvWriter = csv.NewWriter(file)
 	return nil
 }
@@ -70,6 +130,10 @@ func (u *UnexpectedRecorder) RecordImportError(err error) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
+	for _, observer := range u.observers {
+		observer.ObserveImportError(err)
+	}
+
 	// Initialize the csv writer.
 	if u.importFailedCsvWriter == nil {
 		uerr := u.initImportFailedCsvWriter()
@@ -90,6 +154,10 @@ func (u *UnexpectedRecorder) RecordImportErrorWithContent(err error, contents ..
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
+	for _, observer := range u.observers {
+		observer.ObserveImportError(err)
+	}
+
 	// Initialize the csv writer.
 	if u.importFailedCsvWriter == nil {
 		uerr := u.initImportFailedCsvWriter()
@@ -147,6 +215,7 @@ func (u *UnexpectedRecorder) initImportFailedCsvWriter() error {
 		return err
 	}
 
+	u.importFailedFile = file
 	u.importFailedCsvWriter = csv.NewWriter(file)
 	return nil
 }
@@ -163,4 +232,94 @@ func (u *UnexpectedRecorder) Flush() {
 	if u.importFailedJsonFile != nil {
 		u.importFailedJsonFile.Close()
 	}
+
+	for _, observer := range u.observers {
+		if err := observer.FlushObserved(); err != nil {
+			fmt.Printf("flush record observer failed: %v\n", err)
+		}
+	}
+}
+
+// Close flush the recorded csv files and close the files, the recorder can't record after closed.
+// the observers aren't flushed, which is done by Flush.
+func (u *UnexpectedRecorder) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var errs []error
+	for _, writer := range []*csv.Writer{u.checkFailedCsvWriter, u.importFailedCsvWriter} {
+		if writer != nil {
+			writer.Flush()
+			errs = append(errs, writer.Error())
+		}
+	}
+	for _, file := range []*os.File{u.checkFailedFile, u.importFailedFile, u.importFailedJsonFile} {
+		if file == nil {
+			continue
+		}
+		// the json file is closed by Flush
+		if err := file.Close(); !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Merge merge the recorded files of the others into the files of the recorder in order.
+// the files of the recorder are overwritten, and must be called after the others are flushed.
+func (u *UnexpectedRecorder) Merge(others ...*UnexpectedRecorder) error {
+	var checkFailedPaths, importFailedPaths, jsonPaths []string
+	for _, other := range others {
+		checkFailedPaths = append(checkFailedPaths, other.checkFailedPath)
+		importFailedPaths = append(importFailedPaths, other.importFailedPath)
+		jsonPaths = append(jsonPaths, other.importFailedJsonPath)
+	}
+
+	if err := mergeRecordedFiles(u.checkFailedPath, checkFailedPaths); err != nil {
+		return err
+	}
+	if err := mergeRecordedFiles(u.importFailedPath, importFailedPaths); err != nil {
+		return err
+	}
+
+	return mergeRecordedFiles(u.importFailedJsonPath, jsonPaths)
+}
+
+// mergeRecordedFiles append the srcs into the dst, the src not recorded is skipped.
+// the stale dst is removed if no src is recorded.
+func mergeRecordedFiles(dst string, srcs []string) error {
+	var out *os.File
+	for _, src := range srcs {
+		in, err := os.Open(src)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if out == nil {
+			if out, err = os.Create(dst); err != nil {
+				in.Close()
+				return err
+			}
+		}
+
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	if out == nil {
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	return out.Close()
 }
@@ -3,6 +3,8 @@ package util
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -136,3 +138,78 @@ func TestUnexpectedRecorder_IterateJsonContent(t *testing.T) {
 
 	t.Log("IterateJsonContent success")
 }
+
+func TestUnexpectedRecorder_Merge(t *testing.T) {
+	dir := t.TempDir()
+	newRecorder := func(name string) *UnexpectedRecorder {
+		return NewUnexpectedRecorder(filepath.Join(dir, name+"_check_failed.csv"),
+			filepath.Join(dir, name+"_import_failed.csv"), filepath.Join(dir, name+"_unexpected.jsonl"))
+	}
+
+	dst, shard1, shard2 := newRecorder("dst"), newRecorder("shard1"), newRecorder("shard2")
+	if err := shard1.RecordCheckError(errors.New("check error 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := shard2.RecordCheckError(errors.New("check error 2")); err != nil {
+		t.Fatal(err)
+	}
+	shard1.Flush()
+	shard2.Flush()
+	if err := dst.Merge(shard1, shard2); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ReadExcelContent(dst.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || contents[0][0] != "check error 1" || contents[1][0] != "check error 2" {
+		t.Fatalf("unexpected merged contents %v", contents)
+	}
+
+	// the stale files of the last merge are removed if no shard is recorded
+	if err = dst.Merge(newRecorder("shard3")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(dst.GetCheckFailedPath()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the stale check failed file removed, got %v", err)
+	}
+}
+
+type countObserver struct {
+	checkErrors int
+}
+
+func (c *countObserver) ObserveCheckError(error)  { c.checkErrors++ }
+func (c *countObserver) ObserveImportError(error) {}
+func (c *countObserver) FlushObserved() error     { return nil }
+
+func TestUnexpectedRecorder_RecordCheckErrorConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewUnexpectedRecorder(filepath.Join(dir, "check_failed.csv"),
+		filepath.Join(dir, "import_failed.csv"), filepath.Join(dir, "unexpected.jsonl"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			recorder.AddObserver(&countObserver{})
+		}()
+		go func() {
+			defer wg.Done()
+			if err := recorder.RecordCheckError(errors.New("check error")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	recorder.Flush()
+
+	contents, err := ReadExcelContent(recorder.GetCheckFailedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 10 {
+		t.Fatalf("expected 10 check errors, got %d", len(contents))
+	}
+}